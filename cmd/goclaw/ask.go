@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/user/goclaw2/internal/agent"
+	"github.com/user/goclaw2/internal/lineedit"
+	"github.com/user/goclaw2/internal/provider/zhipu"
+)
+
+// maxAskStdin bounds the context read from stdin
+const maxAskStdin = 4 << 20
+
+var (
+	askSession string
+	askNoTools bool
+	askJSON    bool
+	askPlain   bool
+
+	askAllowApproval bool
+)
+
+var askCmd = &cobra.Command{
+	Use:   "ask [question]",
+	Short: "Ask a single question and print the answer",
+	Long: `Run one agent turn, including tool calls, and print only the answer to
+stdout. Piped stdin is appended to the question as context:
+
+  git diff | goclaw ask "review this"
+  goclaw ask --json "what changed in go.mod?" | jq -r .response
+
+On a terminal the answer is rendered as markdown; it is printed as written
+with --plain or when stdout is redirected. Stdin is read whenever it is
+not a terminal; redirect it from /dev/null when running from a context
+that leaves it open, such as a background job. Exits non-zero when the
+turn fails.
+
+Nobody can approve tools listed in agent.approval_tools during ask, so they
+are refused unless --allow-approval-tools is given. Keep it off when the
+question or piped context comes from an untrusted source.`,
+	// Keep stdout for the answer alone
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		color.Output = os.Stderr
+		return prerun(cmd, args)
+	},
+	SilenceUsage:  true,
+	SilenceErrors: true, // main prints the error to stderr
+	RunE:          runAsk,
+}
+
+func init() {
+	askCmd.Flags().StringVar(&askSession, "session", "default", "session whose history the question joins")
+	askCmd.Flags().BoolVar(&askNoTools, "no-tools", false, "answer without calling any tools")
+	askCmd.Flags().BoolVar(&askJSON, "json", false, "print the answer, usage and tool calls as JSON")
+	askCmd.Flags().BoolVar(&askPlain, "plain", false, "print the answer as raw markdown even on a terminal")
+	askCmd.Flags().BoolVar(&askAllowApproval, "allow-approval-tools", false, "run agent.approval_tools without asking")
+}
+
+// askResult is the --json output
+type askResult struct {
+	Session   string        `json:"session"`
+	Response  string        `json:"response,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	ToolCalls []askToolCall `json:"tool_calls"`
+	Usage     zhipu.Usage   `json:"usage"`
+}
+
+type askToolCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Failed    bool   `json:"failed"`
+}
+
+func runAsk(cmd *cobra.Command, args []string) error {
+	defer shutdown()
+
+	result := askResult{Session: askSession, ToolCalls: []askToolCall{}}
+	fail := func(err error) error {
+		if askJSON {
+			result.Error = err.Error()
+			printJSON(result)
+		}
+		return err
+	}
+
+	if strings.TrimSpace(askSession) == "" {
+		return fail(fmt.Errorf("--session must not be empty"))
+	}
+	question, err := askQuestion(args)
+	if err != nil {
+		return fail(err)
+	}
+
+	// Read-only tools run in parallel and report from their own goroutines
+	var toolCallsMu sync.Mutex
+	ev := &agent.Events{
+		OnToolFinish: func(call zhipu.ToolCall, _ string, failed bool) {
+			toolCallsMu.Lock()
+			defer toolCallsMu.Unlock()
+			result.ToolCalls = append(result.ToolCalls, askToolCall{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+				Failed:    failed,
+			})
+		},
+		OnUsage: func(u zhipu.Usage) {
+			result.Usage.PromptTokens += u.PromptTokens
+			result.Usage.CompletionTokens += u.CompletionTokens
+			result.Usage.TotalTokens += u.TotalTokens
+		},
+		SkipApproval: askAllowApproval,
+	}
+	if askNoTools {
+		ev.AllowTool = func(string) bool { return false }
+	}
+
+	a := agt
+	if askSession != mem.SessionID() {
+		a = agent.New(cfg, mem.Session(askSession), toolReg)
+	}
+	response, err := a.ChatWithEvents(question, ev)
+	if err != nil {
+		return fail(err)
+	}
+
+	if askJSON {
+		result.Response = response
+		printJSON(result)
+		return nil
+	}
+	if !askPlain && lineedit.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Println(renderMarkdown(response))
+		return nil
+	}
+	fmt.Println(strings.TrimRight(response, "\n"))
+	return nil
+}
+
+// askQuestion combines the arguments with piped stdin
+func askQuestion(args []string) (string, error) {
+	question := strings.TrimSpace(strings.Join(args, " "))
+
+	var piped string
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+		data, err := io.ReadAll(io.LimitReader(os.Stdin, maxAskStdin+1))
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+		if len(data) > maxAskStdin {
+			return "", fmt.Errorf("stdin is larger than %d MB", maxAskStdin>>20)
+		}
+		piped = strings.TrimSpace(string(data))
+	}
+
+	switch {
+	case question == "" && piped == "":
+		return "", fmt.Errorf("no question given: pass it as an argument or on stdin")
+	case piped == "":
+		return question, nil
+	case question == "":
+		return piped, nil
+	}
+	return question + "\n\n" + piped, nil
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
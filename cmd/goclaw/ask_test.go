@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/goclaw2/internal/agent"
+	"github.com/user/goclaw2/internal/config"
+	"github.com/user/goclaw2/internal/memory"
+	"github.com/user/goclaw2/internal/tools"
+)
+
+// slowReadTool is parallel safe and waits until all calls of a round have
+// started, so they are guaranteed to overlap
+type slowReadTool struct {
+	started *sync.WaitGroup
+}
+
+func (t *slowReadTool) Name() string        { return "slow_read" }
+func (t *slowReadTool) Description() string { return "Read slowly" }
+func (t *slowReadTool) ParallelSafe() bool  { return true }
+
+func (t *slowReadTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"n": map[string]interface{}{"type": "integer"}},
+	}
+}
+
+func (t *slowReadTool) Execute(args map[string]interface{}) (string, error) {
+	t.started.Done()
+	t.started.Wait()
+	return fmt.Sprint(args["n"]), nil
+}
+
+// askModel answers the question with calls calls of slow_read in one
+// round, then with "done"
+func askModel(t *testing.T, calls int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Role string `json:"role"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		msg := map[string]interface{}{"role": "assistant", "content": "done"}
+		if req.Messages[len(req.Messages)-1].Role == "user" {
+			var toolCalls []interface{}
+			for i := 0; i < calls; i++ {
+				toolCalls = append(toolCalls, map[string]interface{}{
+					"id": fmt.Sprintf("call-%d", i), "type": "function",
+					"function": map[string]string{"name": "slow_read", "arguments": fmt.Sprintf(`{"n": %d}`, i)},
+				})
+			}
+			msg = map[string]interface{}{"role": "assistant", "content": "", "tool_calls": toolCalls}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      "x",
+			"choices": []interface{}{map[string]interface{}{"index": 0, "message": msg, "finish_reason": "stop"}},
+			"usage":   map[string]int{"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRunAskParallelToolCalls(t *testing.T) {
+	const calls = 8
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "goclaw.yaml")
+	err := os.WriteFile(cfgFile, []byte(fmt.Sprintf(`
+zhipu:
+  api_key: "test"
+  base_url: %q
+agent:
+  max_parallel_tools: %d
+memory:
+  file_path: %q
+  workspace: %q
+`, askModel(t, calls).URL, calls, filepath.Join(dir, "goclaw.db"), filepath.Join(dir, "ws"))), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = config.Load(cfgFile); err != nil {
+		t.Fatal(err)
+	}
+	if mem, err = memory.New(cfg.Memory.FilePath, "default"); err != nil {
+		t.Fatal(err)
+	}
+	var started sync.WaitGroup
+	started.Add(calls)
+	toolReg = tools.New()
+	toolReg.Register(&slowReadTool{started: &started})
+	agt = agent.New(cfg, mem, toolReg)
+
+	askSession, askJSON = "default", true
+	defer func() { askJSON = false }()
+
+	// runAsk prints the JSON result to stdout and reads piped stdin
+	stdin, stdout := os.Stdin, os.Stdout
+	defer func() { os.Stdin, os.Stdout = stdin, stdout }()
+	os.Stdin, err = os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	output := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- data
+	}()
+
+	done := make(chan error)
+	go func() { done <- runAsk(askCmd, []string{"read everything"}) }()
+	select {
+	case err = <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("runAsk did not finish; the tool calls did not run in parallel")
+	}
+	w.Close()
+	data := <-output
+	if err != nil {
+		t.Fatalf("runAsk: %v\n%s", err, data)
+	}
+
+	var result askResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, data)
+	}
+	if result.Response != "done" {
+		t.Errorf("response = %q, want done", result.Response)
+	}
+	seen := map[string]bool{}
+	for _, call := range result.ToolCalls {
+		if call.Name != "slow_read" || call.Failed {
+			t.Errorf("unexpected tool call %+v", call)
+		}
+		seen[call.Arguments] = true
+	}
+	if len(result.ToolCalls) != calls || len(seen) != calls {
+		t.Errorf("got %d tool calls (%d distinct), want %d: %+v", len(result.ToolCalls), len(seen), calls, result.ToolCalls)
+	}
+	if result.Usage.TotalTokens != 4 {
+		t.Errorf("usage = %+v, want 4 total tokens", result.Usage)
+	}
+}
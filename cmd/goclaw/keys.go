@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/user/goclaw2/internal/gateway"
+)
+
+var (
+	keyName   string
+	keyScopes []string
+	keyTools  []string
+	keyRate   int
+	keyQuota  int
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage gateway API keys",
+}
+
+var keysCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an API key (shown only once)",
+	RunE:  runKeysCreate,
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API keys",
+	RunE:  runKeysList,
+}
+
+var keysRevokeCmd = &cobra.Command{
+	Use:   "revoke <id|prefix>",
+	Short: "Revoke an API key",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runKeysRevoke,
+}
+
+func init() {
+	keysCreateCmd.Flags().StringVar(&keyName, "name", "", "name of the key's owner or purpose (required)")
+	keysCreateCmd.Flags().StringSliceVar(&keyScopes, "scopes", []string{gateway.ScopeChat}, "scopes: chat, admin")
+	keysCreateCmd.Flags().StringSliceVar(&keyTools, "tools", nil, "tools the key may use (default: all)")
+	keysCreateCmd.Flags().IntVar(&keyRate, "rate", 60, "requests per minute, 0 for unlimited")
+	keysCreateCmd.Flags().IntVar(&keyQuota, "quota", 0, "total model tokens, 0 for unlimited")
+	keysCreateCmd.MarkFlagRequired("name")
+
+	keysCmd.AddCommand(keysCreateCmd, keysListCmd, keysRevokeCmd)
+}
+
+func runKeysCreate(cmd *cobra.Command, args []string) error {
+	keys, err := gateway.NewKeyStore(mem.DB())
+	if err != nil {
+		return err
+	}
+	for _, name := range keyTools {
+		if _, ok := toolReg.Get(name); !ok {
+			return fmt.Errorf("unknown tool: %s", name)
+		}
+	}
+
+	key, secret, err := keys.Create(gateway.APIKey{
+		Name:       keyName,
+		Scopes:     keyScopes,
+		Tools:      keyTools,
+		RateLimit:  keyRate,
+		TokenQuota: keyQuota,
+	})
+	if err != nil {
+		return err
+	}
+
+	color.Green("Created key %d (%s) for %s", key.ID, key.Prefix, key.Name)
+	color.White("\n  %s\n", secret)
+	color.Yellow("Store it now; it cannot be shown again.")
+	return nil
+}
+
+func runKeysList(cmd *cobra.Command, args []string) error {
+	keys, err := gateway.NewKeyStore(mem.DB())
+	if err != nil {
+		return err
+	}
+	list, err := keys.List()
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		color.Yellow("No API keys. Create one with `goclaw keys create --name <name>`.")
+		return nil
+	}
+
+	fmt.Printf("%-4s %-14s %-16s %-12s %-8s %-18s %-16s %s\n", "ID", "PREFIX", "NAME", "SCOPES", "RATE", "TOKENS", "LAST USED", "STATUS")
+	for _, k := range list {
+		rate, tools := "-", ""
+		if k.RateLimit > 0 {
+			rate = fmt.Sprintf("%d/min", k.RateLimit)
+		}
+		tokens := fmt.Sprintf("%d", k.TokensUsed)
+		if k.TokenQuota > 0 {
+			tokens += fmt.Sprintf("/%d", k.TokenQuota)
+		}
+		lastUsed := "never"
+		if k.LastUsedAt != nil {
+			lastUsed = k.LastUsedAt.Format("2006-01-02 15:04")
+		}
+		status := "active"
+		if k.RevokedAt != nil {
+			status = "revoked " + k.RevokedAt.Format("2006-01-02")
+		}
+		if len(k.Tools) > 0 {
+			tools = "  tools: " + strings.Join(k.Tools, ",")
+		}
+		fmt.Printf("%-4d %-14s %-16s %-12s %-8s %-18s %-16s %s%s\n",
+			k.ID, k.Prefix, k.Name, strings.Join(k.Scopes, ","), rate, tokens, lastUsed, status, tools)
+	}
+	return nil
+}
+
+func runKeysRevoke(cmd *cobra.Command, args []string) error {
+	keys, err := gateway.NewKeyStore(mem.DB())
+	if err != nil {
+		return err
+	}
+	if err := keys.Revoke(args[0]); err != nil {
+		return err
+	}
+	color.Green("Revoked key %s", args[0])
+	return nil
+}
@@ -1,18 +1,23 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/user/goclaw2/internal/agent"
 	"github.com/user/goclaw2/internal/config"
+	"github.com/user/goclaw2/internal/lineedit"
+	"github.com/user/goclaw2/internal/mcp"
 	"github.com/user/goclaw2/internal/memory"
+	"github.com/user/goclaw2/internal/scheduler"
 	"github.com/user/goclaw2/internal/tools"
 )
 
@@ -22,9 +27,16 @@ var (
 	agt     *agent.Agent
 	mem     *memory.Store
 	toolReg *tools.Registry
+
+	reminders *scheduler.ReminderStore
+
+	mcpClients []*mcp.Client
 )
 
 func main() {
+	// Must run first: exec_command re-executes this binary to set up its sandbox
+	tools.HandleSandboxInit()
+
 	var rootCmd = &cobra.Command{
 		Use:   "goclaw",
 		Short: "GoClaw2 - AI Assistant with Tool Support",
@@ -40,6 +52,7 @@ tool execution (file operations, commands), and memory management.`,
 		Short: "Start interactive chat",
 		RunE:  runChat,
 	}
+	chatCmd.Flags().BoolVar(&chatPlain, "plain", false, "print responses as raw markdown instead of rendering them")
 
 	var configCmd = &cobra.Command{
 		Use:   "config",
@@ -65,7 +78,7 @@ tool execution (file operations, commands), and memory management.`,
 	}
 
 	memoryCmd.AddCommand(memoryClearCmd, memoryShowCmd)
-	rootCmd.AddCommand(chatCmd, configCmd, memoryCmd, initCmd)
+	rootCmd.AddCommand(chatCmd, configCmd, memoryCmd, initCmd, mcpCmd, serveCmd, keysCmd, tasksCmd, askCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -87,12 +100,33 @@ func prerun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to initialize memory: %w", err)
 	}
 
+	// Confine file tools to the configured roots
+	roots := make([]tools.Root, len(cfg.Tools.Roots))
+	for i, root := range cfg.Tools.Roots {
+		roots[i] = tools.Root{Path: root.Path, ReadOnly: root.ReadOnly}
+	}
+	// Oversized tool results are saved here; read_file must be able to page them
+	if cfg.Tools.OutputMaxBytes > 0 {
+		if err := os.MkdirAll(cfg.Tools.SpillDir, 0755); err != nil {
+			return fmt.Errorf("failed to create spill directory: %w", err)
+		}
+		roots = append(roots, tools.Root{Path: cfg.Tools.SpillDir, ReadOnly: true})
+	}
+	policy, err := tools.NewPathPolicy(roots, cfg.Tools.Deny)
+	if err != nil {
+		return fmt.Errorf("failed to initialize file access policy: %w", err)
+	}
+
 	// Initialize tool registry
 	toolReg = tools.New()
-	toolReg.Register(&tools.ReadFile{})
-	toolReg.Register(&tools.WriteFile{})
-	toolReg.Register(&tools.ListDir{})
-	toolReg.Register(&tools.ExecCommand{})
+	toolReg.Register(&tools.ReadFile{Policy: policy, MaxBytes: cfg.Tools.ReadMaxBytes})
+	toolReg.Register(&tools.WriteFile{Policy: policy})
+	toolReg.Register(&tools.EditFile{Policy: policy})
+	toolReg.Register(&tools.ListDir{Policy: policy})
+	toolReg.Register(&tools.GrepFiles{Policy: policy})
+	toolReg.Register(&tools.FindFiles{Policy: policy})
+	runner := newExecRunner()
+	toolReg.Register(&tools.ExecCommand{Runner: runner})
 
 	// Register memory tools with workspace path
 	workspaceDir := cfg.Memory.Workspace
@@ -100,12 +134,98 @@ func prerun(cmd *cobra.Command, args []string) error {
 	toolReg.Register(&tools.MemoryGet{WorkspaceDir: workspaceDir})
 	toolReg.Register(&tools.UpdateMemory{WorkspaceDir: workspaceDir})
 
+	// Reminders are delivered by chat and serve; the zone from IDENTITY.md
+	// wins over scheduler.timezone
+	reminders, err = scheduler.NewReminderStore(mem.DB())
+	if err != nil {
+		return fmt.Errorf("failed to initialize reminders: %w", err)
+	}
+	loc, err := schedulerLocation()
+	if err != nil {
+		return err
+	}
+	toolReg.Register(&tools.SetReminder{Store: reminders, WorkspaceDir: workspaceDir, Location: loc})
+	toolReg.Register(&tools.ListReminders{Store: reminders, WorkspaceDir: workspaceDir, Location: loc})
+	toolReg.Register(&tools.CancelReminder{Store: reminders})
+
+	// Register plugin tools after the built-ins so they cannot replace them.
+	// Only commands that run the agent or serve tools need them.
+	if runsTools(cmd) {
+		if _, err := toolReg.LoadPlugins(cfg.Tools.PluginDir, policy, runner); err != nil {
+			color.Yellow("Warning: %v", err)
+		}
+		startMCPServers()
+	}
+
 	// Initialize agent
 	agt = agent.New(cfg, mem, toolReg)
 
 	return nil
 }
 
+// runsTools reports whether cmd runs agent turns or serves tools, and so
+// needs plugin tools and MCP servers
+func runsTools(cmd *cobra.Command) bool {
+	switch cmd.CommandPath() {
+	case "goclaw chat", "goclaw ask", "goclaw serve", "goclaw mcp serve", "goclaw tasks run-now":
+		return true
+	}
+	return false
+}
+
+// startMCPServers launches the configured MCP servers and registers their
+// tools. A server that fails to start is reported and skipped.
+func startMCPServers() {
+	for _, server := range cfg.MCP.Servers {
+		client, err := mcp.Start(mcp.ServerConfig{
+			Name:    server.Name,
+			Command: server.Command,
+			Env:     server.Env,
+			Timeout: time.Duration(server.Timeout) * time.Second,
+		})
+		if err != nil {
+			color.Yellow("Warning: %v", err)
+			continue
+		}
+		if _, err := mcp.RegisterTools(toolReg, client); err != nil {
+			color.Yellow("Warning: %v", err)
+			client.Close()
+			continue
+		}
+		mcpClients = append(mcpClients, client)
+	}
+}
+
+// shutdown releases the memory store and stops MCP servers
+func shutdown() {
+	for _, client := range mcpClients {
+		client.Close()
+	}
+	if mem != nil {
+		mem.Close()
+	}
+}
+
+// newExecRunner returns the runner for exec_command and plugin tools based
+// on configuration
+func newExecRunner() tools.Runner {
+	execCfg := cfg.Tools.Exec
+	if !execCfg.Sandbox {
+		return tools.PlainRunner{}
+	}
+
+	runner := tools.NewSandboxRunner(tools.SandboxConfig{
+		Workspace:    execCfg.Workspace,
+		CPUSeconds:   execCfg.CPUSeconds,
+		MemoryMB:     execCfg.MemoryMB,
+		MaxProcesses: execCfg.MaxProcesses,
+	})
+	if err := runner.Available(); err != nil {
+		color.Yellow("Warning: command sandbox unavailable, running commands unsandboxed: %v", err)
+	}
+	return runner
+}
+
 func runChat(cmd *cobra.Command, args []string) error {
 	color.Cyan("╔════════════════════════════════════════╗")
 	color.Cyan("║        GoClaw2 - AI Assistant         ║")
@@ -115,7 +235,9 @@ func runChat(cmd *cobra.Command, args []string) error {
 	color.White("  /clear  - Clear conversation history")
 	color.White("  /quit   - Exit")
 	color.White("  /help   - Show available tools")
-	color.White("\nType your message and press Enter.\n")
+	color.White("  /copy   - Save a code block of the last answer: /copy [n] <file>")
+	color.White("\nType your message and press Enter. Alt-Enter or a \"\"\"-quoted block")
+	color.White("spans several lines; Tab completes commands and file paths.\n")
 
 	// Setup signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -124,17 +246,36 @@ func runChat(cmd *cobra.Command, args []string) error {
 	go func() {
 		<-sigChan
 		color.Yellow("\n\nShutting down gracefully...")
-		if mem != nil {
-			mem.Close()
-		}
+		shutdown()
 		os.Exit(0)
 	}()
 
-	reader := bufio.NewReader(os.Stdin)
+	chatEditor = newChatEditor()
+
+	if cfg.Scheduler.Enabled {
+		if err := startChatScheduler(); err != nil {
+			color.Yellow("Warning: scheduled tasks disabled: %v", err)
+		}
+	}
+	go reminders.Deliver(context.Background(), func(r *scheduler.Reminder) bool {
+		// Reminders set over the gateway belong to its clients
+		if r.KeyID != 0 || r.Session != mem.SessionID() {
+			return false
+		}
+		chatPrint(color.MagentaString("\n⏰ Reminder (%s): %s\n", r.Local().Format("2006-01-02 15:04"), r.Message))
+		return true
+	})
 
 	for {
-		color.Green("You: ")
-		input, err := reader.ReadString('\n')
+		input, err := chatEditor.ReadLine()
+		if err == lineedit.ErrInterrupted {
+			continue
+		}
+		if err == io.EOF {
+			color.Yellow("Goodbye!")
+			shutdown()
+			return nil
+		}
 		if err != nil {
 			return fmt.Errorf("failed to read input: %w", err)
 		}
@@ -154,17 +295,37 @@ func runChat(cmd *cobra.Command, args []string) error {
 
 		// Process with agent
 		color.Yellow("Thinking...")
-		response, err := agt.Chat(input)
+		turnMu.Lock()
+		response, err := agt.ChatWithEvents(input, &agent.Events{Approve: approveTool})
+		turnMu.Unlock()
 		if err != nil {
 			color.Red("\nError: %v\n", err)
 			continue
 		}
 
 		fmt.Print("\r") // Clear "Thinking..."
-		color.Cyan("AI: %s\n\n", response)
+		printResponse(response)
 	}
 }
 
+// startChatScheduler runs scheduled tasks in the background of the chat
+// and prints their replies
+func startChatScheduler() error {
+	sched, err := newScheduler(runLocalTask)
+	if err != nil {
+		return err
+	}
+	sched.OnFinish = func(task *scheduler.Task, output string, err error) {
+		if err != nil {
+			chatPrint(color.RedString("\n[task %s] Error: %v\n", task.Name, err))
+			return
+		}
+		chatPrint(color.MagentaString("\n[task %s] %s\n", task.Name, output))
+	}
+	go sched.Start(context.Background())
+	return nil
+}
+
 func handleCommand(cmd string) error {
 	parts := strings.Fields(cmd)
 	if len(parts) == 0 {
@@ -174,9 +335,7 @@ func handleCommand(cmd string) error {
 	switch parts[0] {
 	case "/quit", "/exit":
 		color.Yellow("Goodbye!")
-		if mem != nil {
-			mem.Close()
-		}
+		shutdown()
 		os.Exit(0)
 
 	case "/clear":
@@ -185,6 +344,9 @@ func handleCommand(cmd string) error {
 		}
 		color.Yellow("Conversation history cleared.")
 
+	case "/copy":
+		copyCodeBlock(parts[1:])
+
 	case "/help":
 		color.Yellow("\nAvailable Tools:")
 		for _, tool := range toolReg.List() {
@@ -194,7 +356,7 @@ func handleCommand(cmd string) error {
 
 	default:
 		color.Yellow("Unknown command: %s", parts[0])
-		color.Yellow("Available: /quit, /clear, /help")
+		color.Yellow("Available: /quit, /clear, /copy, /help")
 	}
 
 	return nil
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/user/goclaw2/internal/mcp"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Model Context Protocol commands",
+	// stdout carries the protocol, so warnings printed while loading the
+	// configuration must go to stderr
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		color.Output = os.Stderr
+		return prerun(cmd, args)
+	},
+}
+
+var mcpServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve GoClaw tools and memory over MCP (stdio)",
+	Long: `Run an MCP server on stdin/stdout that exposes the GoClaw tools
+listed in mcp.serve.tools (file tools, memory_search, memory_get,
+update_memory by default) and the conversation history and workspace
+memory files as resources. Nobody can approve tool calls over MCP, so tools
+in agent.approval_tools are left out unless mcp.serve.allow_approval_tools
+is set.`,
+	RunE: runMCPServe,
+}
+
+func init() {
+	mcpCmd.AddCommand(mcpServeCmd)
+}
+
+func runMCPServe(cmd *cobra.Command, args []string) error {
+	defer shutdown()
+
+	server := &mcp.Server{
+		Tools:        toolReg,
+		AllowedTools: mcpServeTools(),
+		Sessions:     mem,
+		WorkspaceDir: cfg.Memory.Workspace,
+		Info:         mcp.Implementation{Name: "goclaw", Version: "0.1.0"},
+	}
+	return server.Serve(os.Stdin, os.Stdout)
+}
+
+// mcpServeTools returns mcp.serve.tools without the approval tools, unless
+// those are allowed explicitly
+func mcpServeTools() []string {
+	approval := make(map[string]bool)
+	if !cfg.MCP.Serve.AllowApprovalTools {
+		for _, name := range cfg.Agent.ApprovalTools {
+			approval[name] = true
+		}
+	}
+	var allowed []string
+	for _, name := range cfg.MCP.Serve.Tools {
+		if !approval[name] {
+			allowed = append(allowed, name)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/user/goclaw2/internal/lineedit"
+	"github.com/user/goclaw2/internal/markdown"
+	"github.com/user/goclaw2/internal/provider/zhipu"
+)
+
+// chatCommands are the slash commands offered by tab completion
+var chatCommands = []string{"/clear", "/copy", "/exit", "/help", "/quit"}
+
+// chatEditor reads chat input; output from background tasks goes through
+// it so the prompt being typed is redrawn below
+var chatEditor *lineedit.Editor
+
+var (
+	chatPlain    bool   // --plain: print responses as written
+	lastResponse string // Source of /copy
+)
+
+// newChatEditor sets up the prompt with the history from chat.history_file
+func newChatEditor() *lineedit.Editor {
+	e := lineedit.New(os.Stdin, os.Stdout)
+	e.Prompt = color.GreenString("You: ")
+	e.ContPrompt = color.GreenString(" ... ")
+	e.Complete = completeChat
+
+	history, err := lineedit.LoadHistory(cfg.Chat.HistoryFile, cfg.Chat.HistorySize)
+	if err != nil {
+		color.Yellow("Warning: %v", err)
+	}
+	e.History = history
+	return e
+}
+
+// completeChat completes slash commands at the start of the input and
+// file paths anywhere else
+func completeChat(line []rune, pos int) (int, []string) {
+	start := lineedit.WordStart(line, pos)
+	word := string(line[start:pos])
+	if start == 0 && strings.HasPrefix(word, "/") {
+		var matches []string
+		for _, c := range chatCommands {
+			if strings.HasPrefix(c, word) {
+				matches = append(matches, c)
+			}
+		}
+		if len(matches) > 0 {
+			return start, matches
+		}
+	}
+	if word == "" {
+		return start, nil
+	}
+	return start, lineedit.PathCandidates(word)
+}
+
+// chatPrint writes output that arrives while the user may be typing
+func chatPrint(text string) {
+	if chatEditor == nil {
+		fmt.Print(text)
+		return
+	}
+	chatEditor.Print(text)
+}
+
+// approveTool asks on the terminal before a tool listed in
+// agent.approval_tools runs. The answer is kept out of the history.
+func approveTool(call zhipu.ToolCall) bool {
+	prompt, history, complete := chatEditor.Prompt, chatEditor.History, chatEditor.Complete
+	defer func() {
+		chatEditor.Prompt, chatEditor.History, chatEditor.Complete = prompt, history, complete
+	}()
+	chatEditor.Prompt = color.YellowString("Run %s %s? [y/N] ", call.Function.Name, truncateLine(call.Function.Arguments, 200))
+	chatEditor.History = nil
+	chatEditor.Complete = nil
+
+	answer, err := chatEditor.ReadLine()
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// printResponse shows an answer as rendered markdown, or as written with
+// --plain or when stdout is not a terminal
+func printResponse(response string) {
+	lastResponse = response
+	if chatPlain || !lineedit.IsTerminal(int(os.Stdout.Fd())) {
+		color.Cyan("AI: %s\n\n", response)
+		return
+	}
+	color.Cyan("AI:")
+	fmt.Printf("%s\n\n", renderMarkdown(response))
+}
+
+// renderMarkdown formats text for the width of stdout
+func renderMarkdown(text string) string {
+	return markdown.Render(text, markdown.Options{Width: lineedit.TerminalWidth(int(os.Stdout.Fd()))})
+}
+
+// copyCodeBlock handles "/copy [n] <file>", saving code block n of the
+// last answer, or its last block, to file
+func copyCodeBlock(args []string) {
+	blocks := markdown.CodeBlocks(lastResponse)
+	if len(blocks) == 0 {
+		color.Yellow("The last answer has no code blocks.")
+		return
+	}
+
+	n := len(blocks)
+	var path string
+	switch len(args) {
+	case 1:
+		path = args[0]
+	case 2:
+		i, err := strconv.Atoi(args[0])
+		if err != nil || i < 1 || i > len(blocks) {
+			color.Yellow("Code block number must be between 1 and %d.", len(blocks))
+			return
+		}
+		n, path = i, args[1]
+	default:
+		color.Yellow("Usage: /copy [n] <file>  (the last answer has %d code blocks)", len(blocks))
+		return
+	}
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+
+	code := blocks[n-1].Code
+	if !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+	if err := os.WriteFile(path, []byte(code), 0644); err != nil {
+		color.Red("Failed to save code block: %v", err)
+		return
+	}
+	color.Yellow("Saved code block %d (%d lines) to %s", n, strings.Count(code, "\n"), path)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/user/goclaw2/internal/gateway"
+	"github.com/user/goclaw2/internal/scheduler"
+)
+
+var (
+	serveHost string
+	servePort int
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Start the HTTP gateway",
+	Long: `Serve the agent over HTTP:
+
+  GET    /                                web chat UI
+  GET    /api/tools                       list tools
+  GET    /api/sessions                    list sessions
+  GET    /api/sessions/{id}/messages      read history (?limit=N)
+  POST   /api/sessions/{id}/messages      send {"message": "..."}
+  DELETE /api/sessions/{id}/messages      clear a session
+  GET    /api/memory                      read MEMORY.md
+  PUT    /api/memory                      replace MEMORY.md {"content": "..."}
+  GET    /ws?session={id}                 WebSocket with streamed events
+  POST   /v1/chat/completions             OpenAI-compatible chat (session from
+                                          X-GoClaw-Session header or "user")
+  GET    /v1/models                       OpenAI-compatible model list
+  POST   /hooks/{name}                    signed webhook event (gateway.webhooks)`,
+	RunE: runServe,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveHost, "host", "", "listen host (default: gateway.host)")
+	serveCmd.Flags().IntVar(&servePort, "port", 0, "listen port (default: gateway.port)")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	defer shutdown()
+
+	host, port := cfg.Gateway.Host, cfg.Gateway.Port
+	if serveHost != "" {
+		host = serveHost
+	}
+	if servePort != 0 {
+		port = servePort
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server, err := gateway.New(cfg, mem, toolReg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize gateway: %w", err)
+	}
+	if !cfg.Gateway.Auth {
+		color.Yellow("Warning: gateway.auth is off; anyone who can reach %s can run tools on this host", addr)
+	} else if ok, err := server.Keys().HasActive(); err == nil && !ok {
+		color.Yellow("Warning: no API keys exist; create one with `goclaw keys create --name <name>`")
+	}
+	// Scheduled tasks go through the gateway so they queue behind API
+	// requests to the same session
+	schedDone := make(chan struct{})
+	close(schedDone)
+	if cfg.Scheduler.Enabled {
+		sched, err := newScheduler(func(task *scheduler.Task) (string, error) {
+			return server.RunPrompt(task.Session, task.Prompt)
+		})
+		if err != nil {
+			color.Yellow("Warning: scheduled tasks disabled: %v", err)
+		} else {
+			schedDone = make(chan struct{})
+			go func() {
+				defer close(schedDone)
+				sched.Start(ctx)
+			}()
+		}
+	}
+
+	// Reminders wait until a WebSocket client is connected to receive them
+	go reminders.Deliver(ctx, server.NotifyReminder)
+
+	color.Cyan("GoClaw gateway listening on http://%s", addr)
+	err = server.ListenAndServe(ctx, addr)
+	stop()
+	<-schedDone
+	if err != nil {
+		return fmt.Errorf("gateway failed: %w", err)
+	}
+	color.Yellow("Gateway stopped.")
+	return nil
+}
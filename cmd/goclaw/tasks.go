@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/user/goclaw2/internal/agent"
+	"github.com/user/goclaw2/internal/scheduler"
+)
+
+var (
+	taskName     string
+	taskSchedule string
+	taskSession  string
+	taskLimit    int
+)
+
+var tasksCmd = &cobra.Command{
+	Use:   "tasks",
+	Short: "Manage scheduled tasks",
+	Long: `Scheduled tasks run a prompt against a session while goclaw chat or
+goclaw serve is running. Schedules are cron expressions in
+scheduler.timezone ("0 8 * * 1-5"), macros (@hourly, @daily, @weekly,
+@monthly) or intervals ("@every 30m").`,
+}
+
+var tasksAddCmd = &cobra.Command{
+	Use:   "add <prompt>",
+	Short: "Add a scheduled task",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runTasksAdd,
+}
+
+var tasksListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List scheduled tasks",
+	RunE:  runTasksList,
+}
+
+var tasksRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a scheduled task and its history",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runTasksRemove,
+}
+
+var tasksRunNowCmd = &cobra.Command{
+	Use:   "run-now <name>",
+	Short: "Run a task immediately",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runTasksRunNow,
+}
+
+var tasksHistoryCmd = &cobra.Command{
+	Use:   "history [name]",
+	Short: "Show recent task runs",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runTasksHistory,
+}
+
+func init() {
+	tasksAddCmd.Flags().StringVar(&taskName, "name", "", "task name (required)")
+	tasksAddCmd.Flags().StringVar(&taskSchedule, "schedule", "", `when to run, e.g. "0 8 * * *" or "@every 1h" (required)`)
+	tasksAddCmd.Flags().StringVar(&taskSession, "session", "default", "session the prompt is sent to")
+	tasksAddCmd.MarkFlagRequired("name")
+	tasksAddCmd.MarkFlagRequired("schedule")
+	tasksHistoryCmd.Flags().IntVar(&taskLimit, "limit", 20, "number of runs to show")
+
+	tasksCmd.AddCommand(tasksAddCmd, tasksListCmd, tasksRemoveCmd, tasksRunNowCmd, tasksHistoryCmd)
+}
+
+// schedulerLocation returns the zone cron expressions are evaluated in
+func schedulerLocation() (*time.Location, error) {
+	if cfg.Scheduler.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(cfg.Scheduler.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scheduler.timezone: %w", err)
+	}
+	return loc, nil
+}
+
+// newScheduler opens the task store, syncs the tasks from the config file
+// and returns a scheduler that runs tasks with run
+func newScheduler(run scheduler.RunFunc) (*scheduler.Scheduler, error) {
+	loc, err := schedulerLocation()
+	if err != nil {
+		return nil, err
+	}
+	store, err := scheduler.NewStore(mem.DB())
+	if err != nil {
+		return nil, err
+	}
+	if run != nil {
+		// Without a turn limit a run still going cannot be told from one
+		// whose process died
+		if cfg.Agent.TurnTimeout <= 0 {
+			return nil, fmt.Errorf("running scheduled tasks needs agent.turn_timeout above 0")
+		}
+		store.SetTurnTimeout(cfg.Agent.TurnTimeout)
+	}
+
+	tasks := make([]scheduler.Task, len(cfg.Scheduler.Tasks))
+	for i, t := range cfg.Scheduler.Tasks {
+		tasks[i] = scheduler.Task{Name: t.Name, Schedule: t.Schedule, Session: t.Session, Prompt: t.Prompt}
+		if tasks[i].Session == "" {
+			tasks[i].Session = "default"
+		}
+		if err := tasks[i].Validate(loc); err != nil {
+			return nil, fmt.Errorf("invalid scheduler.tasks entry: %w", err)
+		}
+	}
+	if err := store.SyncConfig(tasks); err != nil {
+		return nil, err
+	}
+
+	return &scheduler.Scheduler{Store: store, Location: loc, Run: run}, nil
+}
+
+// Agent turns in this process are serialized: the chat loop and scheduled
+// tasks share the memory store and, for the chat's session, the agent
+var (
+	turnMu      sync.Mutex
+	localAgents = map[string]*agent.Agent{}
+)
+
+// runLocalTask runs a task with an agent of this process. Tasks are set up
+// by the user, so their approval_tools run without asking.
+func runLocalTask(task *scheduler.Task) (string, error) {
+	turnMu.Lock()
+	defer turnMu.Unlock()
+
+	a := agt
+	if task.Session != mem.SessionID() {
+		a = localAgents[task.Session]
+		if a == nil {
+			a = agent.New(cfg, mem.Session(task.Session), toolReg)
+			localAgents[task.Session] = a
+		}
+	}
+	return a.ChatWithEvents(task.Prompt, &agent.Events{SkipApproval: true})
+}
+
+func runTasksAdd(cmd *cobra.Command, args []string) error {
+	loc, err := schedulerLocation()
+	if err != nil {
+		return err
+	}
+	sched, err := newScheduler(nil)
+	if err != nil {
+		return err
+	}
+
+	task := scheduler.Task{
+		Name:     taskName,
+		Schedule: taskSchedule,
+		Session:  taskSession,
+		Prompt:   strings.Join(args, " "),
+		Source:   scheduler.SourceCLI,
+	}
+	if err := task.Validate(loc); err != nil {
+		return err
+	}
+	if _, err := sched.Store.Add(task); err != nil {
+		return err
+	}
+
+	schedule, _ := scheduler.Parse(task.Schedule, loc)
+	color.Green("Added task %s, next run %s", task.Name, schedule.Next(time.Now()).Format("2006-01-02 15:04 MST"))
+	return nil
+}
+
+func runTasksList(cmd *cobra.Command, args []string) error {
+	sched, err := newScheduler(nil)
+	if err != nil {
+		return err
+	}
+	tasks, err := sched.Store.List()
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		color.Yellow("No tasks. Add one with `goclaw tasks add --name <name> --schedule <cron> <prompt>`.")
+		return nil
+	}
+
+	fmt.Printf("%-20s %-16s %-12s %-7s %-20s %s\n", "NAME", "SCHEDULE", "SESSION", "SOURCE", "NEXT RUN", "LAST RUN")
+	for _, t := range tasks {
+		next := "invalid schedule"
+		if schedule, err := scheduler.Parse(t.Schedule, sched.Location); err == nil {
+			next = "never"
+			if t := schedule.Next(time.Now()); !t.IsZero() {
+				next = t.Format("2006-01-02 15:04")
+			}
+		}
+		last := "never"
+		if runs, err := sched.Store.Runs(t.Name, 1); err == nil && len(runs) > 0 {
+			last = runs[0].StartedAt.Format("2006-01-02 15:04") + " " + runs[0].Status
+		}
+		fmt.Printf("%-20s %-16s %-12s %-7s %-20s %s\n", t.Name, t.Schedule, t.Session, t.Source, next, last)
+		color.White("    %s", truncateLine(t.Prompt, 100))
+	}
+	return nil
+}
+
+func runTasksRemove(cmd *cobra.Command, args []string) error {
+	sched, err := newScheduler(nil)
+	if err != nil {
+		return err
+	}
+	if err := sched.Store.Remove(args[0]); err != nil {
+		return err
+	}
+	color.Green("Removed task %s", args[0])
+	return nil
+}
+
+func runTasksRunNow(cmd *cobra.Command, args []string) error {
+	sched, err := newScheduler(runLocalTask)
+	if err != nil {
+		return err
+	}
+	task, err := sched.Store.Get(args[0])
+	if err != nil {
+		return err
+	}
+	if task == nil {
+		return fmt.Errorf("no task named %s", args[0])
+	}
+
+	color.Yellow("Running %s in session %s...", task.Name, task.Session)
+	output, ran, err := sched.RunNow(task)
+	if !ran && err == nil {
+		return fmt.Errorf("task %s is already running", task.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("task %s failed: %w", task.Name, err)
+	}
+	color.Cyan("%s\n", output)
+	return nil
+}
+
+func runTasksHistory(cmd *cobra.Command, args []string) error {
+	sched, err := newScheduler(nil)
+	if err != nil {
+		return err
+	}
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+	runs, err := sched.Store.Runs(name, taskLimit)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		color.Yellow("No runs yet")
+		return nil
+	}
+
+	for _, r := range runs {
+		duration := "running"
+		if r.FinishedAt != nil {
+			duration = r.FinishedAt.Sub(r.StartedAt).Round(time.Second).String()
+		}
+		line := fmt.Sprintf("%s  %-20s %-8s %-8s", r.StartedAt.Format("2006-01-02 15:04:05"), r.TaskName, r.Status, duration)
+		switch r.Status {
+		case scheduler.StatusOK:
+			color.Green("%s", line)
+			color.White("    %s", truncateLine(r.Output, 100))
+		case scheduler.StatusError:
+			color.Red("%s", line)
+			color.White("    %s", truncateLine(r.Error, 100))
+		default:
+			color.Yellow("%s", line)
+		}
+	}
+	return nil
+}
+
+// truncateLine flattens s to one line of at most n runes
+func truncateLine(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n]) + "..."
+	}
+	return s
+}
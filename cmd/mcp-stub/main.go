@@ -0,0 +1,103 @@
+// mcp-stub is a minimal stdio MCP server for trying out the MCP client:
+//
+//	mcp:
+//	  servers:
+//	    - name: stub
+//	      command: ["go", "run", "./cmd/mcp-stub"]
+//
+// It offers an echo tool and an add tool.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type request struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+var stubTools = []map[string]interface{}{
+	{
+		"name":        "echo",
+		"description": "Return the given text unchanged",
+		"inputSchema": map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"text": map[string]interface{}{"type": "string"}},
+			"required":   []string{"text"},
+		},
+	},
+	{
+		"name":        "add",
+		"description": "Add two numbers",
+		"inputSchema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"a": map[string]interface{}{"type": "number"},
+				"b": map[string]interface{}{"type": "number"},
+			},
+			"required": []string{"a", "b"},
+		},
+	},
+}
+
+func main() {
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	out := json.NewEncoder(os.Stdout)
+
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil || req.ID == nil {
+			continue // Notifications need no reply
+		}
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "initialize":
+			resp["result"] = map[string]interface{}{
+				"protocolVersion": "2024-11-05",
+				"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+				"serverInfo":      map[string]interface{}{"name": "mcp-stub", "version": "0.1.0"},
+			}
+		case "ping":
+			resp["result"] = map[string]interface{}{}
+		case "tools/list":
+			resp["result"] = map[string]interface{}{"tools": stubTools}
+		case "tools/call":
+			resp["result"] = callTool(req.Params)
+		default:
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found: " + req.Method}
+		}
+		out.Encode(resp)
+	}
+}
+
+func callTool(params json.RawMessage) map[string]interface{} {
+	var call struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	}
+	json.Unmarshal(params, &call)
+
+	var text string
+	isError := false
+	switch call.Name {
+	case "echo":
+		text = fmt.Sprint(call.Arguments["text"])
+	case "add":
+		a, _ := call.Arguments["a"].(float64)
+		b, _ := call.Arguments["b"].(float64)
+		text = fmt.Sprint(a + b)
+	default:
+		text, isError = "unknown tool: "+call.Name, true
+	}
+	return map[string]interface{}{
+		"content": []map[string]interface{}{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
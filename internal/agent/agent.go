@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -21,22 +22,48 @@ type Agent struct {
 	tools         *tools.Registry
 	maxHistory    int
 	contextLoader *ContextLoader
+
+	maxParallelTools int
+	maxToolRounds    int
+	maxRepeatedCalls int
+	turnTimeout      time.Duration
+	outputLimit      *tools.OutputLimiter
+	approvalTools    map[string]bool
 }
 
 // New creates a new agent
 func New(cfg *config.Config, mem *memory.Store, toolRegistry *tools.Registry) *Agent {
-	return &Agent{
+	a := &Agent{
 		cfg:           cfg,
 		client:        zhipu.New(cfg),
 		memory:        mem,
 		tools:         toolRegistry,
 		maxHistory:    cfg.Agent.MaxHistory,
 		contextLoader: NewContextLoader(cfg.Memory.Workspace),
+
+		maxParallelTools: cfg.Agent.MaxParallelTools,
+		maxToolRounds:    cfg.Agent.MaxToolRounds,
+		maxRepeatedCalls: cfg.Agent.MaxRepeatedCalls,
+		turnTimeout:      cfg.Agent.TurnTimeout,
+		outputLimit: &tools.OutputLimiter{
+			MaxBytes: cfg.Tools.OutputMaxBytes,
+			SpillDir: cfg.Tools.SpillDir,
+		},
+		approvalTools: make(map[string]bool),
+	}
+	for _, name := range cfg.Agent.ApprovalTools {
+		a.approvalTools[name] = true
 	}
+	return a
 }
 
 // Chat processes a user message and returns the response
 func (a *Agent) Chat(userMessage string) (string, error) {
+	return a.ChatWithEvents(userMessage, nil)
+}
+
+// ChatWithEvents is Chat that reports progress to ev, which may be nil
+func (a *Agent) ChatWithEvents(userMessage string, ev *Events) (string, error) {
 	// Store user message
 	if err := a.memory.Add("user", userMessage); err != nil {
 		return "", fmt.Errorf("failed to store user message: %w", err)
@@ -61,14 +88,18 @@ func (a *Agent) Chat(userMessage string) (string, error) {
 可用工具：
 - read_file: 读取文件内容
 - write_file: 写入文件
+- edit_file: 修改文件的局部内容（搜索替换、行范围替换或应用 diff）
 - list_dir: 列出目录内容
+- grep_files: 用正则表达式搜索文件内容
+- find_files: 按 glob 模式查找文件
 - exec_command: 执行 shell 命令
 
 重要规则：
 1. 当用户请求读取、写入、列出文件或执行命令时，必须调用相应的工具
 2. 不要猜测文件内容，使用 read_file 工具读取
-3. 在总结文件操作结果时要准确详细
-4. 执行命令前要确认命令的安全性`
+3. 修改已有文件时优先使用 edit_file，不要用 write_file 重写整个文件
+4. 在总结文件操作结果时要准确详细
+5. 执行命令前要确认命令的安全性`
 
 	systemContent := baseSystemPrompt
 	if contextPrompt != "" {
@@ -108,29 +139,54 @@ func (a *Agent) Chat(userMessage string) (string, error) {
 	}
 
 	// Get available tools
-	toolList := a.tools.List()
-	providerTools := make([]zhipu.Tool, len(toolList))
-	for i, tool := range toolList {
-		providerTools[i] = zhipu.Tool{
+	providerTools := []zhipu.Tool{}
+	for _, tool := range a.tools.List() {
+		if !ev.allows(tool.Name()) {
+			continue
+		}
+		providerTools = append(providerTools, zhipu.Tool{
 			Type: "function",
 			Function: zhipu.ToolFunction{
 				Name:        tool.Name(),
 				Description: tool.Description(),
 				Parameters:  tool.Parameters(),
 			},
-		}
+		})
+	}
+
+	// turn_timeout bounds the model requests and tool runs of the turn, not
+	// only the number of rounds started
+	ctx := context.Background()
+	var deadline time.Time
+	if a.turnTimeout > 0 {
+		deadline = time.Now().Add(a.turnTimeout)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
 	}
 
 	// Make API call with tools
-	resp, err := a.client.ChatWithTools(providerMessages, providerTools)
+	resp, err := a.complete(ctx, providerMessages, providerTools, ev)
 	if err != nil {
 		return "", fmt.Errorf("API call failed: %w", err)
 	}
 
-	// Handle tool calls
+	// Handle tool calls, bounded by the loop guard
+	guard := newLoopGuard(a.maxToolRounds, a.maxRepeatedCalls, deadline)
+	var response string
 	for resp.HasToolCalls() {
 		toolCalls := resp.GetToolCalls()
 
+		if reason := guard.nextRound(); reason != "" {
+			response = a.finishEarly(providerMessages, guard.rounds, reason, ev)
+			break
+		}
+		blocked := guard.blocked(toolCalls)
+		if len(blocked) == len(toolCalls) {
+			response = a.finishEarly(providerMessages, guard.rounds-1, "the model kept repeating identical tool calls", ev)
+			break
+		}
+
 		// Add assistant response with tool calls to history
 		assistantMsg := zhipu.Message{
 			Role:      "assistant",
@@ -139,35 +195,24 @@ func (a *Agent) Chat(userMessage string) (string, error) {
 		}
 		providerMessages = append(providerMessages, assistantMsg)
 
-		// Execute each tool call
-		for _, toolCall := range toolCalls {
-			toolName := toolCall.Function.Name
-			toolArgs := toolCall.Function.Arguments
-
-			// Execute tool
-			result, err := a.tools.ExecuteToolCall(toolName, toolArgs)
-			if err != nil {
-				result = fmt.Sprintf("Error: %s", err)
-			}
-
-			// Add tool result to history
-			toolMsg := zhipu.Message{
-				Role:    "tool",
-				Content: result,
-				ToolID:  toolCall.ID,
-			}
-			providerMessages = append(providerMessages, toolMsg)
-		}
+		// Execute tool calls and add their results to history in call order
+		providerMessages = append(providerMessages, a.executeToolCalls(ctx, toolCalls, blocked, ev)...)
 
 		// Make another API call with tool results
-		resp, err = a.client.ChatWithTools(providerMessages, providerTools)
+		resp, err = a.complete(ctx, providerMessages, providerTools, ev)
+		if err != nil && ctx.Err() != nil {
+			response = a.finishEarly(providerMessages, guard.rounds, "ran out of time for this turn", ev)
+			break
+		}
 		if err != nil {
 			return "", fmt.Errorf("API call after tool execution failed: %w", err)
 		}
 	}
 
-	// Get final response
-	response := resp.GetContent()
+	// Get final response, unless the loop was stopped early
+	if resp != nil && !resp.HasToolCalls() {
+		response = resp.GetContent()
+	}
 
 	// Store assistant response
 	if err := a.memory.Add("assistant", response); err != nil {
@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/user/goclaw2/internal/provider/zhipu"
+)
+
+// Events lets a caller follow a Chat turn as it happens. Every field is
+// optional.
+type Events struct {
+	// OnDelta receives response text as it streams in. Setting it
+	// switches the model requests to streaming.
+	OnDelta func(text string)
+
+	// OnToolStart and OnToolFinish bracket each executed tool call. They
+	// may be called concurrently when read-only tools run in parallel.
+	OnToolStart  func(call zhipu.ToolCall)
+	OnToolFinish func(call zhipu.ToolCall, result string, failed bool)
+
+	// Approve is asked before running a tool listed in
+	// agent.approval_tools; the call is skipped when it returns false.
+	// Without Approve those tools are refused unless SkipApproval is set.
+	Approve func(call zhipu.ToolCall) bool
+
+	// SkipApproval runs approval_tools without asking. Only trusted
+	// callers, such as scheduled tasks, set it.
+	SkipApproval bool
+
+	// KeyID is the gateway API key the turn runs for. Tools that keep data
+	// per caller, such as reminders, separate it by key and session.
+	KeyID int64
+
+	// AllowTool restricts the tools offered to the model and refuses
+	// calls to the others. Without it every registered tool is allowed.
+	AllowTool func(name string) bool
+
+	// OnUsage receives the token usage of every model request
+	OnUsage func(usage zhipu.Usage)
+}
+
+func (ev *Events) allows(tool string) bool {
+	return ev == nil || ev.AllowTool == nil || ev.AllowTool(tool)
+}
+
+// complete sends one model request, streaming when the caller listens
+// for deltas. A nil tools list requests a plain answer.
+func (a *Agent) complete(ctx context.Context, messages []zhipu.Message, tools []zhipu.Tool, ev *Events) (*zhipu.ChatResponse, error) {
+	req := &zhipu.ChatRequest{Messages: messages, Tools: tools}
+	var resp *zhipu.ChatResponse
+	var err error
+	if ev != nil && ev.OnDelta != nil {
+		resp, err = a.client.ChatStreamContext(ctx, req, ev.OnDelta)
+	} else {
+		resp, err = a.client.ChatContext(ctx, req)
+	}
+	if err == nil && ev != nil && ev.OnUsage != nil {
+		ev.OnUsage(resp.Usage)
+	}
+	return resp, err
+}
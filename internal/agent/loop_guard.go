@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/user/goclaw2/internal/provider/zhipu"
+)
+
+// finishEarlyTimeout bounds the final request made after the guard stopped
+// the loop, which may be after the turn's own deadline passed
+const finishEarlyTimeout = time.Minute
+
+// loopGuard bounds the tool-calling loop of a single Chat turn
+type loopGuard struct {
+	maxRounds  int       // 0 for unlimited
+	maxRepeats int       // How often an identical call may run; 0 for unlimited
+	deadline   time.Time // Zero for no wall-clock budget
+
+	rounds int
+	seen   map[string]int
+}
+
+func newLoopGuard(maxRounds, maxRepeats int, deadline time.Time) *loopGuard {
+	return &loopGuard{
+		maxRounds:  maxRounds,
+		maxRepeats: maxRepeats,
+		deadline:   deadline,
+		seen:       make(map[string]int),
+	}
+}
+
+// nextRound is called before executing a round of tool calls. It returns
+// a non-empty reason when the loop must stop instead.
+func (g *loopGuard) nextRound() string {
+	if g.maxRounds > 0 && g.rounds >= g.maxRounds {
+		return fmt.Sprintf("reached the limit of %d tool rounds", g.maxRounds)
+	}
+	if !g.deadline.IsZero() && time.Now().After(g.deadline) {
+		return "ran out of time for this turn"
+	}
+	g.rounds++
+	return ""
+}
+
+// blocked records the calls of a round and returns the ones that repeat an
+// identical earlier call too often, mapped to the error sent back to the
+// model instead of running them
+func (g *loopGuard) blocked(toolCalls []zhipu.ToolCall) map[int]string {
+	if g.maxRepeats <= 0 {
+		return nil
+	}
+
+	result := map[int]string{}
+	for i, call := range toolCalls {
+		key := call.Function.Name + "\x00" + call.Function.Arguments
+		g.seen[key]++
+		if g.seen[key] > g.maxRepeats {
+			result[i] = fmt.Sprintf("Error: %s was already called with these exact arguments %d times in this turn. "+
+				"Do not repeat it; use the earlier result or try a different approach.", call.Function.Name, g.maxRepeats)
+		}
+	}
+	return result
+}
+
+// finishEarly asks the model for a final answer without tools after the
+// guard stopped the loop, and notes why the answer may be incomplete
+func (a *Agent) finishEarly(messages []zhipu.Message, rounds int, reason string, ev *Events) string {
+	note := fmt.Sprintf("[stopped after %d tool steps: %s]", rounds, reason)
+
+	messages = append(messages, zhipu.Message{
+		Role: "user",
+		Content: fmt.Sprintf("[系统] 工具调用已停止（%s）。请不要再调用工具，"+
+			"根据目前已获得的信息直接给出最终回答，并说明还有哪些未完成的部分。", reason),
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), finishEarlyTimeout)
+	defer cancel()
+	resp, err := a.complete(ctx, messages, nil, ev)
+	if err != nil || resp.GetContent() == "" {
+		return note
+	}
+	return resp.GetContent() + "\n\n" + note
+}
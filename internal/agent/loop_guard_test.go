@@ -0,0 +1,135 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/user/goclaw2/internal/provider/zhipu"
+	"github.com/user/goclaw2/internal/tools"
+)
+
+func TestLoopGuard(t *testing.T) {
+	g := newLoopGuard(2, 0, time.Time{})
+	for i := 1; i <= 2; i++ {
+		if reason := g.nextRound(); reason != "" {
+			t.Fatalf("round %d stopped: %s", i, reason)
+		}
+	}
+	if reason := g.nextRound(); reason != "reached the limit of 2 tool rounds" {
+		t.Errorf("third round: %q", reason)
+	}
+
+	g = newLoopGuard(0, 0, time.Now().Add(-time.Second))
+	if reason := g.nextRound(); reason != "ran out of time for this turn" {
+		t.Errorf("past deadline: %q", reason)
+	}
+
+	g = newLoopGuard(0, 0, time.Time{})
+	for i := 0; i < 100; i++ {
+		if reason := g.nextRound(); reason != "" {
+			t.Fatalf("unlimited guard stopped: %s", reason)
+		}
+	}
+}
+
+func TestLoopGuardBlocked(t *testing.T) {
+	g := newLoopGuard(0, 2, time.Time{})
+	a := toolCall("1", "read_file", `{"path": "a"}`)
+	b := toolCall("2", "read_file", `{"path": "b"}`)
+
+	rounds := []struct {
+		calls []zhipu.ToolCall
+		want  []int
+	}{
+		{[]zhipu.ToolCall{a, b}, nil},
+		{[]zhipu.ToolCall{a}, nil},
+		{[]zhipu.ToolCall{b, a}, []int{1}},
+		{[]zhipu.ToolCall{b, b}, []int{0, 1}},
+	}
+	for n, round := range rounds {
+		blocked := g.blocked(round.calls)
+		if len(blocked) != len(round.want) {
+			t.Errorf("round %d: blocked %v, want indexes %v", n+1, blocked, round.want)
+			continue
+		}
+		for _, i := range round.want {
+			if !strings.Contains(blocked[i], "already called with these exact arguments 2 times") {
+				t.Errorf("round %d: call %d: %q", n+1, i, blocked[i])
+			}
+		}
+	}
+
+	if blocked := newLoopGuard(0, 0, time.Time{}).blocked([]zhipu.ToolCall{a, a, a}); blocked != nil {
+		t.Errorf("unlimited repeats blocked %v", blocked)
+	}
+}
+
+// loopingModel keeps calling read with args(n) for request n and answers
+// "summary" once tools are withdrawn
+func loopingModel(args func(n int) string) func(req zhipu.ChatRequest) zhipu.Message {
+	var requests int32
+	return func(req zhipu.ChatRequest) zhipu.Message {
+		n := atomic.AddInt32(&requests, 1)
+		if len(req.Tools) == 0 {
+			return zhipu.Message{Role: "assistant", Content: "summary"}
+		}
+		return zhipu.Message{Role: "assistant", ToolCalls: []zhipu.ToolCall{
+			toolCall(fmt.Sprintf("call-%d", n), "read", args(int(n))),
+		}}
+	}
+}
+
+func TestChatStopsRunawayLoops(t *testing.T) {
+	distinct := func(n int) string { return fmt.Sprintf(`{"id": "%d"}`, n) }
+	same := func(int) string { return `{"id": "same"}` }
+
+	tests := []struct {
+		name   string
+		config string
+		args   func(n int) string
+		want   string
+	}{
+		{"round limit", "  max_tool_rounds: 3\n  max_repeated_calls: 0", distinct,
+			"summary\n\n[stopped after 3 tool steps: reached the limit of 3 tool rounds]"},
+		{"repeated calls", "  max_tool_rounds: 0\n  max_repeated_calls: 2", same,
+			"summary\n\n[stopped after 2 tool steps: the model kept repeating identical tool calls]"},
+		{"turn timeout", "  max_tool_rounds: 0\n  max_repeated_calls: 0\n  turn_timeout: 150ms", distinct,
+			"summary\n\n[stopped after"},
+	}
+	for _, tt := range tests {
+		log := &traceLog{}
+		reg := tools.New()
+		reg.Register(&traceTool{name: "read", log: log})
+		a := newTestAgent(t, reg, loopingModel(tt.args), tt.config)
+
+		start := time.Now()
+		got, err := a.Chat("go")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !strings.HasPrefix(got, tt.want) {
+			t.Errorf("%s: response %q, want %q", tt.name, got, tt.want)
+		}
+		if tt.name == "turn timeout" {
+			if !strings.HasSuffix(got, "ran out of time for this turn]") {
+				t.Errorf("%s: response %q", tt.name, got)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("%s: turn took %s", tt.name, elapsed)
+			}
+		}
+
+		// The stopped answer is what the session remembers
+		history, err := a.GetMemory().GetHistory(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last := history[len(history)-1]; last.Role != "assistant" || last.Content != got {
+			t.Errorf("%s: last stored message %+v", tt.name, last)
+		}
+	}
+}
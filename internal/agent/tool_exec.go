@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/user/goclaw2/internal/provider/zhipu"
+	"github.com/user/goclaw2/internal/tools"
+)
+
+// executeToolCalls runs the tool calls of one model response and returns
+// the tool result messages in the same order as the calls. Calls listed in
+// blocked are not run; their message is returned as the result instead.
+//
+// Consecutive parallel-safe calls (e.g. several read_file calls) run
+// concurrently, up to maxParallelTools at a time. Any other call runs on
+// its own, after everything before it has finished, so a write is always
+// visible to the reads the model issued after it. Calls not started before
+// ctx is done are not run.
+func (a *Agent) executeToolCalls(ctx context.Context, toolCalls []zhipu.ToolCall, blocked map[int]string, ev *Events) []zhipu.Message {
+	results := make([]zhipu.Message, len(toolCalls))
+	for i, msg := range blocked {
+		results[i] = zhipu.Message{Role: "tool", Content: msg, ToolID: toolCalls[i].ID}
+	}
+
+	for start := 0; start < len(toolCalls); {
+		end := start + 1
+		if a.tools.IsParallelSafe(toolCalls[start].Function.Name) {
+			for end < len(toolCalls) && a.tools.IsParallelSafe(toolCalls[end].Function.Name) {
+				end++
+			}
+		}
+
+		a.runToolBatch(ctx, toolCalls[start:end], results[start:end], blocked, start, ev)
+		start = end
+	}
+
+	return results
+}
+
+// runToolBatch executes calls concurrently, writing each result to the
+// matching index of results. offset is the index of calls[0] in the round.
+func (a *Agent) runToolBatch(ctx context.Context, calls []zhipu.ToolCall, results []zhipu.Message, blocked map[int]string, offset int, ev *Events) {
+	workers := a.maxParallelTools
+	if workers < 1 {
+		workers = 1
+	}
+
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i := range calls {
+		if _, skip := blocked[offset+i]; skip {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = a.executeToolCall(ctx, calls[i], ev)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// executeToolCall runs one tool call and wraps its result, capped by the
+// output limiter, as a tool message
+func (a *Agent) executeToolCall(ctx context.Context, toolCall zhipu.ToolCall, ev *Events) zhipu.Message {
+	if ev == nil {
+		ev = &Events{}
+	}
+
+	var result string
+	var err error
+	if ctx.Err() != nil {
+		err = fmt.Errorf("the turn ran out of time before %s could run", toolCall.Function.Name)
+	} else if !ev.allows(toolCall.Function.Name) {
+		err = fmt.Errorf("tool %s is not available to this caller", toolCall.Function.Name)
+	} else if a.approvalTools[toolCall.Function.Name] && !ev.SkipApproval && ev.Approve == nil {
+		err = fmt.Errorf("tool %s needs approval, which this caller cannot give", toolCall.Function.Name)
+	} else if a.approvalTools[toolCall.Function.Name] && !ev.SkipApproval && !ev.Approve(toolCall) {
+		err = fmt.Errorf("the user denied this %s call; do not retry it without asking", toolCall.Function.Name)
+	} else {
+		if ev.OnToolStart != nil {
+			ev.OnToolStart(toolCall)
+		}
+		caller := tools.Caller{Session: a.memory.SessionID(), KeyID: ev.KeyID, Ctx: ctx}
+		result, err = a.tools.ExecuteToolCallAs(caller, toolCall.Function.Name, toolCall.Function.Arguments)
+	}
+	if err != nil {
+		result = fmt.Sprintf("Error: %s", err)
+	}
+	result = a.outputLimit.Limit(toolCall.Function.Name, result)
+	if ev.OnToolFinish != nil {
+		ev.OnToolFinish(toolCall, result, err != nil)
+	}
+	return zhipu.Message{
+		Role:    "tool",
+		Content: result,
+		ToolID:  toolCall.ID,
+	}
+}
@@ -0,0 +1,176 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/goclaw2/internal/config"
+	"github.com/user/goclaw2/internal/memory"
+	"github.com/user/goclaw2/internal/provider/zhipu"
+	"github.com/user/goclaw2/internal/tools"
+)
+
+// scriptedModel serves chat completions, answering each request with reply
+func scriptedModel(t *testing.T, reply func(req zhipu.ChatRequest) zhipu.Message) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req zhipu.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(zhipu.ChatResponse{
+			ID:      "x",
+			Choices: []zhipu.Choice{{Message: reply(req), FinishReason: "stop"}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestAgent creates an agent using reg and talking to reply, with
+// agentConfig as the body of the agent section
+func newTestAgent(t *testing.T, reg *tools.Registry, reply func(req zhipu.ChatRequest) zhipu.Message, agentConfig string) *Agent {
+	t.Helper()
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "goclaw.yaml")
+	err := os.WriteFile(cfgFile, []byte(fmt.Sprintf(`
+zhipu:
+  api_key: "test"
+  base_url: %q
+agent:
+  approval_tools: []
+%s
+memory:
+  file_path: %q
+  workspace: %q
+`, scriptedModel(t, reply).URL, agentConfig, filepath.Join(dir, "goclaw.db"), filepath.Join(dir, "ws"))), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem, err := memory.New(cfg.Memory.FilePath, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(cfg, mem, reg)
+}
+
+func toolCall(id, name, args string) zhipu.ToolCall {
+	call := zhipu.ToolCall{ID: id, Type: "function"}
+	call.Function.Name = name
+	call.Function.Arguments = args
+	return call
+}
+
+// traceTool records when each call starts and ends in a shared log
+type traceTool struct {
+	name     string
+	parallel bool
+	log      *traceLog
+}
+
+type traceLog struct {
+	mu      sync.Mutex
+	events  []string
+	running int
+	peak    int
+}
+
+func (t *traceTool) Name() string        { return t.name }
+func (t *traceTool) Description() string { return "Record the call" }
+func (t *traceTool) ParallelSafe() bool  { return t.parallel }
+
+func (t *traceTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"id": map[string]interface{}{"type": "string"}},
+	}
+}
+
+func (t *traceTool) Execute(args map[string]interface{}) (string, error) {
+	id := fmt.Sprint(args["id"])
+	l := t.log
+	l.mu.Lock()
+	l.events = append(l.events, "start "+id)
+	l.running++
+	if l.running > l.peak {
+		l.peak = l.running
+	}
+	l.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	l.mu.Lock()
+	l.events = append(l.events, "end "+id)
+	l.running--
+	l.mu.Unlock()
+	return "result " + id, nil
+}
+
+func TestExecuteToolCallsBatches(t *testing.T) {
+	log := &traceLog{}
+	reg := tools.New()
+	reg.Register(&traceTool{name: "read", parallel: true, log: log})
+	reg.Register(&traceTool{name: "write", log: log})
+	a := newTestAgent(t, reg, nil, "  max_parallel_tools: 2")
+
+	calls := []zhipu.ToolCall{
+		toolCall("c1", "read", `{"id": "r1"}`),
+		toolCall("c2", "read", `{"id": "r2"}`),
+		toolCall("c3", "read", `{"id": "r3"}`),
+		toolCall("c4", "write", `{"id": "w1"}`),
+		toolCall("c5", "read", `{"id": "r4"}`),
+		toolCall("c6", "read", `{"id": "r5"}`),
+		toolCall("c7", "missing", `{}`),
+	}
+	blocked := map[int]string{5: "Error: blocked"}
+
+	var finished []string
+	var mu sync.Mutex
+	ev := &Events{OnToolFinish: func(call zhipu.ToolCall, _ string, _ bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		finished = append(finished, call.ID)
+	}}
+	results := a.executeToolCalls(context.Background(), calls, blocked, ev)
+
+	// Results keep the order of the calls
+	want := []string{"result r1", "result r2", "result r3", "result w1", "result r4", "Error: blocked", "Error: "}
+	for i, msg := range results {
+		if msg.Role != "tool" || msg.ToolID != calls[i].ID || !strings.HasPrefix(msg.Content, want[i]) {
+			t.Errorf("result %d = %+v, want %q for %s", i, msg, want[i], calls[i].ID)
+		}
+	}
+	if len(finished) != 6 {
+		t.Errorf("OnToolFinish called for %v, want every call but the blocked one", finished)
+	}
+
+	// Reads overlap up to max_parallel_tools; the write runs alone after
+	// every earlier read and before every later one
+	if log.peak != 2 {
+		t.Errorf("peak concurrency %d, want 2", log.peak)
+	}
+	trace := strings.Join(log.events, ",")
+	writeAt := strings.Index(trace, "start w1")
+	for _, id := range []string{"r1", "r2", "r3"} {
+		if end := strings.Index(trace, "end "+id); end < 0 || end > writeAt {
+			t.Errorf("%s had not finished before the write: %s", id, trace)
+		}
+	}
+	if start := strings.Index(trace, "start r4"); start < strings.Index(trace, "end w1") {
+		t.Errorf("r4 started before the write finished: %s", trace)
+	}
+}
@@ -5,15 +5,20 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Zhipu    ZhipuConfig    `mapstructure:"zhipu"`
-	Agent    AgentConfig    `mapstructure:"agent"`
-	Memory   MemoryConfig   `mapstructure:"memory"`
-	Gateway  GatewayConfig  `mapstructure:"gateway"`
+	Zhipu     ZhipuConfig     `mapstructure:"zhipu"`
+	Agent     AgentConfig     `mapstructure:"agent"`
+	Memory    MemoryConfig    `mapstructure:"memory"`
+	Gateway   GatewayConfig   `mapstructure:"gateway"`
+	Tools     ToolsConfig     `mapstructure:"tools"`
+	MCP       MCPConfig       `mapstructure:"mcp"`
+	Scheduler SchedulerConfig `mapstructure:"scheduler"`
+	Chat      ChatConfig      `mapstructure:"chat"`
 }
 
 type ZhipuConfig struct {
@@ -25,7 +30,12 @@ type ZhipuConfig struct {
 }
 
 type AgentConfig struct {
-	MaxHistory int `mapstructure:"max_history"`
+	MaxHistory       int           `mapstructure:"max_history"`
+	MaxParallelTools int           `mapstructure:"max_parallel_tools"` // Concurrent read-only tool calls per response
+	MaxToolRounds    int           `mapstructure:"max_tool_rounds"`    // Tool-calling rounds per turn, 0 for unlimited
+	MaxRepeatedCalls int           `mapstructure:"max_repeated_calls"` // Identical calls allowed per turn, 0 for unlimited
+	TurnTimeout      time.Duration `mapstructure:"turn_timeout"`       // Wall-clock budget per turn, 0 for unlimited
+	ApprovalTools    []string      `mapstructure:"approval_tools"`     // Tools that need approval; refused where nobody can give it
 }
 
 type MemoryConfig struct {
@@ -38,6 +48,91 @@ type GatewayConfig struct {
 	Enabled bool   `mapstructure:"enabled"`
 	Port    int    `mapstructure:"port"`
 	Host    string `mapstructure:"host"`
+	Auth    bool   `mapstructure:"auth"` // Require API keys (goclaw keys create)
+
+	// Browser origins besides the gateway's own that may open /ws, e.g.
+	// "https://tools.example.com"
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+
+	Webhooks []WebhookConfig `mapstructure:"webhooks"`
+}
+
+// WebhookConfig turns signed POSTs to /hooks/{name} into agent turns
+type WebhookConfig struct {
+	Name        string   `mapstructure:"name"`
+	Secret      string   `mapstructure:"secret"`       // HMAC-SHA256 key the sender signs the body with
+	Session     string   `mapstructure:"session"`      // Defaults to webhook-<name>
+	Template    string   `mapstructure:"template"`     // Go text/template producing the prompt
+	Tools       []string `mapstructure:"tools"`        // Tools the agent may use, empty for all but approval tools
+	CallbackURL string   `mapstructure:"callback_url"` // Where the reply is POSTed
+	OutputFile  string   `mapstructure:"output_file"`  // File the reply is appended to
+}
+
+// ToolsConfig controls what the built-in tools may access
+type ToolsConfig struct {
+	Roots []RootConfig `mapstructure:"roots"` // Directories the file tools may access
+	Deny  []string     `mapstructure:"deny"`  // Globs that are never accessible, e.g. ".env"
+	Exec  ExecConfig   `mapstructure:"exec"`
+
+	ReadMaxBytes   int    `mapstructure:"read_max_bytes"`   // Cap on read_file output, lowered to fit output_max_bytes
+	OutputMaxBytes int    `mapstructure:"output_max_bytes"` // Cap on any tool result sent to the model, 0 for unlimited
+	SpillDir       string `mapstructure:"spill_dir"`        // Where oversized results are saved, defaults to <workspace>/scratch
+	PluginDir      string `mapstructure:"plugin_dir"`       // Plugin tool manifests, defaults to <workspace>/plugins
+}
+
+type RootConfig struct {
+	Path     string `mapstructure:"path"`
+	ReadOnly bool   `mapstructure:"read_only"`
+}
+
+// ExecConfig controls how exec_command runs commands
+type ExecConfig struct {
+	Sandbox      bool   `mapstructure:"sandbox"`       // Run commands in Linux namespaces
+	Workspace    string `mapstructure:"workspace"`     // Directory mounted read-write in the sandbox
+	CPUSeconds   int    `mapstructure:"cpu_seconds"`   // 0 for unlimited
+	MemoryMB     int    `mapstructure:"memory_mb"`     // 0 for unlimited
+	MaxProcesses int    `mapstructure:"max_processes"` // 0 for unlimited
+}
+
+// MCPConfig lists Model Context Protocol servers whose tools are offered
+// to the model, and what `goclaw mcp serve` offers to other clients
+type MCPConfig struct {
+	Servers []MCPServerConfig `mapstructure:"servers"`
+	Serve   MCPServeConfig    `mapstructure:"serve"`
+}
+
+// MCPServeConfig controls the tools `goclaw mcp serve` exposes
+type MCPServeConfig struct {
+	Tools              []string `mapstructure:"tools"`                // Tools MCP clients may list and call
+	AllowApprovalTools bool     `mapstructure:"allow_approval_tools"` // Keep listed agent.approval_tools, which then run unapproved
+}
+
+type MCPServerConfig struct {
+	Name    string            `mapstructure:"name"`    // Namespace for the server's tools
+	Command []string          `mapstructure:"command"` // Executable and arguments of the stdio server
+	Env     map[string]string `mapstructure:"env"`     // Extra environment, $VARS are expanded
+	Timeout int               `mapstructure:"timeout"` // Seconds per request, default 60
+}
+
+// SchedulerConfig controls scheduled tasks
+type SchedulerConfig struct {
+	Enabled  bool         `mapstructure:"enabled"`  // Run due tasks while chat or serve is running
+	Timezone string       `mapstructure:"timezone"` // IANA zone for cron expressions, default local time
+	Tasks    []TaskConfig `mapstructure:"tasks"`    // Added to those created with `goclaw tasks add`
+}
+
+// TaskConfig is a prompt run against a session on a schedule
+type TaskConfig struct {
+	Name     string `mapstructure:"name"`
+	Schedule string `mapstructure:"schedule"` // Cron expression, @daily style macro or "@every 30m"
+	Session  string `mapstructure:"session"`  // Default "default"
+	Prompt   string `mapstructure:"prompt"`
+}
+
+// ChatConfig controls the interactive `goclaw chat` prompt
+type ChatConfig struct {
+	HistoryFile string `mapstructure:"history_file"` // Input history, empty disables saving it
+	HistorySize int    `mapstructure:"history_size"` // Entries kept in the history file
 }
 
 var globalConfig *Config
@@ -82,6 +177,20 @@ func Load(configPath string) (*Config, error) {
 
 	// Expand ~ in workspace path
 	cfg.Memory.Workspace = expandPath(cfg.Memory.Workspace)
+	if cfg.Tools.SpillDir == "" {
+		cfg.Tools.SpillDir = filepath.Join(cfg.Memory.Workspace, "scratch")
+	}
+	cfg.Tools.SpillDir = expandPath(cfg.Tools.SpillDir)
+	// A read_file page must fit under output_max_bytes with its notice,
+	// or paging a spilled result would spill it again
+	if max := cfg.Tools.OutputMaxBytes - readNoticeReserve; max > 0 && (cfg.Tools.ReadMaxBytes <= 0 || cfg.Tools.ReadMaxBytes > max) {
+		cfg.Tools.ReadMaxBytes = max
+	}
+	if cfg.Tools.PluginDir == "" {
+		cfg.Tools.PluginDir = filepath.Join(cfg.Memory.Workspace, "plugins")
+	}
+	cfg.Tools.PluginDir = expandPath(cfg.Tools.PluginDir)
+	cfg.Chat.HistoryFile = expandPath(cfg.Chat.HistoryFile)
 
 	// Validate
 	if err := validate(&cfg); err != nil {
@@ -92,18 +201,45 @@ func Load(configPath string) (*Config, error) {
 	return &cfg, nil
 }
 
+// readNoticeReserve is left under output_max_bytes for the notice read_file
+// appends to a truncated page
+const readNoticeReserve = 1024
+
 func setDefaults(v *viper.Viper) {
 	v.SetDefault("zhipu.base_url", "https://open.bigmodel.cn/api/paas/v4")
 	v.SetDefault("zhipu.model", "glm-4-flash")
 	v.SetDefault("zhipu.temperature", 0.7)
 	v.SetDefault("zhipu.max_tokens", 4096)
 	v.SetDefault("agent.max_history", 50)
+	v.SetDefault("agent.max_parallel_tools", 4)
+	v.SetDefault("agent.max_tool_rounds", 20)
+	v.SetDefault("agent.max_repeated_calls", 3)
+	v.SetDefault("agent.turn_timeout", "5m")
+	v.SetDefault("agent.approval_tools", []string{"exec_command", "write_file", "edit_file"})
 	v.SetDefault("memory.type", "sqlite")
 	v.SetDefault("memory.file_path", "./goclaw.db")
 	v.SetDefault("memory.workspace", "~/.goclaw/workspace")
 	v.SetDefault("gateway.enabled", false)
 	v.SetDefault("gateway.port", 8080)
 	v.SetDefault("gateway.host", "localhost")
+	v.SetDefault("gateway.auth", true)
+	v.SetDefault("scheduler.enabled", true)
+	v.SetDefault("chat.history_file", "~/.goclaw/history")
+	v.SetDefault("chat.history_size", 1000)
+	v.SetDefault("mcp.serve.tools", []string{"read_file", "write_file", "edit_file", "list_dir", "grep_files", "find_files",
+		"memory_search", "memory_get", "update_memory"})
+	v.SetDefault("mcp.serve.allow_approval_tools", false)
+	v.SetDefault("tools.roots", []map[string]interface{}{
+		{"path": ".", "read_only": false},
+	})
+	v.SetDefault("tools.read_max_bytes", 31*1024)
+	v.SetDefault("tools.output_max_bytes", 32*1024)
+	v.SetDefault("tools.exec.sandbox", false)
+	v.SetDefault("tools.exec.workspace", ".")
+	v.SetDefault("tools.exec.cpu_seconds", 30)
+	v.SetDefault("tools.exec.memory_mb", 1024)
+	v.SetDefault("tools.exec.max_processes", 256)
+	v.SetDefault("tools.deny", []string{".env", ".env.*", "*.pem", "*.key", "id_rsa*", "id_ed25519*"})
 }
 
 func validate(cfg *Config) error {
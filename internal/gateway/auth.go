@@ -0,0 +1,227 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/goclaw2/internal/agent"
+	"github.com/user/goclaw2/internal/provider/zhipu"
+)
+
+type contextKey int
+
+const apiKeyContextKey contextKey = iota
+
+// anonymousKey is used for every request when authentication is disabled
+var anonymousKey = &APIKey{Name: "anonymous", Scopes: []string{ScopeAdmin}}
+
+// systemKey runs turns the host starts itself, such as scheduled tasks
+var systemKey = &APIKey{Name: "system", Scopes: []string{ScopeAdmin}}
+
+// authenticate resolves the bearer token of r to a key and applies its
+// rate limit. Browsers cannot set headers on WebSocket requests, so the
+// token may also be passed as ?token=.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if !s.authEnabled {
+		return r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, anonymousKey)), true
+	}
+
+	token := ""
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	} else if r.URL.Path == "/ws" {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="goclaw"`)
+		writeError(w, http.StatusUnauthorized, "missing API key: send Authorization: Bearer <key>")
+		return nil, false
+	}
+
+	key, err := s.keys.Lookup(token)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to check API key: %v", err)
+		return nil, false
+	}
+	if key == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="goclaw", error="invalid_token"`)
+		writeError(w, http.StatusUnauthorized, "invalid or revoked API key")
+		return nil, false
+	}
+	if !s.limiter.allow(key.ID, key.RateLimit) {
+		w.Header().Set("Retry-After", "60")
+		writeError(w, http.StatusTooManyRequests, "rate limit of %d requests per minute exceeded", key.RateLimit)
+		return nil, false
+	}
+	if err := s.keys.RecordUse(key.ID, 0); err != nil {
+		log.Printf("gateway: failed to record use of API key %d: %v", key.ID, err)
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, key)), true
+}
+
+// requireScope returns the request's key if it grants scope, otherwise it
+// writes a 403
+func requireScope(w http.ResponseWriter, r *http.Request, scope string) (*APIKey, bool) {
+	key, _ := r.Context().Value(apiKeyContextKey).(*APIKey)
+	if key == nil || !key.HasScope(scope) {
+		writeError(w, http.StatusForbidden, "API key lacks the %q scope", scope)
+		return nil, false
+	}
+	return key, true
+}
+
+// runTurn runs one agent turn for key in the given session, restricted to
+// the key's tools and counted against its token quota. ev may be nil.
+func (s *Server) runTurn(key *APIKey, sessionID, text string, ev *agent.Events) (string, zhipu.Usage, error) {
+	var usage zhipu.Usage
+	// WebSockets hold their key for as long as they stay open, so stored
+	// keys are read again for the current usage and revocation
+	if key.ID != 0 {
+		current, err := s.keys.Get(key.ID)
+		if err != nil {
+			return "", usage, err
+		}
+		if current == nil {
+			return "", usage, errKeyRevoked
+		}
+		key = current
+	}
+	if key.QuotaExceeded() {
+		return "", usage, errQuotaExceeded
+	}
+	if err := s.checkSession(key, sessionID, true); err != nil {
+		return "", usage, err
+	}
+
+	turn := agent.Events{}
+	if ev != nil {
+		turn = *ev
+	}
+	turn.AllowTool = key.AllowsTool
+	turn.KeyID = key.ID
+	turn.OnUsage = func(u zhipu.Usage) {
+		usage.PromptTokens += u.PromptTokens
+		usage.CompletionTokens += u.CompletionTokens
+		usage.TotalTokens += u.TotalTokens
+	}
+
+	sess := s.session(sessionID)
+	sess.mu.Lock()
+	response, err := sess.agent.ChatWithEvents(text, &turn)
+	sess.mu.Unlock()
+
+	if key.ID != 0 && usage.TotalTokens > 0 {
+		if err := s.keys.RecordUse(key.ID, usage.TotalTokens); err != nil {
+			log.Printf("gateway: failed to record %d tokens for API key %d: %v", usage.TotalTokens, key.ID, err)
+		}
+	}
+	return response, usage, err
+}
+
+// RunPrompt runs one agent turn in a session on behalf of the host, such
+// as a scheduled task, with every tool allowed and approval_tools run
+// without asking. It shares the per-session locking with API requests.
+func (s *Server) RunPrompt(sessionID, prompt string) (string, error) {
+	response, _, err := s.runTurn(systemKey, sessionID, prompt, &agent.Events{SkipApproval: true})
+	return response, err
+}
+
+// errQuotaExceeded is returned by runTurn when the key has no tokens left
+var errQuotaExceeded = errors.New("token quota of this API key is exhausted")
+
+// errKeyRevoked is returned by runTurn when the key was revoked after the
+// connection authenticated
+var errKeyRevoked = errors.New("API key has been revoked")
+
+// errSessionForbidden is returned when a session belongs to another key
+var errSessionForbidden = errors.New("session belongs to another API key")
+
+// checkSession refuses key access to a session another key owns, unless
+// key is an admin key. With claim set, a session nobody owns becomes key's.
+// Sessions that have history but no owner, such as the CLI's, belong to
+// the host (key 0).
+func (s *Server) checkSession(key *APIKey, sessionID string, claim bool) error {
+	owner, owned, err := s.keys.SessionOwner(sessionID)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		count, err := s.store.Session(sessionID).Count()
+		if err != nil {
+			return fmt.Errorf("failed to read session: %w", err)
+		}
+		claimant := key.ID
+		if count > 0 {
+			claimant = 0
+		}
+		if count > 0 || claim {
+			if owner, err = s.keys.ClaimSession(sessionID, claimant); err != nil {
+				return err
+			}
+			owned = true
+		}
+	}
+	if owned && owner != key.ID && !key.HasScope(ScopeAdmin) {
+		return errSessionForbidden
+	}
+	return nil
+}
+
+// rateLimiter counts requests per key in fixed one-minute windows.
+// Windows of keys that have gone quiet are dropped as new ones start.
+type rateLimiter struct {
+	mu        sync.Mutex
+	windows   map[int64]*rateWindow
+	lastPrune time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{windows: make(map[int64]*rateWindow)}
+}
+
+func (l *rateLimiter) allow(keyID int64, perMinute int) bool {
+	if perMinute <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	w, ok := l.windows[keyID]
+	if !ok || now.Sub(w.start) >= time.Minute {
+		l.prune(now)
+		w = &rateWindow{start: now}
+		l.windows[keyID] = w
+	}
+	if w.count >= perMinute {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// prune drops expired windows, at most once a minute so a busy gateway
+// does not scan the map on every request
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+	for id, w := range l.windows {
+		if now.Sub(w.start) >= time.Minute {
+			delete(l.windows, id)
+		}
+	}
+}
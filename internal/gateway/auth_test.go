@@ -0,0 +1,142 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getWithAuth sends a GET with the given Authorization header, if any, and
+// returns the response and its body
+func getWithAuth(t *testing.T, srv *httptest.Server, path, authorization string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest("GET", srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, srv, token := newTestGateway(t)
+	revokedKey, revoked, err := s.Keys().Create(APIKey{Name: "revoked", Scopes: []string{ScopeChat}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Keys().Revoke(revokedKey.Prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, path, authorization string
+		want                      int
+		wantBody                  string
+	}{
+		{"missing key", "/api/tools", "", http.StatusUnauthorized, "missing API key"},
+		{"not a bearer token", "/api/tools", "Basic " + token, http.StatusUnauthorized, "missing API key"},
+		{"query token outside /ws", "/api/tools?token=" + token, "", http.StatusUnauthorized, "missing API key"},
+		{"unknown key", "/api/tools", "Bearer gck_nope", http.StatusUnauthorized, "invalid or revoked API key"},
+		{"revoked key", "/api/tools", "Bearer " + revoked, http.StatusUnauthorized, "invalid or revoked API key"},
+		{"valid key", "/api/tools", "Bearer " + token, http.StatusOK, `"shout"`},
+		{"missing scope", "/api/sessions", "Bearer " + token, http.StatusForbidden, `lacks the \"admin\" scope`},
+	}
+	for _, tt := range tests {
+		resp, body := getWithAuth(t, srv, tt.path, tt.authorization)
+		if resp.StatusCode != tt.want || !strings.Contains(body, tt.wantBody) {
+			t.Errorf("%s: %d %s, want %d with %q", tt.name, resp.StatusCode, body, tt.want, tt.wantBody)
+		}
+		if tt.want == http.StatusUnauthorized && !strings.HasPrefix(resp.Header.Get("WWW-Authenticate"), "Bearer ") {
+			t.Errorf("%s: WWW-Authenticate %q", tt.name, resp.Header.Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestKeyLimits(t *testing.T) {
+	s, srv, _ := newTestGateway(t)
+
+	// Rate limits count every request of the key
+	limited := createKey(t, s, APIKey{Name: "limited", Scopes: []string{ScopeChat}, RateLimit: 2})
+	for i := 1; i <= 3; i++ {
+		resp, body := getWithAuth(t, srv, "/api/tools", "Bearer "+limited)
+		switch {
+		case i <= 2 && resp.StatusCode != http.StatusOK:
+			t.Errorf("request %d: %d %s", i, resp.StatusCode, body)
+		case i == 3 && (resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "60"):
+			t.Errorf("request over the limit: %d, Retry-After %q: %s", resp.StatusCode, resp.Header.Get("Retry-After"), body)
+		}
+	}
+
+	// An exhausted quota refuses turns but still allows reading
+	spentKey, spent, err := s.Keys().Create(APIKey{Name: "spent", Scopes: []string{ScopeChat}, TokenQuota: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Keys().RecordUse(spentKey.ID, 100); err != nil {
+		t.Fatal(err)
+	}
+	const messages = "/api/sessions/q/messages"
+	if status, body := apiRequest(t, srv, "POST", messages, spent, `{"message": "hi"}`); status != http.StatusTooManyRequests ||
+		!strings.Contains(body, errQuotaExceeded.Error()) {
+		t.Errorf("turn over quota: %d %s", status, body)
+	}
+	if status, body := apiRequest(t, srv, "GET", messages, spent, ""); status != http.StatusOK {
+		t.Errorf("reading over quota: %d %s", status, body)
+	}
+
+	// Tool restrictions hide the other tools and refuse calls to them
+	restricted := createKey(t, s, APIKey{Name: "restricted", Scopes: []string{ScopeChat}, Tools: []string{"read_file"}})
+	if _, body := getWithAuth(t, srv, "/api/tools", "Bearer "+restricted); body != "{\"tools\":[]}\n" {
+		t.Errorf("restricted tools: %s", body)
+	}
+	status, body := apiRequest(t, srv, "POST", "/api/sessions/r/messages", restricted, `{"message": "hi"}`)
+	if status != http.StatusOK || !strings.Contains(body, "tool shout is not available to this caller") {
+		t.Errorf("restricted turn: %d %s", status, body)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter()
+	for i := 0; i < 5; i++ {
+		if !l.allow(1, 0) {
+			t.Fatal("unlimited key was limited")
+		}
+	}
+	if !l.allow(2, 1) || l.allow(2, 1) {
+		t.Error("limit of 1 per minute not applied")
+	}
+	if !l.allow(3, 1) {
+		t.Error("keys share a window")
+	}
+
+	// A window that has run for a minute starts over, and quiet keys'
+	// windows are dropped
+	past := time.Now().Add(-2 * time.Minute)
+	l.windows[2].start = past
+	l.windows[3].start = past
+	l.lastPrune = past
+	if !l.allow(2, 1) {
+		t.Error("expired window still limits")
+	}
+	if _, ok := l.windows[3]; ok {
+		t.Error("expired window of a quiet key was kept")
+	}
+
+	// Pruning runs at most once a minute
+	l.windows[4] = &rateWindow{start: past}
+	l.prune(time.Now())
+	if _, ok := l.windows[4]; !ok {
+		t.Error("pruned twice within a minute")
+	}
+}
@@ -0,0 +1,258 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Key scopes
+const (
+	ScopeChat  = "chat"  // Send messages and read history in its own sessions, list tools
+	ScopeAdmin = "admin" // Everything, including listing and clearing sessions
+)
+
+// keyPrefix marks GoClaw API keys so they are recognizable in configs and logs
+const keyPrefix = "gck_"
+
+// APIKey is a stored gateway key. Only the SHA-256 hash of the secret is kept.
+type APIKey struct {
+	ID         int64
+	Name       string
+	Prefix     string   // First characters of the secret, for display
+	Scopes     []string // ScopeChat, ScopeAdmin
+	Tools      []string // Allowed tools, empty for all
+	RateLimit  int      // Requests per minute, 0 for unlimited
+	TokenQuota int      // Total model tokens, 0 for unlimited
+	TokensUsed int
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// HasScope reports whether the key grants scope; admin grants everything
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsTool reports whether the key may use the named tool
+func (k *APIKey) AllowsTool(name string) bool {
+	if len(k.Tools) == 0 {
+		return true
+	}
+	for _, t := range k.Tools {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// QuotaExceeded reports whether the key has used up its token quota
+func (k *APIKey) QuotaExceeded() bool {
+	return k.TokenQuota > 0 && k.TokensUsed >= k.TokenQuota
+}
+
+// KeyStore keeps API keys in SQLite
+type KeyStore struct {
+	db *sql.DB
+}
+
+// NewKeyStore creates the api_keys table in db if needed
+func NewKeyStore(db *sql.DB) (*KeyStore, error) {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		hash TEXT NOT NULL UNIQUE,
+		scopes TEXT NOT NULL,
+		tools TEXT NOT NULL DEFAULT '',
+		rate_limit INTEGER NOT NULL DEFAULT 0,
+		token_quota INTEGER NOT NULL DEFAULT 0,
+		tokens_used INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		last_used_at DATETIME,
+		revoked_at DATETIME
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api_keys table: %w", err)
+	}
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS session_owners (
+		session TEXT PRIMARY KEY,
+		key_id INTEGER NOT NULL,
+		created_at DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session_owners table: %w", err)
+	}
+	return &KeyStore{db: db}, nil
+}
+
+// Create stores a new key and returns it with its secret. The secret is
+// not stored and cannot be shown again.
+func (s *KeyStore) Create(key APIKey) (*APIKey, string, error) {
+	for _, scope := range key.Scopes {
+		if scope != ScopeChat && scope != ScopeAdmin {
+			return nil, "", fmt.Errorf("unknown scope %q (use %s or %s)", scope, ScopeChat, ScopeAdmin)
+		}
+	}
+	if len(key.Scopes) == 0 {
+		return nil, "", fmt.Errorf("at least one scope is required")
+	}
+
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return nil, "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	secret := keyPrefix + hex.EncodeToString(random)
+	key.Prefix = secret[:len(keyPrefix)+8]
+	key.CreatedAt = time.Now()
+
+	result, err := s.db.Exec(`
+		INSERT INTO api_keys (name, prefix, hash, scopes, tools, rate_limit, token_quota, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		key.Name, key.Prefix, hashKey(secret), strings.Join(key.Scopes, ","), strings.Join(key.Tools, ","),
+		key.RateLimit, key.TokenQuota, key.CreatedAt)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to store key: %w", err)
+	}
+	key.ID, _ = result.LastInsertId()
+	return &key, secret, nil
+}
+
+// Lookup returns the active key for a secret, or nil when it is unknown
+// or revoked
+func (s *KeyStore) Lookup(secret string) (*APIKey, error) {
+	keys, err := s.query(`WHERE hash = ? AND revoked_at IS NULL`, hashKey(secret))
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return keys[0], nil
+}
+
+// Get returns the active key with the given ID, or nil when it has been
+// revoked
+func (s *KeyStore) Get(id int64) (*APIKey, error) {
+	keys, err := s.query(`WHERE id = ? AND revoked_at IS NULL`, id)
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return keys[0], nil
+}
+
+// List returns every key, including revoked ones
+func (s *KeyStore) List() ([]*APIKey, error) {
+	return s.query(`ORDER BY id`)
+}
+
+// HasActive reports whether any key can authenticate
+func (s *KeyStore) HasActive() (bool, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM api_keys WHERE revoked_at IS NULL`).Scan(&n)
+	return n > 0, err
+}
+
+// Revoke disables the key with the given ID or prefix
+func (s *KeyStore) Revoke(idOrPrefix string) error {
+	result, err := s.db.Exec(`
+		UPDATE api_keys SET revoked_at = ?
+		WHERE (CAST(id AS TEXT) = ? OR prefix = ?) AND revoked_at IS NULL`,
+		time.Now(), idOrPrefix, idOrPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to revoke key: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no active key with id or prefix %q", idOrPrefix)
+	}
+	return nil
+}
+
+// RecordUse updates the last use time and adds tokens to the key's usage
+func (s *KeyStore) RecordUse(id int64, tokens int) error {
+	_, err := s.db.Exec(`UPDATE api_keys SET last_used_at = ?, tokens_used = tokens_used + ? WHERE id = ?`,
+		time.Now(), tokens, id)
+	return err
+}
+
+// SessionOwner returns the ID of the key that owns session, and false when
+// no key has claimed it yet
+func (s *KeyStore) SessionOwner(session string) (int64, bool, error) {
+	var id int64
+	err := s.db.QueryRow(`SELECT key_id FROM session_owners WHERE session = ?`, session).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read session owner: %w", err)
+	}
+	return id, true, nil
+}
+
+// ClaimSession makes keyID the owner of session unless it already has
+// one, and returns the owner
+func (s *KeyStore) ClaimSession(session string, keyID int64) (int64, error) {
+	if _, err := s.db.Exec(`INSERT OR IGNORE INTO session_owners (session, key_id, created_at) VALUES (?, ?, ?)`,
+		session, keyID, time.Now()); err != nil {
+		return 0, fmt.Errorf("failed to claim session: %w", err)
+	}
+	id, _, err := s.SessionOwner(session)
+	return id, err
+}
+
+func (s *KeyStore) query(where string, args ...interface{}) ([]*APIKey, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, prefix, scopes, tools, rate_limit, token_quota, tokens_used,
+			created_at, last_used_at, revoked_at
+		FROM api_keys `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		var k APIKey
+		var scopes, tools string
+		var lastUsed, revoked sql.NullTime
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &scopes, &tools, &k.RateLimit, &k.TokenQuota,
+			&k.TokensUsed, &k.CreatedAt, &lastUsed, &revoked); err != nil {
+			return nil, fmt.Errorf("failed to read key: %w", err)
+		}
+		k.Scopes = splitList(scopes)
+		k.Tools = splitList(tools)
+		if lastUsed.Valid {
+			k.LastUsedAt = &lastUsed.Time
+		}
+		if revoked.Valid {
+			k.RevokedAt = &revoked.Time
+		}
+		keys = append(keys, &k)
+	}
+	return keys, rows.Err()
+}
+
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func splitList(s string) []string {
+	var result []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
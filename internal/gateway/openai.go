@@ -0,0 +1,225 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// openAIModel is the model name the facade reports and accepts
+const openAIModel = "goclaw"
+
+// streamKeepAlive is how often a streaming response that is waiting for
+// the turn to end sends a comment, so proxies do not drop it as idle
+const streamKeepAlive = 15 * time.Second
+
+// sessionHeader selects the GoClaw session on /v1 requests
+const sessionHeader = "X-GoClaw-Session"
+
+type openAIRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	User     string          `json:"user"`
+}
+
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// text returns the message content, which may be a string or an array of
+// content parts
+func (m openAIMessage) text() string {
+	var s string
+	if json.Unmarshal(m.Content, &s) == nil {
+		return s
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	json.Unmarshal(m.Content, &parts)
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// POST /v1/chat/completions
+//
+// GoClaw keeps the conversation itself, so only the last user message of
+// the request is used; earlier messages are assumed to be the client's
+// copy of the same history. The session comes from the X-GoClaw-Session
+// header, else the user field, else "default".
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	key, ok := requireScope(w, r, ScopeChat)
+	if !ok {
+		return
+	}
+	var req openAIRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<20)).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid JSON body: %v", err)
+		return
+	}
+
+	var prompt string
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			prompt = req.Messages[i].text()
+			break
+		}
+	}
+	if strings.TrimSpace(prompt) == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "messages must contain a non-empty user message")
+		return
+	}
+
+	id := r.Header.Get(sessionHeader)
+	if id == "" {
+		id = req.User
+	}
+	if id == "" {
+		id = "default"
+	}
+	if !sessionIDPattern.MatchString(id) {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid session id %q", id)
+		return
+	}
+
+	completionID := fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	created := time.Now().Unix()
+	if !req.Stream {
+		response, usage, err := s.runTurn(key, id, prompt, nil)
+		if err != nil {
+			writeOpenAIError(w, turnErrorStatus(err), "%v", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"id":      completionID,
+			"object":  "chat.completion",
+			"created": created,
+			"model":   openAIModel,
+			"choices": []map[string]interface{}{{
+				"index":         0,
+				"message":       map[string]string{"role": "assistant", "content": response},
+				"finish_reason": "stop",
+			}},
+			"usage": map[string]int{
+				"prompt_tokens":     usage.PromptTokens,
+				"completion_tokens": usage.CompletionTokens,
+				"total_tokens":      usage.TotalTokens,
+			},
+		})
+		return
+	}
+
+	// Check the quota and session before the stream starts so they can
+	// fail with a status
+	if key.QuotaExceeded() {
+		writeOpenAIError(w, http.StatusTooManyRequests, "%v", errQuotaExceeded)
+		return
+	}
+	if err := s.checkSession(key, id, true); err != nil {
+		writeOpenAIError(w, turnErrorStatus(err), "%v", err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeOpenAIError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	chunk := func(delta map[string]string, finish interface{}) {
+		data, _ := json.Marshal(map[string]interface{}{
+			"id":      completionID,
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   openAIModel,
+			"choices": []map[string]interface{}{{"index": 0, "delta": delta, "finish_reason": finish}},
+		})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+
+	// The answer is only known when the turn ends: text the model streams
+	// before a tool call, or in a request that runs out of time, is not part
+	// of it, and a stopped turn ends with a note. So the whole answer goes
+	// out as one chunk, the same content a non-streaming request returns,
+	// and comments keep the connection alive until then.
+	chunk(map[string]string{"role": "assistant"}, nil)
+	type turnResult struct {
+		response string
+		err      error
+	}
+	done := make(chan turnResult, 1)
+	go func() {
+		response, _, err := s.runTurn(key, id, prompt, nil)
+		done <- turnResult{response, err}
+	}()
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	var result turnResult
+	for waiting := true; waiting; {
+		select {
+		case result = <-done:
+			waiting = false
+		case <-ticker.C:
+			fmt.Fprint(w, ": working\n\n")
+			flusher.Flush()
+		}
+	}
+
+	if result.err != nil {
+		// Headers are sent; report the error in the stream
+		data, _ := json.Marshal(map[string]interface{}{"error": map[string]string{"message": result.err.Error(), "type": "server_error"}})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	} else {
+		if result.response != "" {
+			chunk(map[string]string{"content": result.response}, nil)
+		}
+		chunk(map[string]string{}, "stop")
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// GET /v1/models lists the single model the facade offers
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	if _, ok := requireScope(w, r, ScopeChat); !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data": []map[string]interface{}{{
+			"id":       openAIModel,
+			"object":   "model",
+			"created":  0,
+			"owned_by": "goclaw",
+		}},
+	})
+}
+
+// writeOpenAIError uses the error shape OpenAI clients expect
+func writeOpenAIError(w http.ResponseWriter, status int, format string, a ...interface{}) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": fmt.Sprintf(format, a...),
+			"type":    "invalid_request_error",
+		},
+	})
+}
@@ -0,0 +1,118 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// streamCompletion posts a streaming chat completion and returns the
+// content of its chunks, joined, and the finish reason of the last one
+func streamCompletion(t *testing.T, url, token, body string) (string, string) {
+	t.Helper()
+	req, err := http.NewRequest("POST", url+"/v1/chat/completions", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	var content strings.Builder
+	var finish string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data := strings.TrimPrefix(scanner.Text(), "data: ")
+		if data == scanner.Text() {
+			continue
+		}
+		if data == "[DONE]" {
+			return content.String(), finish
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta        map[string]string `json:"delta"`
+				FinishReason *string           `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil || len(chunk.Choices) != 1 {
+			t.Fatalf("chunk %s: %v", data, err)
+		}
+		content.WriteString(chunk.Choices[0].Delta["content"])
+		if chunk.Choices[0].FinishReason != nil {
+			finish = *chunk.Choices[0].FinishReason
+		}
+	}
+	t.Fatalf("stream ended without [DONE]: %v", scanner.Err())
+	return "", ""
+}
+
+func TestChatCompletions(t *testing.T) {
+	_, srv, token := newTestGateway(t)
+
+	// The text the model sends before its tool call is not part of the
+	// answer, streamed or not
+	const want = "done: Error: tool shout needs approval"
+	status, body := apiRequest(t, srv, "POST", "/v1/chat/completions", token,
+		`{"user": "plain", "messages": [{"role": "user", "content": "chatty hi"}]}`)
+	var completion struct {
+		Object  string `json:"object"`
+		Choices []struct {
+			Message struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal([]byte(body), &completion); status != http.StatusOK || err != nil || len(completion.Choices) != 1 {
+		t.Fatalf("completion: %d %s", status, body)
+	}
+	answer := completion.Choices[0].Message.Content
+	if completion.Object != "chat.completion" || completion.Choices[0].Message.Role != "assistant" ||
+		!strings.HasPrefix(answer, want) {
+		t.Errorf("completion %+v", completion)
+	}
+
+	streamed, finish := streamCompletion(t, srv.URL, token,
+		`{"user": "streamed", "stream": true, "messages": [{"role": "user", "content": "chatty hi"}]}`)
+	if streamed != answer || finish != "stop" {
+		t.Errorf("stream sent %q (%s), want %q", streamed, finish, answer)
+	}
+
+	// Only the last user message is used, and content parts are joined
+	status, body = apiRequest(t, srv, "POST", "/v1/chat/completions", token, `{"messages": [
+		{"role": "user", "content": "first"},
+		{"role": "assistant", "content": "ok"},
+		{"role": "user", "content": [{"type": "text", "text": "a"}, {"type": "image_url"}, {"type": "text", "text": "b"}]}]}`)
+	if status != http.StatusOK {
+		t.Errorf("content parts: %d %s", status, body)
+	}
+	if _, body := apiRequest(t, srv, "GET", "/api/sessions/default/messages", token, ""); !strings.Contains(body, `"content":"a\nb"`) {
+		t.Errorf("stored prompt: %s", body)
+	}
+
+	tests := []struct {
+		name, method, path, body string
+		want                     int
+	}{
+		{"models", "GET", "/v1/models", "", http.StatusOK},
+		{"bad JSON", "POST", "/v1/chat/completions", `{`, http.StatusBadRequest},
+		{"no user message", "POST", "/v1/chat/completions", `{"messages": [{"role": "system", "content": "x"}]}`, http.StatusBadRequest},
+		{"bad session", "POST", "/v1/chat/completions", `{"user": "a b", "messages": [{"role": "user", "content": "x"}]}`, http.StatusBadRequest},
+		{"wrong method", "GET", "/v1/chat/completions", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if status, body := apiRequest(t, srv, tt.method, tt.path, token, tt.body); status != tt.want {
+			t.Errorf("%s: %d, want %d: %s", tt.name, status, tt.want, body)
+		}
+	}
+}
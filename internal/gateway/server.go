@@ -0,0 +1,355 @@
+// Package gateway serves the agent over HTTP
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/goclaw2/internal/agent"
+	"github.com/user/goclaw2/internal/config"
+	"github.com/user/goclaw2/internal/memory"
+	"github.com/user/goclaw2/internal/tools"
+)
+
+// shutdownTimeout bounds how long in-flight requests may take to finish
+const shutdownTimeout = 30 * time.Second
+
+var sessionIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// Server is the HTTP gateway. Every session gets its own agent; requests
+// for the same session are serialized.
+type Server struct {
+	cfg   *config.Config
+	store *memory.Store
+	tools *tools.Registry
+	mux   *http.ServeMux
+
+	keys        *KeyStore
+	authEnabled bool
+	limiter     *rateLimiter
+
+	webhooks   map[string]*webhook
+	background sync.WaitGroup // Webhook turns still running
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	clients  map[*wsClient]bool // Connected WebSocket clients
+}
+
+type session struct {
+	mu    sync.Mutex // Held for a whole agent turn
+	agent *agent.Agent
+}
+
+// New creates a gateway backed by store and the tools in reg. API keys
+// are kept in the same database as the sessions.
+func New(cfg *config.Config, store *memory.Store, reg *tools.Registry) (*Server, error) {
+	keys, err := NewKeyStore(store.DB())
+	if err != nil {
+		return nil, err
+	}
+	webhooks, err := loadWebhooks(cfg.Gateway.Webhooks)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		cfg:         cfg,
+		store:       store,
+		tools:       reg,
+		mux:         http.NewServeMux(),
+		keys:        keys,
+		authEnabled: cfg.Gateway.Auth,
+		limiter:     newRateLimiter(),
+		webhooks:    webhooks,
+		sessions:    make(map[string]*session),
+		clients:     make(map[*wsClient]bool),
+	}
+	s.routes()
+	return s, nil
+}
+
+// Keys returns the API key store
+func (s *Server) Keys() *KeyStore {
+	return s.keys
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("/healthz", s.handleHealth)
+	s.mux.HandleFunc("/api/tools", s.handleTools)
+	s.mux.HandleFunc("/api/sessions", s.handleSessions)
+	s.mux.HandleFunc("/api/sessions/", s.handleSession)
+	s.mux.HandleFunc("/ws", s.handleWS)
+	s.mux.HandleFunc("/v1/chat/completions", s.handleChatCompletions)
+	s.mux.HandleFunc("/v1/models", s.handleModels)
+	s.mux.HandleFunc("/api/memory", s.handleMemory)
+	s.mux.HandleFunc("/hooks/", s.handleWebhook)
+	s.mux.Handle("/", uiHandler())
+}
+
+// ServeHTTP implements http.Handler. Everything except the health check,
+// the web UI's static files and webhooks (which are signed instead) needs
+// an API key when authentication is enabled.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isPublicPath(r.URL.Path) {
+		var ok bool
+		if r, ok = s.authenticate(w, r); !ok {
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves on addr until ctx is cancelled, then waits for
+// in-flight requests to finish
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve is ListenAndServe on an existing listener. On shutdown it also
+// waits for webhook turns that are still running.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	httpServer := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.Serve(listener)
+	}()
+	if s.authEnabled {
+		go s.watchRevokedKeys(ctx)
+	}
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		return fmt.Errorf("graceful shutdown failed: webhook turns still running")
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// session returns the session for id, creating its agent on first use
+func (s *Server) session(id string) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		sess = &session{agent: agent.New(s.cfg, s.store.Session(id), s.tools)}
+		s.sessions[id] = sess
+	}
+	return sess
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// GET /api/tools
+func (s *Server) handleTools(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	key, ok := requireScope(w, r, ScopeChat)
+	if !ok {
+		return
+	}
+	type toolInfo struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description"`
+		Parameters  map[string]interface{} `json:"parameters"`
+	}
+	list := s.tools.List()
+	result := make([]toolInfo, 0, len(list))
+	for _, tool := range list {
+		if key.AllowsTool(tool.Name()) {
+			result = append(result, toolInfo{tool.Name(), tool.Description(), tool.Parameters()})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tools": result})
+}
+
+// GET /api/sessions
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	if _, ok := requireScope(w, r, ScopeAdmin); !ok {
+		return
+	}
+	sessions, err := s.store.ListSessions()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list sessions: %v", err)
+		return
+	}
+	if sessions == nil {
+		sessions = []memory.SessionInfo{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sessions": sessions})
+}
+
+// /api/sessions/{id}/messages
+//
+//	GET    history, ?limit=N for the most recent N messages
+//	POST   {"message": "..."} runs one agent turn and returns the reply
+//	DELETE clears the session
+//
+// A session belongs to the key that first ran a turn in it; other chat
+// keys get 403 for it.
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/sessions/"), "/")
+	if len(parts) != 2 || parts[1] != "messages" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	id := parts[0]
+	if !sessionIDPattern.MatchString(id) {
+		writeError(w, http.StatusBadRequest, "invalid session id %q: use letters, digits, '.', '_' and '-'", id)
+		return
+	}
+
+	scope := ScopeChat
+	if r.Method == http.MethodDelete {
+		scope = ScopeAdmin
+	}
+	key, ok := requireScope(w, r, scope)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.getHistory(w, r, key, id)
+	case http.MethodPost:
+		s.postMessage(w, r, key, id)
+	case http.MethodDelete:
+		sess := s.session(id)
+		sess.mu.Lock()
+		err := sess.agent.ClearHistory()
+		sess.mu.Unlock()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to clear session: %v", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+	}
+}
+
+func (s *Server) getHistory(w http.ResponseWriter, r *http.Request, key *APIKey, id string) {
+	if err := s.checkSession(key, id, false); err != nil {
+		writeError(w, turnErrorStatus(err), "%v", err)
+		return
+	}
+
+	limit := -1
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	messages, err := s.store.Session(id).GetHistory(-1)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read history: %v", err)
+		return
+	}
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	if messages == nil {
+		messages = []memory.Message{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"session": id, "messages": messages})
+}
+
+func (s *Server) postMessage(w http.ResponseWriter, r *http.Request, key *APIKey, id string) {
+	var req struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: %v", err)
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		writeError(w, http.StatusBadRequest, "message must not be empty")
+		return
+	}
+
+	response, usage, err := s.runTurn(key, id, req.Message, nil)
+	if err != nil {
+		writeError(w, turnErrorStatus(err), "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"session": id, "response": response, "usage": usage})
+}
+
+// turnErrorStatus maps runTurn errors to HTTP status codes
+func turnErrorStatus(err error) int {
+	if errors.Is(err, errQuotaExceeded) {
+		return http.StatusTooManyRequests
+	}
+	if errors.Is(err, errKeyRevoked) {
+		return http.StatusUnauthorized
+	}
+	if errors.Is(err, errSessionForbidden) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadGateway
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, a ...interface{}) {
+	writeJSON(w, status, map[string]string{"error": fmt.Sprintf(format, a...)})
+}
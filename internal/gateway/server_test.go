@@ -0,0 +1,165 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// apiRequest sends an authenticated request and returns the status and body
+func apiRequest(t *testing.T, srv *httptest.Server, method, path, token, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func createKey(t *testing.T, s *Server, key APIKey) string {
+	t.Helper()
+	_, token, err := s.Keys().Create(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestSessionMessages(t *testing.T) {
+	s, srv, token := newTestGateway(t)
+	admin := createKey(t, s, APIKey{Name: "admin", Scopes: []string{ScopeAdmin}})
+
+	const messages = "/api/sessions/s1/messages"
+	status, body := apiRequest(t, srv, "POST", messages, token, `{"message": "hi"}`)
+	var reply struct {
+		Session  string `json:"session"`
+		Response string `json:"response"`
+	}
+	if err := json.Unmarshal([]byte(body), &reply); status != http.StatusOK || err != nil {
+		t.Fatalf("POST = %d: %s", status, body)
+	}
+	// The API cannot approve, so the shout call is refused
+	if reply.Session != "s1" || !strings.HasPrefix(reply.Response, "done: Error: tool shout needs approval") {
+		t.Errorf("reply %+v", reply)
+	}
+
+	history := func(query string) []string {
+		t.Helper()
+		status, body := apiRequest(t, srv, "GET", messages+query, token, "")
+		var result struct {
+			Messages []struct {
+				Role string `json:"role"`
+			} `json:"messages"`
+		}
+		if err := json.Unmarshal([]byte(body), &result); status != http.StatusOK || err != nil {
+			t.Fatalf("GET %s = %d: %s", query, status, body)
+		}
+		roles := make([]string, len(result.Messages))
+		for i, m := range result.Messages {
+			roles[i] = m.Role
+		}
+		return roles
+	}
+	if got := strings.Join(history(""), ","); got != "user,assistant" {
+		t.Errorf("history roles %s", got)
+	}
+	if got := strings.Join(history("?limit=1"), ","); got != "assistant" {
+		t.Errorf("limited history roles %s", got)
+	}
+
+	tests := []struct {
+		name, method, path, token, body string
+		want                            int
+	}{
+		{"health needs no key", "GET", "/healthz", "", "", http.StatusOK},
+		{"missing key", "GET", messages, "", "", http.StatusUnauthorized},
+		{"tools", "GET", "/api/tools", token, "", http.StatusOK},
+		{"bad session id", "GET", "/api/sessions/a%20b/messages", token, "", http.StatusBadRequest},
+		{"unknown path", "GET", "/api/sessions/s1/other", token, "", http.StatusNotFound},
+		{"bad limit", "GET", messages + "?limit=0", token, "", http.StatusBadRequest},
+		{"bad JSON", "POST", messages, token, `{"message":`, http.StatusBadRequest},
+		{"empty message", "POST", messages, token, `{"message": "  "}`, http.StatusBadRequest},
+		{"other method", "PUT", messages, token, "", http.StatusMethodNotAllowed},
+		{"listing sessions needs admin", "GET", "/api/sessions", token, "", http.StatusForbidden},
+		{"admin lists sessions", "GET", "/api/sessions", admin, "", http.StatusOK},
+		{"clearing needs admin", "DELETE", messages, token, "", http.StatusForbidden},
+		{"admin clears", "DELETE", messages, admin, "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		if status, body := apiRequest(t, srv, tt.method, tt.path, tt.token, tt.body); status != tt.want {
+			t.Errorf("%s: %s %s = %d, want %d: %s", tt.name, tt.method, tt.path, status, tt.want, body)
+		}
+	}
+	if got := history(""); len(got) != 0 {
+		t.Errorf("history after clearing: %v", got)
+	}
+}
+
+func TestSessionOwnership(t *testing.T) {
+	s, srv, owner := newTestGateway(t)
+	other := createKey(t, s, APIKey{Name: "other", Scopes: []string{ScopeChat}})
+	admin := createKey(t, s, APIKey{Name: "admin", Scopes: []string{ScopeAdmin}})
+
+	// History left by the CLI before owners were recorded
+	if err := s.store.Session("cli").Add("user", "private notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	const messages = "/api/sessions/s1/messages"
+	steps := []struct {
+		name, method, path, token, body string
+		want                            int
+	}{
+		{"unused session is readable", "GET", messages, other, "", http.StatusOK},
+		{"reading does not claim", "POST", messages, owner, `{"message": "hi"}`, http.StatusOK},
+		{"owner reads", "GET", messages, owner, "", http.StatusOK},
+		{"other key reads", "GET", messages, other, "", http.StatusForbidden},
+		{"other key posts", "POST", messages, other, `{"message": "hi"}`, http.StatusForbidden},
+		{"admin reads", "GET", messages, admin, "", http.StatusOK},
+		{"other key uses its own session", "POST", "/api/sessions/s2/messages", other, `{"message": "hi"}`, http.StatusOK},
+		{"owner cannot read it", "GET", "/api/sessions/s2/messages", owner, "", http.StatusForbidden},
+		{"CLI history belongs to the host", "GET", "/api/sessions/cli/messages", owner, "", http.StatusForbidden},
+		{"admin reads CLI history", "GET", "/api/sessions/cli/messages", admin, "", http.StatusOK},
+		{"OpenAI API honours owners", "POST", "/v1/chat/completions", other,
+			`{"user": "s1", "messages": [{"role": "user", "content": "hi"}]}`, http.StatusForbidden},
+		{"OpenAI stream honours owners", "POST", "/v1/chat/completions", other,
+			`{"user": "s1", "stream": true, "messages": [{"role": "user", "content": "hi"}]}`, http.StatusForbidden},
+	}
+	for _, step := range steps {
+		status, body := apiRequest(t, srv, step.method, step.path, step.token, step.body)
+		if status != step.want {
+			t.Errorf("%s: %s %s = %d, want %d: %s", step.name, step.method, step.path, status, step.want, body)
+		}
+	}
+
+	// WebSocket messages to another key's session are refused
+	conn, err := DialWebSocket(wsURL(srv, "token="+other), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := conn.WriteJSON(wsEvent{Type: "message", Session: "s1", Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	var ev wsEvent
+	if err := conn.ReadJSON(&ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != "error" || ev.Error != errSessionForbidden.Error() {
+		t.Errorf("message to another key's session: got %+v", ev)
+	}
+}
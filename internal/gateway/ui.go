@@ -0,0 +1,86 @@
+package gateway
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// maxMemoryFileSize bounds MEMORY.md uploads from the web UI
+const maxMemoryFileSize = 1 << 20
+
+// isPublicPath reports whether path is served without an API key: the
+// health check, the web UI's static files, which ask for a key
+// themselves, and webhooks, which are authenticated by their signature
+func isPublicPath(path string) bool {
+	return path == "/healthz" || path == "/" || strings.HasPrefix(path, "/static/") || strings.HasPrefix(path, "/hooks/")
+}
+
+// uiHandler serves the embedded single-page chat UI
+func uiHandler() http.Handler {
+	static, _ := fs.Sub(webFiles, "web")
+	files := http.FileServer(http.FS(static))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/":
+			data, _ := webFiles.ReadFile("web/index.html")
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(data)
+		case strings.HasPrefix(r.URL.Path, "/static/"):
+			http.StripPrefix("/static", files).ServeHTTP(w, r)
+		default:
+			writeError(w, http.StatusNotFound, "not found")
+		}
+	})
+}
+
+// /api/memory reads (GET, chat scope) or replaces (PUT, admin scope) the
+// workspace MEMORY.md
+func (s *Server) handleMemory(w http.ResponseWriter, r *http.Request) {
+	path := filepath.Join(s.cfg.Memory.Workspace, "memory", "MEMORY.md")
+
+	switch r.Method {
+	case http.MethodGet:
+		if _, ok := requireScope(w, r, ScopeChat); !ok {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			writeError(w, http.StatusInternalServerError, "failed to read MEMORY.md: %v", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"content": string(data)})
+
+	case http.MethodPut:
+		if _, ok := requireScope(w, r, ScopeAdmin); !ok {
+			return
+		}
+		var req struct {
+			Content string `json:"content"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMemoryFileSize)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body: %v", err)
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to create memory directory: %v", err)
+			return
+		}
+		if err := os.WriteFile(path, []byte(req.Content), 0644); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to write MEMORY.md: %v", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+	}
+}
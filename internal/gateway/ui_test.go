@@ -0,0 +1,36 @@
+package gateway
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUI(t *testing.T) {
+	s, srv, token := newTestGateway(t)
+	admin := createKey(t, s, APIKey{Name: "admin", Scopes: []string{ScopeAdmin}})
+
+	tests := []struct {
+		name, method, path, token, body string
+		want                            int
+		wantBody                        string
+	}{
+		{"index needs no key", "GET", "/", "", "", http.StatusOK, "<html"},
+		{"static files need no key", "GET", "/static/app.js", "", "", http.StatusOK, "WebSocket"},
+		{"missing static file", "GET", "/static/nope.js", "", "", http.StatusNotFound, ""},
+		{"other paths need a key", "GET", "/nope", "", "", http.StatusUnauthorized, ""},
+		{"unknown path", "GET", "/nope", token, "", http.StatusNotFound, "not found"},
+		{"memory starts empty", "GET", "/api/memory", token, "", http.StatusOK, `{"content":""}`},
+		{"writing memory needs admin", "PUT", "/api/memory", token, `{"content": "x"}`, http.StatusForbidden, ""},
+		{"admin writes memory", "PUT", "/api/memory", admin, `{"content": "# Notes"}`, http.StatusNoContent, ""},
+		{"memory reads back", "GET", "/api/memory", token, "", http.StatusOK, `{"content":"# Notes"}`},
+		{"bad memory body", "PUT", "/api/memory", admin, `{"content": 1}`, http.StatusBadRequest, ""},
+		{"other method", "DELETE", "/api/memory", admin, "", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		status, body := apiRequest(t, srv, tt.method, tt.path, tt.token, tt.body)
+		if status != tt.want || !strings.Contains(body, tt.wantBody) {
+			t.Errorf("%s: %s %s = %d %.80q, want %d with %q", tt.name, tt.method, tt.path, status, body, tt.want, tt.wantBody)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+// GoClaw web chat. Talks to the gateway REST API and the /ws event stream.
+(function () {
+  "use strict";
+
+  const $ = (id) => document.getElementById(id);
+  const state = {
+    key: localStorage.getItem("goclaw.key") || "",
+    session: localStorage.getItem("goclaw.session") || "default",
+    ws: null,
+    current: null, // Message element receiving streamed text
+    tools: {},     // call_id -> tool panel
+  };
+
+  // ---- API -----------------------------------------------------------
+
+  async function api(method, path, body) {
+    const headers = { "Content-Type": "application/json" };
+    if (state.key) headers.Authorization = "Bearer " + state.key;
+    const resp = await fetch(path, {
+      method, headers, body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    if (resp.status === 204) return null;
+    const data = await resp.json().catch(() => ({}));
+    if (!resp.ok) {
+      const err = new Error(data.error || resp.statusText);
+      err.status = resp.status;
+      throw err;
+    }
+    return data;
+  }
+
+  // ---- Markdown ------------------------------------------------------
+
+  function escapeHTML(s) {
+    return s.replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;").replace(/"/g, "&quot;");
+  }
+
+  function inline(s) {
+    return escapeHTML(s)
+      .replace(/`([^`]+)`/g, "<code>$1</code>")
+      .replace(/\*\*([^*]+)\*\*/g, "<strong>$1</strong>")
+      .replace(/(^|[^*])\*([^*\s][^*]*)\*/g, "$1<em>$2</em>")
+      .replace(/\[([^\]]+)\]\((https?:\/\/[^)\s]+)\)/g, '<a href="$2" target="_blank" rel="noopener">$1</a>');
+  }
+
+  function renderMarkdown(text) {
+    const lines = text.replace(/\r\n/g, "\n").split("\n");
+    const out = [];
+    let i = 0;
+    while (i < lines.length) {
+      const line = lines[i];
+      const fence = line.match(/^```\s*([\w+-]*)/);
+      if (fence) {
+        const code = [];
+        for (i++; i < lines.length && !lines[i].startsWith("```"); i++) code.push(lines[i]);
+        i++;
+        out.push('<pre><code class="lang-' + escapeHTML(fence[1]) + '">' + escapeHTML(code.join("\n")) + "</code></pre>");
+        continue;
+      }
+      const heading = line.match(/^(#{1,6})\s+(.*)/);
+      if (heading) {
+        const level = heading[1].length;
+        out.push("<h" + level + ">" + inline(heading[2]) + "</h" + level + ">");
+        i++;
+        continue;
+      }
+      if (/^\s*([-*+]|\d+\.)\s+/.test(line)) {
+        const ordered = /^\s*\d+\./.test(line);
+        const items = [];
+        for (; i < lines.length && /^\s*([-*+]|\d+\.)\s+/.test(lines[i]); i++) {
+          items.push("<li>" + inline(lines[i].replace(/^\s*([-*+]|\d+\.)\s+/, "")) + "</li>");
+        }
+        out.push((ordered ? "<ol>" : "<ul>") + items.join("") + (ordered ? "</ol>" : "</ul>"));
+        continue;
+      }
+      if (/^\|.*\|\s*$/.test(line)) {
+        const rows = [];
+        for (; i < lines.length && /^\|.*\|\s*$/.test(lines[i]); i++) {
+          if (/^\|[\s:|-]+\|\s*$/.test(lines[i])) continue; // Separator row
+          const cells = lines[i].trim().slice(1, -1).split("|").map((c) => "<td>" + inline(c.trim()) + "</td>");
+          rows.push("<tr>" + cells.join("") + "</tr>");
+        }
+        out.push("<table>" + rows.join("") + "</table>");
+        continue;
+      }
+      if (/^>\s?/.test(line)) {
+        const quote = [];
+        for (; i < lines.length && /^>\s?/.test(lines[i]); i++) quote.push(lines[i].replace(/^>\s?/, ""));
+        out.push("<blockquote>" + renderMarkdown(quote.join("\n")) + "</blockquote>");
+        continue;
+      }
+      if (line.trim() === "") {
+        i++;
+        continue;
+      }
+      const para = [];
+      for (; i < lines.length && lines[i].trim() !== "" && !/^(```|#{1,6}\s|\s*([-*+]|\d+\.)\s|>|\|)/.test(lines[i]); i++) {
+        para.push(inline(lines[i]));
+      }
+      if (para.length === 0) para.push(inline(lines[i++]));
+      out.push("<p>" + para.join("<br>") + "</p>");
+    }
+    return out.join("\n");
+  }
+
+  // ---- Messages ------------------------------------------------------
+
+  function addMessage(role, text) {
+    const el = document.createElement("div");
+    el.className = "msg " + role;
+    el.innerHTML = '<div class="role">' + (role === "user" ? "你" : "GoClaw") + '</div><div class="body"></div>';
+    el.dataset.text = text || "";
+    el.querySelector(".body").innerHTML = renderMarkdown(el.dataset.text);
+    $("messages").appendChild(el);
+    scrollDown();
+    return el;
+  }
+
+  function appendText(el, text) {
+    el.dataset.text += text;
+    el.querySelector(".body").innerHTML = renderMarkdown(el.dataset.text);
+    scrollDown();
+  }
+
+  function scrollDown() {
+    const box = $("messages");
+    box.scrollTop = box.scrollHeight;
+  }
+
+  function assistantMessage() {
+    if (!state.current) state.current = addMessage("assistant", "");
+    return state.current;
+  }
+
+  function toolPanel(ev) {
+    let panel = state.tools[ev.call_id];
+    if (!panel) {
+      panel = document.createElement("details");
+      panel.className = "tool running";
+      panel.innerHTML = "<summary><span class=\"name\"></span><span class=\"state\"></span></summary><pre class=\"args\"></pre>";
+      panel.querySelector(".name").textContent = "🔧 " + ev.name;
+      panel.querySelector(".args").textContent = ev.arguments || "";
+      assistantMessage().appendChild(panel);
+      state.tools[ev.call_id] = panel;
+    }
+    return panel;
+  }
+
+  function setToolState(panel, cls, label) {
+    panel.className = "tool " + cls;
+    panel.querySelector(".state").textContent = label;
+  }
+
+  // ---- WebSocket events ---------------------------------------------
+
+  function connect() {
+    const proto = location.protocol === "https:" ? "wss:" : "ws:";
+    let url = proto + "//" + location.host + "/ws?session=" + encodeURIComponent(state.session);
+    if (state.key) url += "&token=" + encodeURIComponent(state.key);
+    const ws = new WebSocket(url);
+    state.ws = ws;
+    setStatus("连接中…");
+    ws.onopen = () => setStatus("已连接 · 会话 " + state.session);
+    ws.onclose = () => {
+      if (state.ws !== ws) return;
+      setStatus("连接已断开，3 秒后重连");
+      setTimeout(() => state.ws === ws && connect(), 3000);
+    };
+    ws.onmessage = (e) => handleEvent(JSON.parse(e.data));
+  }
+
+  function handleEvent(ev) {
+    if (ev.type === "reminder") {
+      showReminder(ev);
+      return;
+    }
+    if (ev.session && ev.session !== state.session) {
+      loadSessions();
+      return;
+    }
+    switch (ev.type) {
+      case "delta":
+        appendText(assistantMessage(), ev.text);
+        break;
+      case "tool_start":
+        setToolState(toolPanel(ev), "running", "运行中");
+        break;
+      case "tool_finish": {
+        const panel = toolPanel(ev);
+        setToolState(panel, ev.failed ? "failed" : "done", ev.failed ? "失败" : "完成");
+        const result = document.createElement("pre");
+        result.textContent = ev.result;
+        panel.appendChild(result);
+        const approval = panel.querySelector(".approval");
+        if (approval) approval.remove();
+        break;
+      }
+      case "approval_request":
+        showApproval(ev);
+        break;
+      case "message": {
+        const el = assistantMessage();
+        el.dataset.text = "";
+        const panels = Array.from(el.querySelectorAll(".tool"));
+        appendText(el, ev.text);
+        panels.forEach((p) => el.insertBefore(p, el.querySelector(".body")));
+        finishTurn();
+        loadSessions();
+        break;
+      }
+      case "error":
+        addMessage("assistant", "**错误:** " + ev.error);
+        finishTurn();
+        break;
+    }
+  }
+
+  function showApproval(ev) {
+    const panel = toolPanel(ev);
+    setToolState(panel, "running", "等待批准");
+    panel.open = true;
+    const box = document.createElement("div");
+    box.className = "approval";
+    box.innerHTML = "允许运行此工具？<button class=\"allow\">允许</button><button class=\"deny\">拒绝</button>";
+    const answer = (approved) => {
+      state.ws.send(JSON.stringify({ type: "approval", call_id: ev.call_id, approved }));
+      box.remove();
+      if (!approved) setToolState(panel, "failed", "已拒绝");
+    };
+    box.querySelector(".allow").onclick = () => answer(true);
+    box.querySelector(".deny").onclick = () => answer(false);
+    panel.appendChild(box);
+  }
+
+  function showReminder(ev) {
+    const el = document.createElement("div");
+    el.className = "msg reminder";
+    el.innerHTML = '<div class="role">⏰ 提醒</div><div class="body"></div>';
+    el.querySelector(".body").textContent = ev.text + "（" + new Date(ev.at).toLocaleString() + "）";
+    $("messages").appendChild(el);
+    scrollDown();
+    if (window.Notification && Notification.permission === "granted") {
+      new Notification("GoClaw 提醒", { body: ev.text });
+    }
+  }
+
+  function finishTurn() {
+    state.current = null;
+    state.tools = {};
+    $("send").disabled = false;
+  }
+
+  function setStatus(text) {
+    $("status").textContent = text;
+  }
+
+  // ---- Sessions ------------------------------------------------------
+
+  function knownSessions() {
+    return JSON.parse(localStorage.getItem("goclaw.sessions") || '["default"]');
+  }
+
+  function remember(id) {
+    const list = knownSessions().filter((s) => s !== id);
+    list.unshift(id);
+    localStorage.setItem("goclaw.sessions", JSON.stringify(list.slice(0, 50)));
+  }
+
+  async function loadSessions() {
+    let sessions;
+    try {
+      sessions = (await api("GET", "/api/sessions")).sessions;
+    } catch (err) {
+      // Keys without the admin scope cannot list sessions; use the ones seen here
+      sessions = knownSessions().map((id) => ({ id }));
+    }
+    if (!sessions.some((s) => s.id === state.session)) sessions.unshift({ id: state.session });
+
+    const list = $("sessions");
+    list.innerHTML = "";
+    sessions.forEach((s) => {
+      const li = document.createElement("li");
+      li.textContent = s.id;
+      if (s.messages !== undefined) {
+        const info = document.createElement("small");
+        info.textContent = s.messages + " 条消息 · " + new Date(s.last_active).toLocaleString();
+        li.appendChild(info);
+      }
+      if (s.id === state.session) li.className = "active";
+      li.onclick = () => switchSession(s.id);
+      list.appendChild(li);
+    });
+  }
+
+  async function switchSession(id) {
+    state.session = id;
+    localStorage.setItem("goclaw.session", id);
+    remember(id);
+    finishTurn();
+    $("messages").innerHTML = "";
+    loadSessions();
+    setStatus("已连接 · 会话 " + id);
+    try {
+      const data = await api("GET", "/api/sessions/" + encodeURIComponent(id) + "/messages?limit=100");
+      data.messages.forEach((m) => addMessage(m.role === "user" ? "user" : "assistant", m.content));
+    } catch (err) {
+      addMessage("assistant", "**错误:** " + err.message);
+    }
+  }
+
+  // ---- Memory editor -------------------------------------------------
+
+  async function openMemory() {
+    $("memory").classList.remove("hidden");
+    $("memory-status").textContent = "加载中…";
+    try {
+      $("memory-text").value = (await api("GET", "/api/memory")).content;
+      $("memory-status").textContent = "";
+    } catch (err) {
+      $("memory-status").textContent = err.message;
+    }
+  }
+
+  async function saveMemory() {
+    $("memory-status").textContent = "保存中…";
+    try {
+      await api("PUT", "/api/memory", { content: $("memory-text").value });
+      $("memory-status").textContent = "已保存";
+    } catch (err) {
+      $("memory-status").textContent = err.status === 403 ? "需要 admin 权限的 API Key" : err.message;
+    }
+  }
+
+  // ---- Startup -------------------------------------------------------
+
+  function send() {
+    const text = $("input").value.trim();
+    if (!text || !state.ws || state.ws.readyState !== WebSocket.OPEN) return;
+    addMessage("user", text);
+    state.ws.send(JSON.stringify({ type: "message", session: state.session, text }));
+    $("input").value = "";
+    $("send").disabled = true;
+    remember(state.session);
+  }
+
+  async function start() {
+    try {
+      await api("GET", "/api/tools");
+    } catch (err) {
+      if (err.status === 401) {
+        $("login").classList.remove("hidden");
+        $("login-error").textContent = state.key ? "API Key 无效或已吊销" : "";
+        return;
+      }
+    }
+    $("login").classList.add("hidden");
+    if (window.Notification && Notification.permission === "default") Notification.requestPermission();
+    connect();
+    switchSession(state.session);
+  }
+
+  $("login-form").onsubmit = (e) => {
+    e.preventDefault();
+    state.key = $("login-key").value.trim();
+    localStorage.setItem("goclaw.key", state.key);
+    start();
+  };
+  $("logout").onclick = () => {
+    localStorage.removeItem("goclaw.key");
+    location.reload();
+  };
+  $("composer").onsubmit = (e) => {
+    e.preventDefault();
+    send();
+  };
+  $("input").onkeydown = (e) => {
+    if (e.key === "Enter" && !e.shiftKey && !e.isComposing) {
+      e.preventDefault();
+      send();
+    }
+  };
+  $("new-session").onclick = () => {
+    const id = prompt("会话名称（字母、数字、. _ -）");
+    if (id && /^[a-zA-Z0-9_.-]{1,64}$/.test(id)) switchSession(id);
+  };
+  $("open-memory").onclick = openMemory;
+  $("memory-save").onclick = saveMemory;
+  $("memory-close").onclick = () => $("memory").classList.add("hidden");
+
+  start();
+})();
@@ -0,0 +1,358 @@
+package gateway
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/user/goclaw2/internal/agent"
+	"github.com/user/goclaw2/internal/config"
+	"github.com/user/goclaw2/internal/provider/zhipu"
+)
+
+const (
+	// maxWebhookBody bounds inbound event payloads
+	maxWebhookBody = 1 << 20
+
+	// signatureHeader carries "sha256=<hex HMAC>" of the timestamp header,
+	// a ".", and the body
+	signatureHeader = "X-GoClaw-Signature"
+
+	// timestampHeader carries the Unix time the request was signed at
+	timestampHeader = "X-GoClaw-Timestamp"
+
+	// signatureWindow is how far the signed time may be from ours. Older
+	// requests are refused and newer duplicates are remembered.
+	signatureWindow = 5 * time.Minute
+
+	// callbackAttempts is how often a failed callback POST is tried
+	callbackAttempts = 3
+)
+
+// defaultWebhookTemplate is used when a webhook configures no template
+const defaultWebhookTemplate = `收到来自 {{.Name}} 的 webhook 事件，请处理：
+
+{{.Body}}`
+
+// webhook is a configured inbound webhook with its parsed template
+type webhook struct {
+	config.WebhookConfig
+	tmpl *template.Template
+
+	mu   sync.Mutex
+	seen map[string]time.Time // Accepted signatures -> their signed time
+}
+
+// webhookEvent is the data the prompt template is executed with
+type webhookEvent struct {
+	Name     string      // Webhook name
+	Delivery string      // ID of this delivery
+	Body     string      // Raw request body
+	Payload  interface{} // Body decoded as JSON, nil when it is not JSON
+	Headers  http.Header
+	Query    map[string]string
+}
+
+// webhookResult is POSTed to the callback URL once the agent replied
+type webhookResult struct {
+	Webhook  string      `json:"webhook"`
+	Delivery string      `json:"delivery"`
+	Session  string      `json:"session"`
+	Response string      `json:"response,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Usage    zhipu.Usage `json:"usage"`
+}
+
+// webhookFuncs are available in prompt templates
+var webhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		return string(data), err
+	},
+}
+
+// loadWebhooks validates the configured webhooks and parses their templates
+func loadWebhooks(configs []config.WebhookConfig) (map[string]*webhook, error) {
+	hooks := make(map[string]*webhook, len(configs))
+	for _, c := range configs {
+		if !sessionIDPattern.MatchString(c.Name) {
+			return nil, fmt.Errorf("invalid webhook name %q: use letters, digits, '.', '_' and '-'", c.Name)
+		}
+		if _, exists := hooks[c.Name]; exists {
+			return nil, fmt.Errorf("duplicate webhook %q", c.Name)
+		}
+		if c.Secret == "" {
+			return nil, fmt.Errorf("webhook %s: secret is required", c.Name)
+		}
+		if c.Session == "" {
+			c.Session = "webhook-" + c.Name
+		}
+		if !sessionIDPattern.MatchString(c.Session) {
+			return nil, fmt.Errorf("webhook %s: invalid session %q", c.Name, c.Session)
+		}
+		if c.Template == "" {
+			c.Template = defaultWebhookTemplate
+		}
+		tmpl, err := template.New(c.Name).Funcs(webhookFuncs).Option("missingkey=zero").Parse(c.Template)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %s: invalid template: %w", c.Name, err)
+		}
+		hooks[c.Name] = &webhook{WebhookConfig: c, tmpl: tmpl, seen: make(map[string]time.Time)}
+	}
+	return hooks, nil
+}
+
+// listsTool reports whether the webhook explicitly allows the named
+// tool. Tools that need approval only run when listed, since nobody is
+// around to approve them.
+func (h *webhook) listsTool(name string) bool {
+	for _, t := range h.Tools {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// firstUse records an accepted signature and reports whether it was new.
+// Signatures whose time left the window are forgotten, since checkTimestamp
+// refuses them anyway.
+func (h *webhook) firstUse(signature string, signed, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sig, at := range h.seen {
+		if now.Sub(at) > signatureWindow {
+			delete(h.seen, sig)
+		}
+	}
+	if _, ok := h.seen[signature]; ok {
+		return false
+	}
+	h.seen[signature] = signed
+	return true
+}
+
+// POST /hooks/{name}
+//
+// The timestamp and body must be signed with the webhook's secret, and
+// each signature is accepted once. The event is
+// acknowledged with 202 and processed in the background; the reply goes
+// to the configured callback URL and/or output file.
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/hooks/")
+	hook, ok := s.webhooks[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown webhook %q", name)
+		return
+	}
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "failed to read body: %v", err)
+		return
+	}
+	timestamp := r.Header.Get(timestampHeader)
+	signature := r.Header.Get(signatureHeader)
+	if !verifySignature(hook.Secret, timestamp, body, signature) {
+		writeError(w, http.StatusUnauthorized, "invalid or missing %s and %s headers", signatureHeader, timestampHeader)
+		return
+	}
+	now := time.Now()
+	signed, err := checkTimestamp(timestamp, now)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "%v", err)
+		return
+	}
+	if !hook.firstUse(signature, signed, now) {
+		writeError(w, http.StatusConflict, "duplicate delivery: this signature was already used")
+		return
+	}
+
+	event := webhookEvent{
+		Name:     hook.Name,
+		Delivery: newDeliveryID(),
+		Body:     string(body),
+		Headers:  r.Header,
+		Query:    make(map[string]string),
+	}
+	var payload interface{}
+	if json.Unmarshal(body, &payload) == nil {
+		event.Payload = payload
+	}
+	for k, v := range r.URL.Query() {
+		event.Query[k] = v[0]
+	}
+
+	var prompt bytes.Buffer
+	if err := hook.tmpl.Execute(&prompt, event); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "failed to render prompt template: %v", err)
+		return
+	}
+	if strings.TrimSpace(prompt.String()) == "" {
+		writeError(w, http.StatusUnprocessableEntity, "prompt template rendered an empty prompt")
+		return
+	}
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		s.runWebhook(hook, event.Delivery, prompt.String())
+	}()
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"delivery": event.Delivery, "session": hook.Session})
+}
+
+// runWebhook runs the agent turn for one delivery and hands the reply to
+// the configured outputs
+func (s *Server) runWebhook(hook *webhook, delivery, prompt string) {
+	key := &APIKey{Name: "webhook:" + hook.Name, Scopes: []string{ScopeChat}, Tools: hook.Tools}
+	ev := &agent.Events{
+		Approve: func(call zhipu.ToolCall) bool {
+			return hook.listsTool(call.Function.Name)
+		},
+	}
+	response, usage, err := s.runTurn(key, hook.Session, prompt, ev)
+
+	result := webhookResult{
+		Webhook:  hook.Name,
+		Delivery: delivery,
+		Session:  hook.Session,
+		Response: response,
+		Usage:    usage,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	if hook.OutputFile != "" {
+		if err := appendWebhookOutput(hook.OutputFile, result); err != nil {
+			log.Printf("webhook %s: delivery %s: %v", hook.Name, delivery, err)
+		}
+	}
+	if hook.CallbackURL != "" {
+		if err := postCallback(hook, result); err != nil {
+			log.Printf("webhook %s: delivery %s: %v", hook.Name, delivery, err)
+		}
+	}
+}
+
+// postCallback POSTs result to the webhook's callback URL, signed with
+// the same secret, retrying failed attempts with a growing delay
+func postCallback(hook *webhook, result webhookResult) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode callback: %w", err)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	var lastErr error
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * 2 * time.Second)
+		}
+		req, err := http.NewRequest(http.MethodPost, hook.CallbackURL, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("invalid callback URL: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(timestampHeader, timestamp)
+		req.Header.Set(signatureHeader, sign(hook.Secret, timestamp, body))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		if resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("status %s", resp.Status)
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			break // Retrying will not help
+		}
+	}
+	return fmt.Errorf("callback to %s failed: %w", hook.CallbackURL, lastErr)
+}
+
+// outputMu serializes appends to webhook output files
+var outputMu sync.Mutex
+
+// appendWebhookOutput appends result to path as a Markdown section
+func appendWebhookOutput(path string, result webhookResult) error {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer f.Close()
+
+	text := result.Response
+	if result.Error != "" {
+		text = "Error: " + result.Error
+	}
+	_, err = fmt.Fprintf(f, "## %s %s (%s)\n\n%s\n\n",
+		time.Now().Format("2006-01-02 15:04:05"), result.Webhook, result.Delivery, strings.TrimSpace(text))
+	if err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
+
+// sign returns the signature header value for a body sent at timestamp
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature checks a "sha256=<hex>" signature in constant time
+func verifySignature(secret, timestamp string, body []byte, signature string) bool {
+	return timestamp != "" && signature != "" &&
+		hmac.Equal([]byte(sign(secret, timestamp, body)), []byte(signature))
+}
+
+// checkTimestamp parses a signed Unix time and refuses it when it is more
+// than signatureWindow away from now
+func checkTimestamp(timestamp string, now time.Time) (time.Time, error) {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s header: want Unix seconds", timestampHeader)
+	}
+	signed := time.Unix(sec, 0)
+	if d := now.Sub(signed); d > signatureWindow || d < -signatureWindow {
+		return time.Time{}, fmt.Errorf("%s is more than %s away from the server time", timestampHeader, signatureWindow)
+	}
+	return signed, nil
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,163 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/goclaw2/internal/config"
+)
+
+// postHook sends body to a webhook with the given signature headers
+func postHook(t *testing.T, srv *httptest.Server, path, timestamp, signature, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest("POST", srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timestamp != "" {
+		req.Header.Set(timestampHeader, timestamp)
+	}
+	if signature != "" {
+		req.Header.Set(signatureHeader, signature)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestWebhook(t *testing.T) {
+	const secret = "s3cret"
+	callbacks := make(chan webhookResult, 1)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !verifySignature(secret, r.Header.Get(timestampHeader), body, r.Header.Get(signatureHeader)) {
+			t.Errorf("callback signature %q does not match", r.Header.Get(signatureHeader))
+		}
+		var result webhookResult
+		json.Unmarshal(body, &result)
+		callbacks <- result
+	}))
+	defer callback.Close()
+
+	output := filepath.Join(t.TempDir(), "out", "ci.md")
+	_, srv, _ := newTestGatewayWith(t, fmt.Sprintf(`  webhooks:
+    - name: ci
+      secret: %q
+      template: "build {{.Payload.status}} on {{.Query.ref}}"
+      tools: ["shout"]
+      callback_url: %q
+      output_file: %q
+    - name: quiet
+      secret: %q
+      template: "{{if .Payload}}event{{end}}"`, secret, callback.URL, output, secret))
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	body := `{"status": "ok"}`
+	tests := []struct {
+		name, path, timestamp, signature, body string
+		want                                   int
+		wantBody                               string
+	}{
+		{"unknown webhook", "/hooks/nope", now, sign(secret, now, []byte(body)), body, http.StatusNotFound, "unknown webhook"},
+		{"missing signature", "/hooks/ci", now, "", body, http.StatusUnauthorized, "invalid or missing"},
+		{"wrong secret", "/hooks/ci", now, sign("other", now, []byte(body)), body, http.StatusUnauthorized, "invalid or missing"},
+		{"changed body", "/hooks/ci", now, sign(secret, now, []byte(body)), `{"status": "bad"}`, http.StatusUnauthorized, "invalid or missing"},
+		{"signed too long ago", "/hooks/ci", old, sign(secret, old, []byte(body)), body, http.StatusUnauthorized, "away from the server time"},
+		{"bad timestamp", "/hooks/ci", "soon", sign(secret, "soon", []byte(body)), body, http.StatusUnauthorized, "want Unix seconds"},
+		{"empty prompt", "/hooks/quiet", now, sign(secret, now, []byte("plain")), "plain", http.StatusUnprocessableEntity, "empty prompt"},
+		{"accepted", "/hooks/ci?ref=main", now, sign(secret, now, []byte(body)), body, http.StatusAccepted, `"session":"webhook-ci"`},
+		{"replayed", "/hooks/ci?ref=main", now, sign(secret, now, []byte(body)), body, http.StatusConflict, "duplicate delivery"},
+	}
+	for _, tt := range tests {
+		status, got := postHook(t, srv, tt.path, tt.timestamp, tt.signature, tt.body)
+		if status != tt.want || !strings.Contains(got, tt.wantBody) {
+			t.Errorf("%s: %d %s, want %d with %q", tt.name, status, got, tt.want, tt.wantBody)
+		}
+	}
+
+	// The reply of the accepted delivery goes to both outputs; shout is
+	// listed, so it runs without approval
+	var result webhookResult
+	select {
+	case result = <-callbacks:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no callback")
+	}
+	if result.Webhook != "ci" || result.Session != "webhook-ci" || result.Delivery == "" ||
+		result.Response != "done: BUILD OK ON MAIN" || result.Error != "" {
+		t.Errorf("callback %+v", result)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "ci ("+result.Delivery+")\n\ndone: BUILD OK ON MAIN\n") {
+		t.Errorf("output file:\n%s", data)
+	}
+}
+
+func TestLoadWebhooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []config.WebhookConfig
+		wantErr string
+	}{
+		{"defaults", []config.WebhookConfig{{Name: "a", Secret: "s"}}, ""},
+		{"bad name", []config.WebhookConfig{{Name: "a/b", Secret: "s"}}, "invalid webhook name"},
+		{"duplicate", []config.WebhookConfig{{Name: "a", Secret: "s"}, {Name: "a", Secret: "s"}}, "duplicate webhook"},
+		{"no secret", []config.WebhookConfig{{Name: "a"}}, "secret is required"},
+		{"bad session", []config.WebhookConfig{{Name: "a", Secret: "s", Session: "x y"}}, "invalid session"},
+		{"bad template", []config.WebhookConfig{{Name: "a", Secret: "s", Template: "{{.Body"}}, "invalid template"},
+	}
+	for _, tt := range tests {
+		hooks, err := loadWebhooks(tt.configs)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if h := hooks["a"]; h.Session != "webhook-a" || h.Template != defaultWebhookTemplate {
+			t.Errorf("%s: loaded %+v", tt.name, h.WebhookConfig)
+		}
+	}
+}
+
+func TestWebhookFirstUse(t *testing.T) {
+	h := &webhook{seen: make(map[string]time.Time)}
+	now := time.Now()
+	if !h.firstUse("a", now, now) || h.firstUse("a", now, now) {
+		t.Error("a signature was accepted twice")
+	}
+	if !h.firstUse("b", now.Add(-4*time.Minute), now) {
+		t.Error("second signature refused")
+	}
+
+	// Signatures are forgotten once their time leaves the window
+	later := now.Add(2 * time.Minute)
+	h.firstUse("c", later, later)
+	if _, ok := h.seen["b"]; ok {
+		t.Error("expired signature kept")
+	}
+	if _, ok := h.seen["a"]; !ok {
+		t.Error("signature still in the window forgotten")
+	}
+}
@@ -0,0 +1,350 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/goclaw2/internal/agent"
+	"github.com/user/goclaw2/internal/provider/zhipu"
+	"github.com/user/goclaw2/internal/scheduler"
+)
+
+// approvalTimeout denies a tool call the client never answered
+const approvalTimeout = 5 * time.Minute
+
+// revocationCheckInterval is how often open WebSockets are checked for
+// keys revoked since they connected
+const revocationCheckInterval = 30 * time.Second
+
+// wsEvent is the message format of the /ws endpoint in both directions.
+//
+// Client to server:
+//
+//	{"type": "message", "session": "s1", "text": "..."}     run a turn
+//	{"type": "approval", "call_id": "...", "approved": true} answer an approval_request
+//
+// Server to client:
+//
+//	{"type": "delta", "text": "..."}                               streamed response text
+//	{"type": "tool_start", "call_id", "name", "arguments"}         a tool began running
+//	{"type": "tool_finish", "call_id", "name", "result", "failed"} a tool finished
+//	{"type": "approval_request", "call_id", "name", "arguments"}   waiting for an approval
+//	{"type": "message", "text": "..."}                             the final response
+//	{"type": "error", "error": "..."}
+//	{"type": "reminder", "id": 3, "text": "...", "at": "..."}      a reminder fell due
+//
+// Every server event carries the session it belongs to. Reminders go to
+// the clients with the key that set them that have used their session.
+type wsEvent struct {
+	Type      string `json:"type"`
+	ID        int64  `json:"id,omitempty"`
+	Session   string `json:"session,omitempty"`
+	Text      string `json:"text,omitempty"`
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Result    string `json:"result,omitempty"`
+	Approved  bool   `json:"approved,omitempty"`
+	Failed    bool   `json:"failed,omitempty"`
+	Error     string `json:"error,omitempty"`
+	At        string `json:"at,omitempty"`
+}
+
+// GET /ws?session=ID upgrades to a WebSocket. The session in the query is
+// the default for messages that do not name one.
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	defaultSession := r.URL.Query().Get("session")
+	if defaultSession == "" {
+		defaultSession = "default"
+	}
+	if !sessionIDPattern.MatchString(defaultSession) {
+		writeError(w, http.StatusBadRequest, "invalid session id %q", defaultSession)
+		return
+	}
+
+	// Browsers let any page open a WebSocket to localhost, which without
+	// auth means running tools, so only pages served by the gateway itself
+	// or listed in gateway.allowed_origins may connect
+	if origin := r.Header.Get("Origin"); !s.allowsOrigin(origin, r.Host) {
+		writeError(w, http.StatusForbidden, "origin %q is not allowed", origin)
+		return
+	}
+
+	key, ok := requireScope(w, r, ScopeChat)
+	if !ok {
+		return
+	}
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	c := &wsClient{conn: conn, server: s, key: key, approvals: make(map[string]chan bool),
+		sessions: map[string]bool{defaultSession: true}}
+	s.mu.Lock()
+	s.clients[c] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+	}()
+	c.run(defaultSession)
+}
+
+// allowsOrigin reports whether a WebSocket handshake with the given Origin
+// header may proceed. Requests without one do not come from a browser.
+func (s *Server) allowsOrigin(origin, host string) bool {
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, host) {
+		return true
+	}
+	for _, allowed := range s.cfg.Gateway.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// NotifyReminder sends a due reminder to the connected WebSocket clients
+// of its owner, those with the same key that have used its session, and
+// reports whether any received it
+func (s *Server) NotifyReminder(r *scheduler.Reminder) bool {
+	s.mu.Lock()
+	clients := make([]*wsClient, 0, len(s.clients))
+	for c := range s.clients {
+		if c.key.ID == r.KeyID && c.usesSession(r.Session) {
+			clients = append(clients, c)
+		}
+	}
+	s.mu.Unlock()
+
+	delivered := false
+	ev := wsEvent{Type: "reminder", ID: r.ID, Session: r.Session, Text: r.Message, At: r.Local().Format(time.RFC3339)}
+	for _, c := range clients {
+		if c.send(ev) == nil {
+			delivered = true
+		}
+	}
+	return delivered
+}
+
+// watchRevokedKeys closes WebSockets whose key has been revoked, for
+// example with `goclaw keys revoke` from another process, until ctx is
+// cancelled
+func (s *Server) watchRevokedKeys(ctx context.Context) {
+	ticker := time.NewTicker(revocationCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		clients := make([]*wsClient, 0, len(s.clients))
+		for c := range s.clients {
+			clients = append(clients, c)
+		}
+		s.mu.Unlock()
+
+		for _, c := range clients {
+			if c.key.ID == 0 {
+				continue
+			}
+			key, err := s.keys.Get(c.key.ID)
+			if err != nil {
+				log.Printf("gateway: failed to check API key %d: %v", c.key.ID, err)
+				continue
+			}
+			if key == nil {
+				c.revoked()
+			}
+		}
+	}
+}
+
+// wsClient is one WebSocket connection. Turns run in their own goroutines
+// so approvals can be read while a turn waits for them.
+type wsClient struct {
+	conn   *WSConn
+	server *Server
+	key    *APIKey
+
+	mu        sync.Mutex
+	approvals map[string]chan bool
+	sessions  map[string]bool // Sessions this client has used
+	nextID    int
+	closed    bool // The connection is gone; approvals are denied
+	turns     sync.WaitGroup
+}
+
+func (c *wsClient) usesSession(session string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessions[session]
+}
+
+func (c *wsClient) run(defaultSession string) {
+	defer func() {
+		// Deny pending and later approvals and make sends fail, so running
+		// turns finish quickly and release their sessions
+		c.mu.Lock()
+		c.closed = true
+		for id, ch := range c.approvals {
+			close(ch)
+			delete(c.approvals, id)
+		}
+		c.mu.Unlock()
+		c.conn.Close()
+		c.turns.Wait()
+	}()
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var in wsEvent
+		if err := json.Unmarshal(data, &in); err != nil {
+			c.send(wsEvent{Type: "error", Error: "invalid message: " + err.Error()})
+			continue
+		}
+
+		switch in.Type {
+		case "message":
+			session := in.Session
+			if session == "" {
+				session = defaultSession
+			}
+			if !sessionIDPattern.MatchString(session) {
+				c.send(wsEvent{Type: "error", Session: session, Error: "invalid session id"})
+				continue
+			}
+			if strings.TrimSpace(in.Text) == "" {
+				c.send(wsEvent{Type: "error", Session: session, Error: "text must not be empty"})
+				continue
+			}
+			// The handshake counted once; every message is a request too
+			if !c.server.limiter.allow(c.key.ID, c.key.RateLimit) {
+				c.send(wsEvent{Type: "error", Session: session,
+					Error: fmt.Sprintf("rate limit of %d requests per minute exceeded", c.key.RateLimit)})
+				continue
+			}
+			if err := c.server.checkSession(c.key, session, true); err != nil {
+				c.send(wsEvent{Type: "error", Session: session, Error: err.Error()})
+				continue
+			}
+			c.mu.Lock()
+			c.sessions[session] = true
+			c.mu.Unlock()
+			c.turns.Add(1)
+			go func() {
+				defer c.turns.Done()
+				c.runTurn(session, in.Text)
+			}()
+
+		case "approval":
+			c.mu.Lock()
+			ch, ok := c.approvals[in.CallID]
+			delete(c.approvals, in.CallID)
+			c.mu.Unlock()
+			if !ok {
+				c.send(wsEvent{Type: "error", CallID: in.CallID, Error: "no pending approval with this call_id"})
+				continue
+			}
+			ch <- in.Approved
+
+		default:
+			c.send(wsEvent{Type: "error", Error: "unknown message type: " + in.Type})
+		}
+	}
+}
+
+func (c *wsClient) runTurn(session, text string) {
+	events := &agent.Events{
+		OnDelta: func(delta string) {
+			c.send(wsEvent{Type: "delta", Session: session, Text: delta})
+		},
+		OnToolStart: func(call zhipu.ToolCall) {
+			c.send(wsEvent{Type: "tool_start", Session: session, CallID: call.ID,
+				Name: call.Function.Name, Arguments: call.Function.Arguments})
+		},
+		OnToolFinish: func(call zhipu.ToolCall, result string, failed bool) {
+			c.send(wsEvent{Type: "tool_finish", Session: session, CallID: call.ID,
+				Name: call.Function.Name, Result: result, Failed: failed})
+		},
+		Approve: func(call zhipu.ToolCall) bool {
+			return c.requestApproval(session, call)
+		},
+	}
+
+	response, _, err := c.server.runTurn(c.key, session, text, events)
+	if errors.Is(err, errKeyRevoked) {
+		c.revoked()
+		return
+	}
+	if err != nil {
+		c.send(wsEvent{Type: "error", Session: session, Error: err.Error()})
+		return
+	}
+	c.send(wsEvent{Type: "message", Session: session, Text: response})
+}
+
+// requestApproval asks the client about call and waits for its answer
+func (c *wsClient) requestApproval(session string, call zhipu.ToolCall) bool {
+	ch := make(chan bool, 1)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return false
+	}
+	id := call.ID
+	if _, taken := c.approvals[id]; id == "" || taken {
+		c.nextID++
+		id = fmt.Sprintf("approval-%d", c.nextID)
+	}
+	c.approvals[id] = ch
+	c.mu.Unlock()
+
+	if err := c.send(wsEvent{Type: "approval_request", Session: session, CallID: id,
+		Name: call.Function.Name, Arguments: call.Function.Arguments}); err != nil {
+		c.mu.Lock()
+		delete(c.approvals, id)
+		c.mu.Unlock()
+		return false
+	}
+
+	select {
+	case approved, ok := <-ch:
+		return ok && approved
+	case <-time.After(approvalTimeout):
+		c.mu.Lock()
+		delete(c.approvals, id)
+		c.mu.Unlock()
+		return false
+	}
+}
+
+// revoked tells the client its key was revoked and closes the connection
+func (c *wsClient) revoked() {
+	c.send(wsEvent{Type: "error", Error: errKeyRevoked.Error()})
+	c.conn.Close()
+}
+
+func (c *wsClient) send(ev wsEvent) error {
+	return c.conn.WriteJSON(ev)
+}
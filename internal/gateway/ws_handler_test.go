@@ -0,0 +1,309 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/goclaw2/internal/config"
+	"github.com/user/goclaw2/internal/memory"
+	"github.com/user/goclaw2/internal/tools"
+)
+
+// shoutTool upper-cases its text; the tests list it in approval_tools
+type shoutTool struct{}
+
+func (shoutTool) Name() string        { return "shout" }
+func (shoutTool) Description() string { return "Upper-case text" }
+
+func (shoutTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"text": map[string]interface{}{"type": "string"}},
+		"required":   []string{"text"},
+	}
+}
+
+func (shoutTool) Execute(args map[string]interface{}) (string, error) {
+	return strings.ToUpper(fmt.Sprint(args["text"])), nil
+}
+
+// fakeModel answers every user message with a shout call, after a pause
+// when the message contains "slow" and after some text when it contains
+// "chatty", then answers "done: <tool result>", in two deltas when
+// streaming
+func fakeModel(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+			Stream bool `json:"stream"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		last := req.Messages[len(req.Messages)-1]
+
+		var deltas []map[string]interface{}
+		if last.Role == "user" {
+			if strings.Contains(last.Content, "slow") {
+				time.Sleep(300 * time.Millisecond)
+			}
+			if strings.Contains(last.Content, "chatty") {
+				deltas = append(deltas, map[string]interface{}{"content": "let me check. "})
+			}
+			args, _ := json.Marshal(map[string]string{"text": last.Content})
+			deltas = append(deltas, map[string]interface{}{"tool_calls": []interface{}{map[string]interface{}{
+				"index": 0, "id": "call-1", "type": "function",
+				"function": map[string]string{"name": "shout", "arguments": string(args)},
+			}}})
+		} else {
+			deltas = append(deltas, map[string]interface{}{"content": "done: "}, map[string]interface{}{"content": last.Content})
+		}
+
+		if !req.Stream {
+			msg := map[string]interface{}{"role": "assistant"}
+			content := ""
+			for _, delta := range deltas {
+				if text, ok := delta["content"].(string); ok {
+					content += text
+				}
+				if calls, ok := delta["tool_calls"]; ok {
+					msg["tool_calls"] = calls
+				}
+			}
+			msg["content"] = content
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "x", "choices": []interface{}{
+				map[string]interface{}{"index": 0, "message": msg, "finish_reason": "stop"},
+			}})
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range deltas {
+			chunk := map[string]interface{}{"id": "x", "choices": []interface{}{map[string]interface{}{"index": 0, "delta": delta}}}
+			data, _ := json.Marshal(chunk)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestGateway starts a gateway with auth enabled in front of a fake
+// model and returns it, its HTTP server and a chat-scoped API key
+func newTestGateway(t *testing.T) (*Server, *httptest.Server, string) {
+	t.Helper()
+	return newTestGatewayWith(t, "")
+}
+
+// newTestGatewayWith is newTestGateway with gatewayConfig added to the
+// gateway section
+func newTestGatewayWith(t *testing.T, gatewayConfig string) (*Server, *httptest.Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	model := fakeModel(t)
+	cfgFile := filepath.Join(dir, "goclaw.yaml")
+	err := os.WriteFile(cfgFile, []byte(fmt.Sprintf(`
+zhipu:
+  api_key: "test"
+  base_url: %q
+agent:
+  approval_tools: ["shout"]
+memory:
+  file_path: %q
+  workspace: %q
+gateway:
+  auth: true
+  allowed_origins: ["https://ok.example.com"]
+%s
+`, model.URL, filepath.Join(dir, "goclaw.db"), filepath.Join(dir, "ws"), gatewayConfig)), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := memory.New(cfg.Memory.FilePath, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := tools.New()
+	reg.Register(shoutTool{})
+	s, err := New(cfg, store, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, token, err := s.Keys().Create(APIKey{Name: "test", Scopes: []string{ScopeChat}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv, token
+}
+
+func wsURL(srv *httptest.Server, query string) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?" + query
+}
+
+// runWSTurn sends text and collects the events of the turn, answering
+// approval requests with approve
+func runWSTurn(t *testing.T, conn *WSConn, session, text string, approve bool) []wsEvent {
+	t.Helper()
+	conn.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := conn.WriteJSON(wsEvent{Type: "message", Session: session, Text: text}); err != nil {
+		t.Fatal(err)
+	}
+	var events []wsEvent
+	for {
+		var ev wsEvent
+		if err := conn.ReadJSON(&ev); err != nil {
+			t.Fatalf("after %+v: %v", events, err)
+		}
+		events = append(events, ev)
+		switch ev.Type {
+		case "approval_request":
+			if err := conn.WriteJSON(wsEvent{Type: "approval", CallID: ev.CallID, Approved: approve}); err != nil {
+				t.Fatal(err)
+			}
+		case "message", "error":
+			return events
+		}
+	}
+}
+
+func eventTypes(events []wsEvent) string {
+	types := make([]string, len(events))
+	for i, ev := range events {
+		types[i] = ev.Type
+	}
+	return strings.Join(types, ",")
+}
+
+func TestWebSocketTurn(t *testing.T) {
+	_, srv, token := newTestGateway(t)
+	conn, err := DialWebSocket(wsURL(srv, "session=s1&token="+token), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	events := runWSTurn(t, conn, "", "hello", true)
+	if got, want := eventTypes(events), "approval_request,tool_start,tool_finish,delta,delta,message"; got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+	for _, ev := range events {
+		if ev.Session != "s1" {
+			t.Errorf("%s event has session %q, want s1", ev.Type, ev.Session)
+		}
+	}
+	if ev := events[0]; ev.Name != "shout" || ev.CallID != "call-1" || ev.Arguments != `{"text":"hello"}` {
+		t.Errorf("approval_request = %+v", ev)
+	}
+	if ev := events[2]; ev.Result != "HELLO" || ev.Failed {
+		t.Errorf("tool_finish = %+v", ev)
+	}
+	if events[3].Text+events[4].Text != "done: HELLO" || events[5].Text != "done: HELLO" {
+		t.Errorf("deltas %q %q, message %q", events[3].Text, events[4].Text, events[5].Text)
+	}
+}
+
+func TestWebSocketDeniedApproval(t *testing.T) {
+	_, srv, token := newTestGateway(t)
+	conn, err := DialWebSocket(wsURL(srv, "token="+token), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	events := runWSTurn(t, conn, "s2", "hello", false)
+	if got, want := eventTypes(events), "approval_request,tool_finish,delta,delta,message"; got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+	if ev := events[1]; !ev.Failed || !strings.Contains(ev.Result, "denied") {
+		t.Errorf("tool_finish = %+v, want a denial", ev)
+	}
+	if events[0].Session != "s2" {
+		t.Errorf("session = %q, want s2", events[0].Session)
+	}
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	_, srv, token := newTestGateway(t)
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	tests := []struct {
+		name    string
+		query   string
+		origin  string
+		wantErr string
+	}{
+		{"no token", "", "", "401"},
+		{"bad token", "token=nope", "", "401"},
+		{"bad session", "session=a/b&token=" + token, "", "400"},
+		{"cross origin", "token=" + token, "https://evil.example.com", "403"},
+		{"same origin", "token=" + token, "http://" + host, ""},
+		{"allowed origin", "token=" + token, "https://ok.example.com", ""},
+		{"no origin", "token=" + token, "", ""},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.origin != "" {
+			header.Set("Origin", tt.origin)
+		}
+		conn, err := DialWebSocket(wsURL(srv, tt.query), header)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error %v, want %s", tt.name, err, tt.wantErr)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+	}
+}
+
+func TestWebSocketDisconnectBeforeApproval(t *testing.T) {
+	_, srv, token := newTestGateway(t)
+	conn, err := DialWebSocket(wsURL(srv, "session=s3&token="+token), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteJSON(wsEvent{Type: "message", Text: "slow hello"}); err != nil {
+		t.Fatal(err)
+	}
+	// Drop the connection without a close frame while the model is still
+	// thinking, before the approval request
+	time.Sleep(50 * time.Millisecond)
+	conn.conn.Close()
+
+	// The abandoned turn must not hold the session until approvalTimeout
+	conn, err = DialWebSocket(wsURL(srv, "session=s3&token="+token), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	start := time.Now()
+	events := runWSTurn(t, conn, "", "hello", true)
+	if last := events[len(events)-1]; last.Type != "message" || last.Text != "done: HELLO" {
+		t.Fatalf("events = %+v", events)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("turn took %s; the session was held by the disconnected client", elapsed)
+	}
+}
@@ -0,0 +1,79 @@
+package lineedit
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Completer returns candidates for the text before the cursor. Each
+// candidate replaces line[start:pos].
+type Completer func(line []rune, pos int) (start int, candidates []string)
+
+// WordStart returns the index where the whitespace-separated word ending
+// at pos begins
+func WordStart(line []rune, pos int) int {
+	start := pos
+	for start > 0 && line[start-1] != ' ' && line[start-1] != '\t' && line[start-1] != '\n' {
+		start--
+	}
+	return start
+}
+
+// PathCandidates lists the files whose path starts with prefix, written
+// the way prefix is written (relative, absolute or under ~). Directories
+// end in a slash; hidden files are only offered once prefix names a dot.
+func PathCandidates(prefix string) []string {
+	dir, base := filepath.Split(prefix)
+	lookup := dir
+	if strings.HasPrefix(lookup, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			lookup = filepath.Join(home, lookup[2:])
+		}
+	}
+	if lookup == "" {
+		lookup = "."
+	}
+
+	entries, err := os.ReadDir(lookup)
+	if err != nil {
+		return nil
+	}
+	var candidates []string
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		isDir := e.IsDir()
+		if e.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(lookup, name)); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		if isDir {
+			name += "/"
+		}
+		candidates = append(candidates, dir+name)
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// commonPrefix returns the longest prefix shared by all of words
+func commonPrefix(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	prefix := []rune(words[0])
+	for _, w := range words[1:] {
+		r := []rune(w)
+		n := 0
+		for n < len(prefix) && n < len(r) && prefix[n] == r[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
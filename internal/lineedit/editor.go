@@ -0,0 +1,586 @@
+// Package lineedit reads lines from a terminal with cursor movement,
+// history, multi-line input and tab completion. When stdin is not a
+// terminal it reads plain lines instead.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrInterrupted is returned by ReadLine when the user presses Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+// maxListed bounds the completion candidates printed at once
+const maxListed = 100
+
+// ansiPattern matches the escape sequences colored prompts contain
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;?]*[ -/]*[@-~]")
+
+// Editor reads lines from a terminal. Keys follow readline: arrows, Home,
+// End, Ctrl-A/E/B/F/K/U/W/L, Up and Down for history, Tab to complete.
+// Alt-Enter inserts a newline, as does Enter inside a block opened with
+// """; pasted text keeps its newlines.
+type Editor struct {
+	Prompt     string    // Printed before the first line, may be colored
+	ContPrompt string    // Printed before continuation lines
+	History    *History  // Optional; entered lines are added to it
+	Complete   Completer // Optional; called on Tab
+
+	in     *os.File
+	out    io.Writer
+	reader *bufio.Reader
+
+	mu        sync.Mutex
+	active    bool // A line is being edited in raw mode
+	buf       []rune
+	pos       int
+	cursorRow int // Row of the cursor below the prompt row after the last render
+	histIndex int
+	draft     []rune // The unsent line while browsing history
+}
+
+// New returns an editor reading in and drawing to out
+func New(in *os.File, out io.Writer) *Editor {
+	return &Editor{
+		Prompt:     "> ",
+		ContPrompt: "... ",
+		in:         in,
+		out:        out,
+		reader:     bufio.NewReader(in),
+	}
+}
+
+// ReadLine reads one message. It returns io.EOF on Ctrl-D at an empty
+// prompt or at the end of input, and ErrInterrupted on Ctrl-C. A message
+// wrapped in """ is returned without the quotes.
+func (e *Editor) ReadLine() (string, error) {
+	fd := int(e.in.Fd())
+	if !isTerminal(fd) {
+		return e.readPlain()
+	}
+	state, err := makeRaw(fd)
+	if err != nil {
+		return e.readPlain()
+	}
+	defer restore(fd, state)
+
+	e.mu.Lock()
+	io.WriteString(e.out, "\x1b[?2004h") // Bracketed paste
+	e.buf, e.pos, e.cursorRow, e.draft = nil, 0, 0, nil
+	e.histIndex = len(e.historyEntries())
+	e.active = true
+	e.render()
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		e.active = false
+		io.WriteString(e.out, "\x1b[?2004l")
+		e.mu.Unlock()
+	}()
+
+	for {
+		k, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+		e.mu.Lock()
+		line, done, err := e.handle(k)
+		e.mu.Unlock()
+		if err != nil || done {
+			return line, err
+		}
+	}
+}
+
+// Print writes text above the line being edited and redraws the line, so
+// output from other goroutines does not garble the prompt
+func (e *Editor) Print(text string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	if !e.active {
+		io.WriteString(e.out, text)
+		return
+	}
+	e.clear()
+	io.WriteString(e.out, text)
+	e.render()
+}
+
+// readPlain reads a line without editing, for piped input
+func (e *Editor) readPlain() (string, error) {
+	io.WriteString(e.out, e.Prompt)
+	var lines []string
+	for {
+		line, err := e.reader.ReadString('\n')
+		if err != nil && line == "" {
+			if len(lines) > 0 {
+				return unquote(strings.Join(lines, "\n")), nil
+			}
+			io.WriteString(e.out, "\n")
+			return "", err
+		}
+		lines = append(lines, strings.TrimRight(line, "\r\n"))
+		text := strings.Join(lines, "\n")
+		if !openBlock(text) || err != nil {
+			return unquote(text), nil
+		}
+		io.WriteString(e.out, e.ContPrompt)
+	}
+}
+
+// key is one keypress: a rune, a named special key or a paste
+type key struct {
+	r     rune
+	name  string
+	paste string
+}
+
+// readKey decodes the next keypress, including VT100 escape sequences
+func (e *Editor) readKey() (key, error) {
+	r, _, err := e.reader.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+	if r != 0x1b {
+		return key{r: r}, nil
+	}
+	// Terminals send escape sequences in one write, so an ESC with nothing
+	// after it is the Escape key itself; waiting for more would swallow
+	// the next keypress
+	if e.reader.Buffered() == 0 {
+		return key{name: "escape"}, nil
+	}
+
+	b, err := e.reader.ReadByte()
+	if err != nil {
+		return key{}, err
+	}
+	switch b {
+	case '[':
+		var seq []byte
+		for {
+			c, err := e.reader.ReadByte()
+			if err != nil {
+				return key{}, err
+			}
+			seq = append(seq, c)
+			if c >= 0x40 && c <= 0x7e {
+				break
+			}
+		}
+		if string(seq) == "200~" {
+			return e.readPaste()
+		}
+		return key{name: csiKeys[string(seq)]}, nil
+	case 'O':
+		c, err := e.reader.ReadByte()
+		if err != nil {
+			return key{}, err
+		}
+		return key{name: csiKeys[string(c)]}, nil
+	case '\r', '\n':
+		return key{name: "alt-enter"}, nil
+	case 'b', 'B':
+		return key{name: "word-left"}, nil
+	case 'f', 'F':
+		return key{name: "word-right"}, nil
+	case 0x7f, 0x08:
+		return key{name: "delete-word"}, nil
+	}
+	return key{}, nil
+}
+
+// csiKeys names the escape sequences of special keys, without ESC [
+var csiKeys = map[string]string{
+	"A": "up", "B": "down", "C": "right", "D": "left",
+	"H": "home", "F": "end", "1~": "home", "7~": "home", "4~": "end", "8~": "end",
+	"3~":   "delete",
+	"1;5C": "word-right", "1;5D": "word-left", "1;3C": "word-right", "1;3D": "word-left",
+}
+
+// readPaste reads a bracketed paste up to its end marker
+func (e *Editor) readPaste() (key, error) {
+	const end = "\x1b[201~"
+	var sb strings.Builder
+	for !strings.HasSuffix(sb.String(), end) {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return key{}, err
+		}
+		sb.WriteRune(r)
+	}
+	text := strings.TrimSuffix(sb.String(), end)
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return key{paste: text}, nil
+}
+
+// handle applies k to the line. done is set when the line was submitted.
+func (e *Editor) handle(k key) (line string, done bool, err error) {
+	switch {
+	case k.paste != "":
+		e.insert([]rune(strings.ReplaceAll(k.paste, "\t", "    ")))
+
+	case k.r == '\r' || k.r == '\n':
+		if openBlock(string(e.buf)) {
+			e.insert([]rune{'\n'})
+			break
+		}
+		e.finish("")
+		text := string(e.buf)
+		if e.History != nil {
+			if err := e.History.Add(text); err != nil {
+				fmt.Fprintf(e.out, "warning: %v\n", err)
+			}
+		}
+		return unquote(text), true, nil
+
+	case k.name == "alt-enter":
+		e.insert([]rune{'\n'})
+
+	case k.r == 3: // Ctrl-C
+		e.finish("^C")
+		return "", false, ErrInterrupted
+
+	case k.r == 4: // Ctrl-D
+		if len(e.buf) == 0 {
+			e.finish("")
+			return "", false, io.EOF
+		}
+		e.deleteRange(e.pos, e.pos+1)
+
+	case k.r == 1 || k.name == "home":
+		e.pos = e.lineStart(e.pos)
+	case k.r == 5 || k.name == "end":
+		e.pos = e.lineEnd(e.pos)
+	case k.r == 2 || k.name == "left":
+		if e.pos > 0 {
+			e.pos--
+		}
+	case k.r == 6 || k.name == "right":
+		if e.pos < len(e.buf) {
+			e.pos++
+		}
+	case k.name == "word-left":
+		e.pos = e.wordLeft(e.pos)
+	case k.name == "word-right":
+		for e.pos < len(e.buf) && isSpace(e.buf[e.pos]) {
+			e.pos++
+		}
+		for e.pos < len(e.buf) && !isSpace(e.buf[e.pos]) {
+			e.pos++
+		}
+
+	case k.r == 0x7f || k.r == 8: // Backspace
+		e.deleteRange(e.pos-1, e.pos)
+	case k.name == "delete":
+		e.deleteRange(e.pos, e.pos+1)
+	case k.r == 11: // Ctrl-K
+		e.deleteRange(e.pos, e.lineEnd(e.pos))
+	case k.r == 21: // Ctrl-U
+		e.deleteRange(e.lineStart(e.pos), e.pos)
+	case k.r == 23 || k.name == "delete-word":
+		e.deleteRange(e.wordLeft(e.pos), e.pos)
+
+	case k.r == 12: // Ctrl-L
+		io.WriteString(e.out, "\x1b[H\x1b[2J")
+		e.cursorRow = 0
+
+	case k.r == 16 || k.name == "up":
+		if start := e.lineStart(e.pos); start > 0 {
+			e.moveVertical(start, e.lineStart(start-1))
+		} else {
+			e.historyMove(-1)
+		}
+	case k.r == 14 || k.name == "down":
+		if end := e.lineEnd(e.pos); end < len(e.buf) {
+			e.moveVertical(e.lineStart(e.pos), end+1)
+		} else {
+			e.historyMove(1)
+		}
+
+	case k.r == '\t':
+		e.complete()
+
+	case k.r >= 0x20 && k.r != 0x7f:
+		e.insert([]rune{k.r})
+	}
+
+	e.render()
+	return "", false, nil
+}
+
+// finish moves below the line, showing mark at its end
+func (e *Editor) finish(mark string) {
+	e.pos = len(e.buf)
+	e.render()
+	io.WriteString(e.out, mark+"\r\n")
+	e.cursorRow = 0
+}
+
+func (e *Editor) insert(rs []rune) {
+	buf := make([]rune, 0, len(e.buf)+len(rs))
+	buf = append(buf, e.buf[:e.pos]...)
+	buf = append(buf, rs...)
+	e.buf = append(buf, e.buf[e.pos:]...)
+	e.pos += len(rs)
+}
+
+// deleteRange removes buf[from:to], clamped to the line
+func (e *Editor) deleteRange(from, to int) {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(e.buf) {
+		to = len(e.buf)
+	}
+	if from >= to {
+		return
+	}
+	e.buf = append(e.buf[:from], e.buf[to:]...)
+	e.pos = from
+}
+
+// lineStart and lineEnd bound the row of a multi-line message holding pos
+func (e *Editor) lineStart(pos int) int {
+	for pos > 0 && e.buf[pos-1] != '\n' {
+		pos--
+	}
+	return pos
+}
+
+func (e *Editor) lineEnd(pos int) int {
+	for pos < len(e.buf) && e.buf[pos] != '\n' {
+		pos++
+	}
+	return pos
+}
+
+// moveVertical moves to the row starting at target, keeping the column of
+// the row starting at from where possible
+func (e *Editor) moveVertical(from, target int) {
+	col := e.pos - from
+	if end := e.lineEnd(target); target+col > end {
+		e.pos = end
+	} else {
+		e.pos = target + col
+	}
+}
+
+func (e *Editor) wordLeft(pos int) int {
+	for pos > 0 && isSpace(e.buf[pos-1]) {
+		pos--
+	}
+	for pos > 0 && !isSpace(e.buf[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n'
+}
+
+func (e *Editor) historyEntries() []string {
+	if e.History == nil {
+		return nil
+	}
+	return e.History.Entries()
+}
+
+// historyMove steps through history, keeping the unsent line as a draft
+func (e *Editor) historyMove(delta int) {
+	entries := e.historyEntries()
+	next := e.histIndex + delta
+	if next < 0 || next > len(entries) {
+		return
+	}
+	if e.histIndex == len(entries) {
+		e.draft = append([]rune(nil), e.buf...)
+	}
+	e.histIndex = next
+	if next == len(entries) {
+		e.buf = e.draft
+	} else {
+		e.buf = []rune(entries[next])
+	}
+	e.pos = len(e.buf)
+}
+
+// complete inserts the single or common completion, or lists the
+// candidates when Tab cannot narrow them further
+func (e *Editor) complete() {
+	if e.Complete == nil {
+		return
+	}
+	start, candidates := e.Complete(e.buf, e.pos)
+	if len(candidates) == 0 || start < 0 || start > e.pos {
+		io.WriteString(e.out, "\a")
+		return
+	}
+
+	word := e.buf[start:e.pos]
+	if len(candidates) == 1 {
+		completion := candidates[0]
+		if !strings.HasSuffix(completion, "/") {
+			completion += " "
+		}
+		e.deleteRange(start, e.pos)
+		e.insert([]rune(completion))
+		return
+	}
+	if prefix := []rune(commonPrefix(candidates)); len(prefix) > len(word) {
+		e.deleteRange(start, e.pos)
+		e.insert(prefix)
+		return
+	}
+
+	e.clear()
+	e.listCandidates(candidates)
+}
+
+// listCandidates prints candidates in columns
+func (e *Editor) listCandidates(candidates []string) {
+	more := 0
+	if len(candidates) > maxListed {
+		more = len(candidates) - maxListed
+		candidates = candidates[:maxListed]
+	}
+	colWidth := 0
+	for _, c := range candidates {
+		if w := StringWidth(c) + 2; w > colWidth {
+			colWidth = w
+		}
+	}
+	perRow := terminalWidth(int(e.in.Fd())) / colWidth
+	if perRow < 1 {
+		perRow = 1
+	}
+
+	var sb strings.Builder
+	for i, c := range candidates {
+		sb.WriteString(c)
+		if (i+1)%perRow == 0 || i == len(candidates)-1 {
+			sb.WriteString("\r\n")
+		} else {
+			sb.WriteString(strings.Repeat(" ", colWidth-StringWidth(c)))
+		}
+	}
+	if more > 0 {
+		fmt.Fprintf(&sb, "... and %d more\r\n", more)
+	}
+	io.WriteString(e.out, sb.String())
+}
+
+// clear erases the rendered line, leaving the cursor where it began
+func (e *Editor) clear() {
+	var sb strings.Builder
+	sb.WriteString("\r")
+	if e.cursorRow > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dA", e.cursorRow)
+	}
+	sb.WriteString("\x1b[J")
+	io.WriteString(e.out, sb.String())
+	e.cursorRow = 0
+}
+
+// render redraws the prompt and line, wrapping at the terminal width, and
+// places the cursor at pos
+func (e *Editor) render() {
+	width := terminalWidth(int(e.in.Fd()))
+	var sb strings.Builder
+	sb.WriteString("\r")
+	if e.cursorRow > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dA", e.cursorRow)
+	}
+	sb.WriteString("\x1b[J")
+
+	row, col := 0, 0
+	// writePrompt writes a prompt, tracking where it wraps
+	writePrompt := func(prompt string) {
+		sb.WriteString(prompt)
+		col += visibleWidth(prompt)
+		for col >= width {
+			row++
+			col -= width
+		}
+	}
+	writePrompt(e.Prompt)
+
+	curRow, curCol := row, col
+	for i, r := range e.buf {
+		if i == e.pos {
+			curRow, curCol = row, col
+		}
+		if r == '\n' {
+			sb.WriteString("\r\n")
+			row, col = row+1, 0
+			writePrompt(e.ContPrompt)
+			continue
+		}
+		w := runeWidth(r)
+		if w == 0 && r < 0x20 {
+			continue
+		}
+		if col+w > width {
+			sb.WriteString("\r\n")
+			row, col = row+1, 0
+		}
+		sb.WriteRune(r)
+		col += w
+		// Wrap now so the cursor never sits in the terminal's pending
+		// wrap state
+		if col >= width {
+			sb.WriteString("\r\n")
+			row, col = row+1, 0
+		}
+	}
+	if e.pos >= len(e.buf) {
+		curRow, curCol = row, col
+	}
+
+	if row > curRow {
+		fmt.Fprintf(&sb, "\x1b[%dA", row-curRow)
+	}
+	sb.WriteString("\r")
+	if curCol > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dC", curCol)
+	}
+	e.cursorRow = curRow
+	io.WriteString(e.out, sb.String())
+}
+
+func visibleWidth(s string) int {
+	return StringWidth(ansiPattern.ReplaceAllString(s, ""))
+}
+
+// openBlock reports whether text starts a """ block that is not closed
+func openBlock(text string) bool {
+	t := strings.TrimSpace(text)
+	if !strings.HasPrefix(t, `"""`) {
+		return false
+	}
+	return len(t) < 6 || !strings.HasSuffix(t, `"""`)
+}
+
+// unquote strips the """ around a multi-line message
+func unquote(text string) string {
+	t := strings.TrimSpace(text)
+	if !strings.HasPrefix(t, `"""`) {
+		return text
+	}
+	t = strings.TrimPrefix(t, `"""`)
+	t = strings.TrimSuffix(t, `"""`)
+	return strings.Trim(t, "\r\n")
+}
@@ -0,0 +1,222 @@
+package lineedit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// newTestEditor returns an editor that reads keys from input and draws to
+// the returned buffer. Its terminal is a pipe, so lines are 80 columns.
+func newTestEditor(t *testing.T, input string) (*Editor, *bytes.Buffer) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		r.Close()
+		w.Close()
+	})
+	var out bytes.Buffer
+	e := New(r, &out)
+	e.reader = bufio.NewReader(strings.NewReader(input))
+	return e, &out
+}
+
+// editLine runs the key loop of ReadLine without switching the terminal
+// to raw mode
+func editLine(e *Editor) (string, error) {
+	e.buf, e.pos, e.cursorRow, e.draft = nil, 0, 0, nil
+	e.histIndex = len(e.historyEntries())
+	for {
+		k, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+		line, done, err := e.handle(k)
+		if err != nil || done {
+			return line, err
+		}
+	}
+}
+
+func TestEditorKeys(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"plain", "hello\r", "hello"},
+		{"newline submits", "hello\n", "hello"},
+		{"left arrow", "helo\x1b[Dl\r", "hello"},
+		{"Ctrl-B and Ctrl-F", "ac\x02\x02\x06b\r", "abc"},
+		{"Ctrl-A", "world\x01hello \r", "hello world"},
+		{"Ctrl-E", "bc\x01a\x05d\r", "abcd"},
+		{"Home and End keys", "b\x1b[Ha\x1b[Fc\x1bOHx\r", "xabc"},
+		{"backspace", "abx\x7fc\r", "abc"},
+		{"backspace at start", "\x7f\x08a\r", "a"},
+		{"delete", "abxc\x1b[D\x1b[D\x1b[3~\r", "abc"},
+		{"Ctrl-D deletes under the cursor", "abc\x02\x04\r", "ab"},
+		{"Ctrl-K", "abc def\x01\x06\x06\x06\x0b\r", "abc"},
+		{"Ctrl-U", "abc def\x15x\r", "x"},
+		{"Ctrl-W", "abc def  \x17\r", "abc "},
+		{"Alt-Backspace", "abc def\x1b\x7f\r", "abc "},
+		{"Alt-B", "abc def\x1bbX\r", "abc Xdef"},
+		{"Ctrl-Left", "abc def\x1b[1;5DX\r", "abc Xdef"},
+		{"Alt-F", "abc def\x01\x1bfX\r", "abcX def"},
+		{"Ctrl-Right", "abc def\x01\x1b[1;5CX\r", "abcX def"},
+		{"unknown sequence", "\x1b[99~\x1b[5;9Za\r", "a"},
+		{"control keys are ignored", "a\x00\x1fb\r", "ab"},
+		{"wide runes", "中文\x7f字\r", "中字"},
+		{"Alt-Enter", "a\x1b\rb\r", "a\nb"},
+		{"quoted block", "\"\"\"\rline 1\rline 2\r\"\"\"\r", "line 1\nline 2"},
+		{"one-line quotes", "\"\"\"hi\"\"\"\r", "hi"},
+		{"paste", "\x1b[200~x\r\ny\tz\rw\x1b[201~!\r", "x\ny    z\nw!"},
+		{"up and down between rows", "ab\x1b\rcdef\x1b[AX\x1b[BY\r", "abX\ncdeYf"},
+		{"up keeps the column", "abcd\x1b\rxy\x01\x06\x10Z\r", "aZbcd\nxy"},
+	}
+	for _, tt := range tests {
+		e, _ := newTestEditor(t, tt.input)
+		got, err := editLine(e)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestEditorEndings(t *testing.T) {
+	tests := []struct {
+		name, input string
+		want        error
+		wantOut     string
+	}{
+		{"Ctrl-C", "abc\x03", ErrInterrupted, "^C\r\n"},
+		{"Ctrl-D at an empty prompt", "\x04", io.EOF, "\r\n"},
+		{"end of input", "abc", io.EOF, ""},
+	}
+	for _, tt := range tests {
+		e, out := newTestEditor(t, tt.input)
+		if _, err := editLine(e); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.want)
+		}
+		if !strings.HasSuffix(out.String(), tt.wantOut) {
+			t.Errorf("%s: output %q", tt.name, out.String())
+		}
+	}
+}
+
+func TestEditorHistory(t *testing.T) {
+	history, err := LoadHistory("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history.Add("first")
+	history.Add("multi\nline")
+
+	tests := []struct {
+		name, input, want string
+	}{
+		// The last entry opens with the cursor on its last row, so the
+		// second Up moves within it
+		{"up", "draft\x1b[A\x1b[A\x1b[A\r", "first"},
+		{"past the oldest", "\x10\x10\x10\x10\x10\r", "first"},
+		{"down restores the draft", "draft\x1b[A\x1b[Bs\r", "drafts"},
+		{"down at the draft", "x\x1b[B\r", "x"},
+	}
+	for _, tt := range tests {
+		e, _ := newTestEditor(t, tt.input)
+		e.History = history
+		got, err := editLine(e)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	// Entered lines are added unless they repeat the last one
+	want := []string{"first", "multi\nline", "first", "drafts", "x"}
+	if got := history.Entries(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("history %q, want %q", got, want)
+	}
+}
+
+func TestEditorComplete(t *testing.T) {
+	words := []string{"apple", "apricot", "banana", "dir/"}
+	complete := func(line []rune, pos int) (int, []string) {
+		start := WordStart(line, pos)
+		var candidates []string
+		for _, w := range words {
+			if strings.HasPrefix(w, string(line[start:pos])) {
+				candidates = append(candidates, w)
+			}
+		}
+		return start, candidates
+	}
+
+	tests := []struct {
+		name, input, want, wantOut string
+	}{
+		{"single candidate", "eat ban\t\r", "eat banana ", ""},
+		{"directory", "di\tx\r", "dir/x", ""},
+		{"common prefix", "a\t\r", "ap", ""},
+		{"ambiguous", "ap\t\r", "ap", "apple    apricot\r\n"},
+		{"no candidate", "x\t\r", "x", "\a"},
+		{"mid-line", "ban tail\x01\x06\x06\x06\t\r", "banana  tail", ""},
+	}
+	for _, tt := range tests {
+		e, out := newTestEditor(t, tt.input)
+		e.Complete = complete
+		got, err := editLine(e)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+		if !strings.Contains(out.String(), tt.wantOut) {
+			t.Errorf("%s: output %q does not contain %q", tt.name, out.String(), tt.wantOut)
+		}
+	}
+}
+
+func TestEditorRender(t *testing.T) {
+	// 2 prompt columns and 90 runes wrap once on an 80-column terminal
+	e, out := newTestEditor(t, strings.Repeat("a", 90))
+	e.Prompt = "\x1b[32m> \x1b[0m"
+	editLine(e)
+	if e.cursorRow != 1 || !strings.HasSuffix(out.String(), "\r\x1b[12C") {
+		t.Errorf("cursor row %d, output ends %q", e.cursorRow, out.String()[out.Len()-12:])
+	}
+
+	// Double-width runes never straddle the edge
+	e, out = newTestEditor(t, strings.Repeat("a", 77)+"中")
+	editLine(e)
+	if last := out.String()[strings.LastIndex(out.String(), "\x1b[J"):]; !strings.Contains(last, strings.Repeat("a", 77)+"\r\n中") {
+		t.Errorf("last render %q", last)
+	}
+}
+
+func TestReadLinePlain(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go func() {
+		io.WriteString(w, "one\r\n\"\"\"\ntwo\nthree\n\"\"\"\n\"\"\"\nunclosed")
+		w.Close()
+	}()
+
+	var out bytes.Buffer
+	e := New(r, &out)
+	for _, want := range []string{"one", "two\nthree", "unclosed"} {
+		if got, err := e.ReadLine(); err != nil || got != want {
+			t.Errorf("ReadLine = %q, %v; want %q", got, err, want)
+		}
+	}
+	if _, err := e.ReadLine(); err != io.EOF {
+		t.Errorf("at the end: %v", err)
+	}
+	if got := out.String(); got != "> > ... ... ... > ... > \n" {
+		t.Errorf("prompts %q", got)
+	}
+}
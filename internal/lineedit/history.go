@@ -0,0 +1,132 @@
+package lineedit
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// History is the list of entered lines, oldest first, optionally kept in
+// a file. Multi-line entries are stored on one line with \n escaped.
+type History struct {
+	path    string
+	max     int
+	entries []string
+}
+
+// LoadHistory reads the history file at path, keeping at most max
+// entries. An empty path keeps history in memory only; a missing file is
+// not an error.
+func LoadHistory(path string, max int) (*History, error) {
+	if max <= 0 {
+		max = 1000
+	}
+	h := &History{path: path, max: max}
+	if path == "" {
+		return h, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return h, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, unescapeHistory(line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return h, fmt.Errorf("failed to read history: %w", err)
+	}
+	if len(h.entries) > max {
+		h.entries = h.entries[len(h.entries)-max:]
+		if err := h.rewrite(); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
+
+// Entries returns the history, oldest first
+func (h *History) Entries() []string {
+	return h.entries
+}
+
+// Add appends line unless it is blank or repeats the last entry
+func (h *History) Add(line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == line {
+		return nil
+	}
+	h.entries = append(h.entries, line)
+	if h.path == "" {
+		return nil
+	}
+	// The file itself is trimmed by the next LoadHistory
+	if len(h.entries) > h.max {
+		h.entries = h.entries[len(h.entries)-h.max:]
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(escapeHistory(line) + "\n"); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// rewrite replaces the history file with the current entries
+func (h *History) rewrite() error {
+	var sb strings.Builder
+	for _, entry := range h.entries {
+		sb.WriteString(escapeHistory(entry))
+		sb.WriteByte('\n')
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sb.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		return fmt.Errorf("failed to replace history: %w", err)
+	}
+	return nil
+}
+
+func escapeHistory(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+func unescapeHistory(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' {
+				sb.WriteByte('\n')
+			} else {
+				sb.WriteByte(s[i])
+			}
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,48 @@
+package lineedit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "history")
+	h, err := LoadHistory(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"one", "  ", "two", "two", `back\slash`, "multi\nline"} {
+		if err := h.Add(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"two", `back\slash`, "multi\nline"}
+	if got := h.Entries(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("entries %q, want %q", got, want)
+	}
+
+	// The file keeps one line per entry until the next load trims it
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "one\ntwo\nback\\\\slash\nmulti\\nline\n" {
+		t.Errorf("file %q", got)
+	}
+	h, err = LoadHistory(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.Entries(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("loaded %q, want %q", got, want)
+	}
+	if data, _ := os.ReadFile(path); strings.HasPrefix(string(data), "one\n") {
+		t.Errorf("file not trimmed: %q", data)
+	}
+
+	if h, err := LoadHistory(filepath.Join(t.TempDir(), "missing"), 0); err != nil || len(h.Entries()) != 0 {
+		t.Errorf("missing file: %v, %v", h.Entries(), err)
+	}
+}
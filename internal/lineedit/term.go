@@ -0,0 +1,12 @@
+package lineedit
+
+// IsTerminal reports whether fd is a terminal
+func IsTerminal(fd int) bool {
+	return isTerminal(fd)
+}
+
+// TerminalWidth returns the number of columns of the terminal fd, or 80
+// when it is unknown
+func TerminalWidth(fd int) int {
+	return terminalWidth(fd)
+}
@@ -0,0 +1,267 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/fatih/color"
+)
+
+var (
+	keywordColor  = color.New(color.FgMagenta)
+	stringColor   = color.New(color.FgGreen)
+	commentColor  = color.New(color.FgHiBlack)
+	numberColor   = color.New(color.FgYellow)
+	functionColor = color.New(color.FgBlue)
+)
+
+// language describes the tokens highlighted in one language
+type language struct {
+	keywords       map[string]bool
+	lineComments   []string
+	blockComment   [2]string
+	quotes         string // Characters that open a string
+	multiline      string // Quotes whose strings may span lines
+	caseFold       bool   // Keywords are case-insensitive
+	keyBeforeColon bool   // Identifiers before ":" are keys, as in YAML
+}
+
+func keywordSet(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var cLike = language{
+	keywords: keywordSet(`auto break case catch char class const continue default delete do double else enum
+		extends extern false final finally float for friend goto if implements import inline instanceof int
+		interface long namespace new null nullptr package private protected public return short signed sizeof
+		static struct super switch template this throw throws true try typedef typename union unsigned using
+		var virtual void volatile while bool string fun val when object override suspend data internal`),
+	lineComments: []string{"//"},
+	blockComment: [2]string{"/*", "*/"},
+	quotes:       `"'`,
+}
+
+var languages = map[string]language{
+	"go": {
+		keywords: keywordSet(`break case chan const continue default defer else fallthrough for func go goto if
+			import interface map package range return select struct switch type var true false nil iota
+			bool byte error int int8 int16 int32 int64 rune string uint uint8 uint16 uint32 uint64 float32
+			float64 any append cap close copy delete len make new panic print println recover`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+		multiline:    "`",
+	},
+	"python": {
+		keywords: keywordSet(`and as assert async await break class continue def del elif else except False
+			finally for from global if import in is lambda None nonlocal not or pass raise return True try
+			while with yield self print len range`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	},
+	"js": {
+		keywords: keywordSet(`async await break case catch class const continue debugger default delete do else
+			export extends false finally for from function if import in instanceof let new null of return
+			static super switch this throw true try typeof undefined var void while yield interface type
+			enum implements private public protected readonly string number boolean any unknown never`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+		multiline:    "`",
+	},
+	"rust": {
+		keywords: keywordSet(`as async await break const continue crate dyn else enum extern false fn for if impl
+			in let loop match mod move mut pub ref return self Self static struct super trait true type
+			unsafe use where while Some None Ok Err String Vec Option Result`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"`,
+	},
+	"sh": {
+		keywords: keywordSet(`if then else elif fi for while until do done case esac in function return exit
+			export local readonly echo cd set unset source`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	},
+	"sql": {
+		keywords: keywordSet(`select from where and or not insert into values update set delete create table
+			drop alter add index primary key foreign references join left right inner outer on as group by
+			order having limit offset null is in like between distinct union all case when then else end
+			count sum avg min max integer text varchar default exists if begin commit rollback`),
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `'"`,
+		caseFold:     true,
+	},
+	"yaml": {
+		keywords:       keywordSet(`true false null yes no on off`),
+		lineComments:   []string{"#"},
+		quotes:         `"'`,
+		keyBeforeColon: true,
+	},
+	"json": {
+		keywords: keywordSet(`true false null`),
+		quotes:   `"`,
+	},
+	"c": cLike,
+}
+
+// languageAliases maps fence info strings to entries of languages
+var languageAliases = map[string]string{
+	"golang": "go", "py": "python", "python3": "python",
+	"javascript": "js", "jsx": "js", "ts": "js", "typescript": "js", "tsx": "js", "node": "js",
+	"rs":   "rust",
+	"bash": "sh", "shell": "sh", "zsh": "sh", "console": "sh", "shell-session": "sh",
+	"yml": "yaml", "jsonc": "json",
+	"cpp": "c", "c++": "c", "h": "c", "hpp": "c", "java": "c", "kotlin": "c", "kt": "c",
+	"cs": "c", "csharp": "c", "swift": "c",
+	"mysql": "sql", "sqlite": "sql", "postgresql": "sql", "postgres": "sql",
+}
+
+// highlight colors code written in lang. Unknown languages are returned
+// unchanged. Styling never spans a newline.
+func highlight(code, lang string) string {
+	lang = strings.ToLower(lang)
+	if alias, ok := languageAliases[lang]; ok {
+		lang = alias
+	}
+	l, ok := languages[lang]
+	if !ok {
+		return code
+	}
+
+	var sb strings.Builder
+	emit := func(c *color.Color, text string) {
+		if c == nil {
+			sb.WriteString(text)
+			return
+		}
+		for i, part := range strings.Split(text, "\n") {
+			if i > 0 {
+				sb.WriteByte('\n')
+			}
+			if part != "" {
+				sb.WriteString(c.Sprint(part))
+			}
+		}
+	}
+
+	rs := []rune(code)
+	for i := 0; i < len(rs); {
+		if open := l.blockComment[0]; open != "" && hasPrefixAt(rs, i, open) {
+			j := i + len([]rune(open))
+			for j < len(rs) && !hasPrefixAt(rs, j, l.blockComment[1]) {
+				j++
+			}
+			if j < len(rs) {
+				j += len([]rune(l.blockComment[1]))
+			}
+			emit(commentColor, string(rs[i:j]))
+			i = j
+			continue
+		}
+		if isLineComment(l, rs, i) {
+			j := i
+			for j < len(rs) && rs[j] != '\n' {
+				j++
+			}
+			emit(commentColor, string(rs[i:j]))
+			i = j
+			continue
+		}
+
+		r := rs[i]
+		switch {
+		case strings.ContainsRune(l.quotes, r):
+			j := i + 1
+			for j < len(rs) && rs[j] != r {
+				if rs[j] == '\\' && r != '`' {
+					j++
+				} else if rs[j] == '\n' && !strings.ContainsRune(l.multiline, r) {
+					break
+				}
+				j++
+			}
+			if j < len(rs) && rs[j] == r {
+				j++
+			}
+			emit(stringColor, string(rs[i:j]))
+			i = j
+
+		case unicode.IsDigit(r) && (i == 0 || !isIdentRune(rs[i-1])):
+			j := i
+			for j < len(rs) && (isIdentRune(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			emit(numberColor, string(rs[i:j]))
+			i = j
+
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(rs) && (isIdentRune(rs[j]) || (l.keyBeforeColon && rs[j] == '-')) {
+				j++
+			}
+			ident := string(rs[i:j])
+			next := nextNonSpace(rs, j)
+			switch {
+			case l.keywords[ident] || (l.caseFold && l.keywords[strings.ToLower(ident)]):
+				emit(keywordColor, ident)
+			case l.keyBeforeColon && next == ':':
+				emit(functionColor, ident)
+			case next == '(':
+				emit(functionColor, ident)
+			default:
+				emit(nil, ident)
+			}
+			i = j
+
+		default:
+			sb.WriteRune(r)
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// isLineComment reports whether a line comment starts at rs[i]. A shell
+// "#" only starts one at the beginning of a word.
+func isLineComment(l language, rs []rune, i int) bool {
+	for _, prefix := range l.lineComments {
+		if !hasPrefixAt(rs, i, prefix) {
+			continue
+		}
+		if prefix == "#" && i > 0 && !unicode.IsSpace(rs[i-1]) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func nextNonSpace(rs []rune, i int) rune {
+	for i < len(rs) && (rs[i] == ' ' || rs[i] == '\t') {
+		i++
+	}
+	if i < len(rs) {
+		return rs[i]
+	}
+	return 0
+}
+
+func hasPrefixAt(rs []rune, i int, prefix string) bool {
+	for _, r := range prefix {
+		if i >= len(rs) || rs[i] != r {
+			return false
+		}
+		i++
+	}
+	return true
+}
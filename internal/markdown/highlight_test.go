@@ -0,0 +1,49 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestHighlight(t *testing.T) {
+	saved := color.NoColor
+	color.NoColor = false
+	defer func() { color.NoColor = saved }()
+
+	k := func(s string) string { return keywordColor.Sprint(s) }
+	str := func(s string) string { return stringColor.Sprint(s) }
+	com := func(s string) string { return commentColor.Sprint(s) }
+	num := func(s string) string { return numberColor.Sprint(s) }
+	fn := func(s string) string { return functionColor.Sprint(s) }
+
+	tests := []struct {
+		name, code, lang, want string
+	}{
+		{"go", `func f() { return "x" } // done`, "go",
+			k("func") + " " + fn("f") + "() { " + k("return") + " " + str(`"x"`) + " } " + com("// done")},
+		{"alias and escapes", `x = 'it\'s' + 42`, "py", "x = " + str(`'it\'s'`) + " + " + num("42")},
+		{"identifiers with digits", "v2 = 3.5", "python", "v2 = " + num("3.5")},
+		{"block comments span lines", "/* a\nb */", "c", com("/* a") + "\n" + com("b */")},
+		{"raw strings span lines", "`a\nb`", "golang", str("`a") + "\n" + str("b`")},
+		{"strings end at a newline", "'a\nb'", "python", str("'a") + "\n" + "b" + str("'")},
+		{"shell # only starts a word", "echo a#b # c", "bash", k("echo") + " a#b " + com("# c")},
+		{"case-insensitive keywords", "SELECT 1", "SQL", k("SELECT") + " " + num("1")},
+		{"yaml keys", "name: true", "yml", fn("name") + ": " + k("true")},
+		{"unknown language", "func x", "brainfuck", "func x"},
+	}
+	for _, tt := range tests {
+		if got := highlight(tt.code, tt.lang); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Every line closes the styling it opens
+	for _, line := range strings.Split(highlight("/* a\n\nb */ `x\ny`", "go"), "\n") {
+		resets := strings.Count(line, "\x1b[0m")
+		if opens := strings.Count(line, "\x1b[") - resets; opens != resets {
+			t.Errorf("%d styles opened and %d closed in %q", opens, resets, line)
+		}
+	}
+}
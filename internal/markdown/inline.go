@@ -0,0 +1,326 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/fatih/color"
+	"github.com/user/goclaw2/internal/lineedit"
+)
+
+// style is a set of inline attributes
+type style int
+
+const (
+	styleBold style = 1 << iota
+	styleItalic
+	styleStrike
+	styleCode
+	styleLink
+	styleDim
+	styleHeading
+)
+
+// ansiPattern matches the escape sequences in styled text
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// visibleWidth is the number of columns s takes, ignoring styling
+func visibleWidth(s string) int {
+	return lineedit.StringWidth(ansiPattern.ReplaceAllString(s, ""))
+}
+
+// apply styles text for the terminal
+func (s style) apply(text string) string {
+	if s == 0 || text == "" {
+		return text
+	}
+	var attrs []color.Attribute
+	if s&styleHeading != 0 {
+		attrs = append(attrs, color.Bold, color.FgCyan)
+	}
+	if s&styleBold != 0 {
+		attrs = append(attrs, color.Bold)
+	}
+	if s&styleItalic != 0 {
+		attrs = append(attrs, color.Italic)
+	}
+	if s&styleStrike != 0 {
+		attrs = append(attrs, color.CrossedOut)
+	}
+	if s&styleCode != 0 {
+		attrs = append(attrs, color.FgYellow)
+	}
+	if s&styleLink != 0 {
+		attrs = append(attrs, color.Underline, color.FgBlue)
+	}
+	if s&styleDim != 0 {
+		attrs = append(attrs, color.Faint)
+	}
+	return color.New(attrs...).Sprint(text)
+}
+
+// segment is a run of text with one style
+type segment struct {
+	text  string
+	style style
+}
+
+// parseInline splits a paragraph into styled segments: `code`, **bold**,
+// *italic*, ~~strike~~, [links](url) and ![images](url)
+func parseInline(text string, base style) []segment {
+	var segs []segment
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			segs = append(segs, segment{plain.String(), base})
+			plain.Reset()
+		}
+	}
+
+	rs := []rune(text)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '\\' && i+1 < len(rs) && strings.ContainsRune("\\`*_~[]()#+-.!|", rs[i+1]):
+			plain.WriteRune(rs[i+1])
+			i++
+			continue
+
+		case r == '`':
+			ticks := countRun(rs, i, '`')
+			if end := findRun(rs, i+ticks, '`', ticks); end >= 0 {
+				flush()
+				code := strings.TrimSpace(string(rs[i+ticks : end]))
+				segs = append(segs, segment{code, base | styleCode})
+				i = end + ticks - 1
+				continue
+			}
+
+		case (r == '*' || r == '_') && i+1 < len(rs) && rs[i+1] == r:
+			if end := findDelim(rs, i+2, string([]rune{r, r})); end > i+2 && (r == '*' || !isWordRune(before(rs, i))) {
+				flush()
+				segs = append(segs, parseInline(string(rs[i+2:end]), base|styleBold)...)
+				i = end + 1
+				continue
+			}
+
+		case (r == '*' || r == '_') && i+1 < len(rs) && !unicode.IsSpace(rs[i+1]):
+			if end := findDelim(rs, i+1, string(r)); end > i+1 && (r == '*' || !isWordRune(before(rs, i))) {
+				flush()
+				segs = append(segs, parseInline(string(rs[i+1:end]), base|styleItalic)...)
+				i = end
+				continue
+			}
+
+		case r == '~' && i+1 < len(rs) && rs[i+1] == '~':
+			if end := findDelim(rs, i+2, "~~"); end > i+2 {
+				flush()
+				segs = append(segs, parseInline(string(rs[i+2:end]), base|styleStrike)...)
+				i = end + 1
+				continue
+			}
+
+		case r == '[' || (r == '!' && i+1 < len(rs) && rs[i+1] == '['):
+			image := r == '!'
+			start := i
+			if image {
+				start++
+			}
+			if label, url, end, ok := parseLink(rs, start); ok {
+				flush()
+				switch {
+				case image:
+					segs = append(segs, segment{"[image: " + label + "]", base | styleDim})
+				case label == url || label == "":
+					segs = append(segs, segment{url, base | styleLink})
+				default:
+					segs = append(segs, parseInline(label, base|styleLink)...)
+					segs = append(segs, segment{" (" + url + ")", base | styleDim})
+				}
+				i = end
+				continue
+			}
+		}
+		plain.WriteRune(r)
+	}
+	flush()
+	return segs
+}
+
+func countRun(rs []rune, i int, r rune) int {
+	n := 0
+	for i+n < len(rs) && rs[i+n] == r {
+		n++
+	}
+	return n
+}
+
+// findRun finds the next run of exactly n r's at or after i
+func findRun(rs []rune, i int, r rune, n int) int {
+	for i < len(rs) {
+		if rs[i] != r {
+			i++
+			continue
+		}
+		run := countRun(rs, i, r)
+		if run == n {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
+
+// findDelim finds the closing delimiter of an emphasis span that opened
+// before i. The span may not end with a space.
+func findDelim(rs []rune, i int, delim string) int {
+	d := []rune(delim)
+	for j := i; j+len(d) <= len(rs); j++ {
+		if rs[j] == '`' {
+			if end := findRun(rs, j+countRun(rs, j, '`'), '`', countRun(rs, j, '`')); end >= 0 {
+				j = end
+				continue
+			}
+		}
+		if string(rs[j:j+len(d)]) != delim || unicode.IsSpace(rs[j-1]) {
+			continue
+		}
+		// A single delimiter must not be half of a double one
+		if len(d) == 1 && j+1 < len(rs) && rs[j+1] == d[0] {
+			j++
+			continue
+		}
+		if d[0] == '_' && j+len(d) < len(rs) && isWordRune(rs[j+len(d)]) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// parseLink parses [label](url) at rs[i], returning the index of ")"
+func parseLink(rs []rune, i int) (label, url string, end int, ok bool) {
+	depth := 0
+	j := i
+	for ; j < len(rs); j++ {
+		if rs[j] == '[' {
+			depth++
+		} else if rs[j] == ']' {
+			depth--
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	if j+1 >= len(rs) || rs[j+1] != '(' {
+		return "", "", 0, false
+	}
+	k := j + 2
+	for k < len(rs) && rs[k] != ')' && rs[k] != ' ' {
+		k++
+	}
+	if k >= len(rs) || rs[k] != ')' {
+		return "", "", 0, false
+	}
+	return string(rs[i+1 : j]), string(rs[j+2 : k]), k, true
+}
+
+func before(rs []rune, i int) rune {
+	if i == 0 {
+		return ' '
+	}
+	return rs[i-1]
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// word is the unit of wrapping: pieces of text with no space between them
+type word struct {
+	pieces      []segment
+	width       int
+	spaceBefore bool
+}
+
+// splitWords breaks segments at spaces and around wide characters, which
+// may wrap without a space. Differently styled text with no space between,
+// such as "**bold**,", stays in one word.
+func splitWords(segs []segment) []word {
+	var words []word
+	var cur *word
+	space := false
+	lastWide := false
+	for _, seg := range segs {
+		for _, r := range seg.text {
+			if r == ' ' || r == '\t' || r == '\n' {
+				space = true
+				cur = nil
+				continue
+			}
+			w := lineedit.StringWidth(string(r))
+			wide := w == 2
+			if cur == nil || wide || lastWide {
+				words = append(words, word{spaceBefore: space && len(words) > 0})
+				cur = &words[len(words)-1]
+				space = false
+			}
+			lastWide = wide
+			if n := len(cur.pieces); n > 0 && cur.pieces[n-1].style == seg.style {
+				cur.pieces[n-1].text += string(r)
+			} else {
+				cur.pieces = append(cur.pieces, segment{string(r), seg.style})
+			}
+			cur.width += w
+		}
+	}
+	return words
+}
+
+// wrap lays segments out within width columns. The first line starts with
+// first and the others with rest, which should be equally wide.
+func wrap(segs []segment, width int, first, rest string) []string {
+	var lines []string
+	line := first
+	col := visibleWidth(first)
+	empty := true
+	newLine := func() {
+		lines = append(lines, strings.TrimRight(line, " "))
+		line = rest
+		col = visibleWidth(rest)
+		empty = true
+	}
+
+	for _, w := range splitWords(segs) {
+		need := w.width
+		if w.spaceBefore && !empty {
+			need++
+		}
+		if !empty && col+need > width {
+			newLine()
+		}
+		if w.spaceBefore && !empty {
+			line += " "
+			col++
+		}
+		for _, p := range w.pieces {
+			// Break words longer than a whole line
+			var run strings.Builder
+			for _, r := range p.text {
+				rw := lineedit.StringWidth(string(r))
+				if col+rw > width && !(empty && run.Len() == 0) {
+					line += p.style.apply(run.String())
+					run.Reset()
+					newLine()
+				}
+				run.WriteRune(r)
+				col += rw
+				empty = false
+			}
+			line += p.style.apply(run.String())
+		}
+	}
+	lines = append(lines, strings.TrimRight(line, " "))
+	return lines
+}
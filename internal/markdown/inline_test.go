@@ -0,0 +1,56 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// describe writes segments as text{style} for comparison
+func describe(segs []segment) string {
+	parts := make([]string, len(segs))
+	for i, s := range segs {
+		parts[i] = fmt.Sprintf("%s{%d}", s.text, s.style)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseInline(t *testing.T) {
+	b, i, s, c, l, d := styleBold, styleItalic, styleStrike, styleCode, styleLink, styleDim
+	tests := []struct {
+		text string
+		want []segment
+	}{
+		{"plain", []segment{{"plain", 0}}},
+		{"a **b** c", []segment{{"a ", 0}, {"b", b}, {" c", 0}}},
+		{"__b__ _i_ *i*", []segment{{"b", b}, {" ", 0}, {"i", i}, {" ", 0}, {"i", i}}},
+		{"**bold _both_**", []segment{{"bold ", b}, {"both", b | i}}},
+		{"~~gone~~", []segment{{"gone", s}}},
+		{"`a *b*` and ``x ` y``", []segment{{"a *b*", c}, {" and ", 0}, {"x ` y", c}}},
+		{"**`code` in bold**", []segment{{"code", b | c}, {" in bold", b}}},
+		{"snake_case_name and 2 * 3 * 4", []segment{{"snake_case_name and 2 * 3 * 4", 0}}},
+		{"**unclosed", []segment{{"**unclosed", 0}}},
+		{"[a **b**](u)", []segment{{"a ", l}, {"b", l | b}, {" (u)", d}}},
+		{"[u](u) [](v) ![alt](i.png)", []segment{{"u", l}, {" ", 0}, {"v", l}, {" ", 0}, {"[image: alt]", d}}},
+		{"[not a link] (x) [x](a b)", []segment{{"[not a link] (x) [x](a b)", 0}}},
+		{`\*\_\[x\]`, []segment{{"*_[x]", 0}}},
+	}
+	for _, tt := range tests {
+		if got, want := describe(parseInline(tt.text, 0)), describe(tt.want); got != want {
+			t.Errorf("%q:\n got %s\nwant %s", tt.text, got, want)
+		}
+	}
+}
+
+func TestWrapKeepsStyledWordsTogether(t *testing.T) {
+	plainColors(t)
+	// "**bold**," is one word: the comma does not wrap on its own
+	got := wrap(parseInline("aaaa **bold**, c", 0), 8, "", "")
+	if strings.Join(got, "|") != "aaaa|bold, c" {
+		t.Errorf("wrap = %q", got)
+	}
+	got = wrap(parseInline("one two three", 0), 10, "- ", "  ")
+	if strings.Join(got, "|") != "- one two|  three" {
+		t.Errorf("wrap with prefixes = %q", got)
+	}
+}
@@ -0,0 +1,359 @@
+// Package markdown renders the markdown in model responses for a
+// terminal: styled headings, lists, quotes and tables wrapped to the
+// terminal width, and syntax-highlighted code blocks.
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/user/goclaw2/internal/lineedit"
+)
+
+// Options control rendering
+type Options struct {
+	Width int // Columns to wrap at, default 80
+}
+
+// CodeBlock is a fenced code block
+type CodeBlock struct {
+	Lang string
+	Code string
+}
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	listPattern    = regexp.MustCompile(`^(\s*)([-*+]|\d{1,9}[.)])\s+(.*)$`)
+	rulePattern    = regexp.MustCompile(`^\s{0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	fencePattern   = regexp.MustCompile("^(\\s*)(`{3,}|~{3,})\\s*([^`\\s]*)")
+	tableSepCell   = regexp.MustCompile(`^\s*:?-{1,}:?\s*$`)
+)
+
+// Render formats markdown src for a terminal
+func Render(src string, opts Options) string {
+	if opts.Width <= 0 {
+		opts.Width = 80
+	}
+	r := &renderer{width: opts.Width}
+	r.blocks(splitLines(src), "")
+	return strings.TrimRight(strings.Join(r.out, "\n"), "\n")
+}
+
+// CodeBlocks returns the fenced code blocks of src, numbered from 1 in
+// the headers Render gives them
+func CodeBlocks(src string) []CodeBlock {
+	r := &renderer{width: 80}
+	r.blocks(splitLines(src), "")
+	return r.codes
+}
+
+func splitLines(src string) []string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	return strings.Split(strings.Trim(src, "\n"), "\n")
+}
+
+// parseFence parses the fenced code block opening at lines[i] and returns
+// the index of its last line. An unclosed fence runs to the end.
+func parseFence(lines []string, i int) (CodeBlock, int, bool) {
+	m := fencePattern.FindStringSubmatch(lines[i])
+	if m == nil {
+		return CodeBlock{}, 0, false
+	}
+	indent, fence := m[1], m[2]
+	var body []string
+	j := i + 1
+	for ; j < len(lines); j++ {
+		t := strings.TrimSpace(lines[j])
+		if strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+			break
+		}
+		// Fences nested in list items indent their code as well
+		line := lines[j]
+		for k := 0; k < len(indent) && strings.HasPrefix(line, " "); k++ {
+			line = line[1:]
+		}
+		body = append(body, line)
+	}
+	if j == len(lines) {
+		j--
+	}
+	return CodeBlock{Lang: m[3], Code: strings.Join(body, "\n")}, j, true
+}
+
+type renderer struct {
+	width int
+	out   []string
+	codes []CodeBlock // Code blocks rendered so far
+}
+
+// emit adds a line of output, collapsing repeated blank lines
+func (r *renderer) emit(line string) {
+	if line == "" && (len(r.out) == 0 || r.out[len(r.out)-1] == "") {
+		return
+	}
+	r.out = append(r.out, line)
+}
+
+// blocks renders lines, starting every output line with prefix
+func (r *renderer) blocks(lines []string, prefix string) {
+	// Lines are wrapped at full columns, leaving inner after the prefix
+	inner := r.width - visibleWidth(prefix)
+	if inner < 20 {
+		inner = 20
+	}
+	full := inner + visibleWidth(prefix)
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if block, end, ok := parseFence(lines, i); ok {
+			r.code(block, prefix)
+			i = end
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			r.emit("")
+
+		case headingPattern.MatchString(trimmed):
+			m := headingPattern.FindStringSubmatch(trimmed)
+			r.emit("")
+			wrapped := wrap(parseInline(m[2], styleHeading), full, prefix, prefix)
+			for _, l := range wrapped {
+				r.emit(l)
+			}
+			if len(m[1]) == 1 {
+				underline := 0
+				for _, l := range wrapped {
+					if w := visibleWidth(l) - visibleWidth(prefix); w > underline {
+						underline = w
+					}
+				}
+				r.emit(prefix + styleHeading.apply(strings.Repeat("═", underline)))
+			}
+			r.emit("")
+
+		case rulePattern.MatchString(line):
+			r.emit(prefix + styleDim.apply(strings.Repeat("─", inner)))
+
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(q, " "))
+			}
+			i--
+			r.blocks(quoted, prefix+styleDim.apply("│ "))
+
+		case isTableRow(line) && i+1 < len(lines) && isTableSeparator(lines[i+1]):
+			end := i + 2
+			for end < len(lines) && isTableRow(lines[end]) {
+				end++
+			}
+			r.table(lines[i:end], prefix, inner)
+			i = end - 1
+
+		case listPattern.MatchString(line):
+			i = r.listItem(lines, i, prefix, full)
+
+		default:
+			// A paragraph runs until a blank line or another block
+			text := []string{trimmed}
+			for i+1 < len(lines) && !startsBlock(lines, i+1) {
+				i++
+				text = append(text, strings.TrimSpace(lines[i]))
+			}
+			joined := strings.Join(text, " ")
+			for _, l := range wrap(parseInline(joined, 0), full, prefix, prefix) {
+				r.emit(l)
+			}
+		}
+	}
+}
+
+// startsBlock reports whether lines[i] ends a paragraph
+func startsBlock(lines []string, i int) bool {
+	line := lines[i]
+	t := strings.TrimSpace(line)
+	return t == "" || headingPattern.MatchString(t) || rulePattern.MatchString(line) ||
+		strings.HasPrefix(t, ">") || listPattern.MatchString(line) || fencePattern.MatchString(line) ||
+		(isTableRow(line) && i+1 < len(lines) && isTableSeparator(lines[i+1]))
+}
+
+// listItem renders the item at lines[i] with its continuation lines,
+// wrapped at width columns, and returns the index of its last line
+func (r *renderer) listItem(lines []string, i int, prefix string, width int) int {
+	m := listPattern.FindStringSubmatch(lines[i])
+	indent := strings.Repeat("  ", len(strings.ReplaceAll(m[1], "\t", "    "))/2)
+	marker := m[2]
+	if strings.ContainsAny(marker, "-*+") {
+		marker = "•"
+	}
+	text := m[3]
+	switch {
+	case strings.HasPrefix(text, "[ ] "):
+		marker, text = "☐", text[4:]
+	case strings.HasPrefix(text, "[x] "), strings.HasPrefix(text, "[X] "):
+		marker, text = "☑", text[4:]
+	}
+
+	// Following lines that start no block of their own continue the item
+	for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" && !startsBlock(lines, i+1) {
+		i++
+		text += " " + strings.TrimSpace(lines[i])
+	}
+
+	first := prefix + indent + styleBold.apply(marker) + " "
+	rest := prefix + indent + strings.Repeat(" ", lineedit.StringWidth(marker)+1)
+	for _, l := range wrap(parseInline(text, 0), width, first, rest) {
+		r.emit(l)
+	}
+	return i
+}
+
+// code renders a code block with a numbered header, so /copy can refer to it
+func (r *renderer) code(block CodeBlock, prefix string) {
+	r.codes = append(r.codes, block)
+	r.emit("")
+	header := fmt.Sprintf("╭─ [%d]", len(r.codes))
+	if block.Lang != "" {
+		header += " " + block.Lang
+	}
+	r.emit(prefix + styleDim.apply(header))
+	code := strings.ReplaceAll(block.Code, "\t", "    ")
+	for _, l := range strings.Split(highlight(code, block.Lang), "\n") {
+		r.emit(prefix + styleDim.apply("│ ") + l)
+	}
+	r.emit(prefix + styleDim.apply("╰─"))
+	r.emit("")
+}
+
+// isTableRow reports whether line looks like "| a | b |" or "a | b"
+func isTableRow(line string) bool {
+	t := strings.TrimSpace(line)
+	return strings.Contains(t, "|") && t != "|"
+}
+
+func isTableSeparator(line string) bool {
+	cells := tableCells(line)
+	if len(cells) == 0 {
+		return false
+	}
+	for _, c := range cells {
+		if !tableSepCell.MatchString(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// tableCells splits a table row, ignoring escaped pipes and the optional
+// outer pipes
+func tableCells(line string) []string {
+	t := strings.TrimSpace(line)
+	t = strings.TrimPrefix(t, "|")
+	if strings.HasSuffix(t, "|") && !strings.HasSuffix(t, `\|`) {
+		t = t[:len(t)-1]
+	}
+	var cells []string
+	var cur strings.Builder
+	for i := 0; i < len(t); i++ {
+		switch {
+		case t[i] == '\\' && i+1 < len(t) && t[i+1] == '|':
+			cur.WriteByte('|')
+			i++
+		case t[i] == '|':
+			cells = append(cells, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(t[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cur.String()))
+}
+
+// table draws rows (header, separator, body) with box characters. Tables
+// wider than the terminal are shown as written.
+func (r *renderer) table(rows []string, prefix string, width int) {
+	header := tableCells(rows[0])
+	aligns := tableCells(rows[1])
+	body := make([][]string, 0, len(rows)-2)
+	for _, row := range rows[2:] {
+		body = append(body, tableCells(row))
+	}
+
+	cols := len(header)
+	// Cells are rendered with inline styling before measuring them
+	render := func(cells []string, base style) []string {
+		out := make([]string, cols)
+		for c := 0; c < cols && c < len(cells); c++ {
+			for _, seg := range parseInline(cells[c], base) {
+				out[c] += seg.style.apply(seg.text)
+			}
+		}
+		return out
+	}
+	styled := [][]string{render(header, styleBold)}
+	for _, row := range body {
+		styled = append(styled, render(row, 0))
+	}
+
+	widths := make([]int, cols)
+	for _, row := range styled {
+		for c, cell := range row {
+			if w := visibleWidth(cell); w > widths[c] {
+				widths[c] = w
+			}
+		}
+	}
+	total := 1
+	for _, w := range widths {
+		total += w + 3
+	}
+	if total > width {
+		for _, row := range rows {
+			r.emit(prefix + row)
+		}
+		return
+	}
+
+	border := func(left, mid, right string) string {
+		parts := make([]string, cols)
+		for c, w := range widths {
+			parts[c] = strings.Repeat("─", w+2)
+		}
+		return prefix + styleDim.apply(left+strings.Join(parts, mid)+right)
+	}
+	line := func(cells []string) string {
+		var sb strings.Builder
+		sb.WriteString(prefix + styleDim.apply("│"))
+		for c, cell := range cells {
+			pad := widths[c] - visibleWidth(cell)
+			align := ""
+			if c < len(aligns) {
+				align = aligns[c]
+			}
+			left := 0
+			switch {
+			case strings.HasPrefix(align, ":") && strings.HasSuffix(align, ":"):
+				left = pad / 2
+			case strings.HasSuffix(align, ":"):
+				left = pad
+			}
+			sb.WriteString(" " + strings.Repeat(" ", left) + cell + strings.Repeat(" ", pad-left) + " ")
+			sb.WriteString(styleDim.apply("│"))
+		}
+		return sb.String()
+	}
+
+	r.emit(border("┌", "┬", "┐"))
+	r.emit(line(styled[0]))
+	r.emit(border("├", "┼", "┤"))
+	for _, row := range styled[1:] {
+		r.emit(line(row))
+	}
+	r.emit(border("└", "┴", "┘"))
+}
@@ -0,0 +1,95 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// plainColors turns styling off for the rest of the test
+func plainColors(t *testing.T) {
+	t.Helper()
+	saved := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = saved })
+}
+
+func TestRender(t *testing.T) {
+	plainColors(t)
+	tests := []struct {
+		name, src, want string
+	}{
+		{"headings and paragraphs", "# Title here\r\n\r\nSome *text* that is long enough to wrap around.\n\n\n\n## Sub ##",
+			"Title here\n══════════\n\nSome text that is long\nenough to wrap around.\n\nSub"},
+		{"lists", "- one\n- two\n  continued\n  - nested\n1. first\n2) second\n- [ ] todo\n- [x] done",
+			"• one\n• two continued\n  • nested\n1. first\n2) second\n☐ todo\n☑ done"},
+		{"wrapped list item", "- a list item long enough to wrap",
+			"• a list item long\n  enough to wrap"},
+		{"quote and rule", "> quoted text\n> more\n\n---",
+			"│ quoted text more\n\n" + strings.Repeat("─", 24)},
+		{"code blocks", "```go\nfunc main() {}\n```\ntext\n~~~\nplain\n~~~",
+			"╭─ [1] go\n│ func main() {}\n╰─\n\ntext\n\n╭─ [2]\n│ plain\n╰─"},
+		{"code in a list item", "- item\n  ```sh\n  echo hi\n  ```",
+			"• item\n\n╭─ [1] sh\n│ echo hi\n╰─"},
+		{"unclosed fence", "```\nunclosed", "╭─ [1]\n│ unclosed\n╰─"},
+		{"table", "| a | b | c |\n|:-|-:|:-:|\n| cell | xyz | mmmmm |",
+			"┌──────┬─────┬───────┐\n" +
+				"│ a    │   b │   c   │\n" +
+				"├──────┼─────┼───────┤\n" +
+				"│ cell │ xyz │ mmmmm │\n" +
+				"└──────┴─────┴───────┘"},
+		{"table wider than the terminal", "| aaaaaaaaaaaaaaaaaaaa | b |\n|-|-|\n| c | d |",
+			"| aaaaaaaaaaaaaaaaaaaa | b |\n|-|-|\n| c | d |"},
+		{"pipes without a separator", "a | b\nc | d", "a | b c | d"},
+		{"links and escapes", "see [docs](http://x.io) and [http://y.io](http://y.io) ![logo](l.png) \\*not\\*",
+			"see docs (http://x.io)\nand http://y.io [image:\nlogo] *not*"},
+		{"wide characters wrap anywhere", strings.Repeat("中文", 11),
+			strings.Repeat("中文", 6) + "\n" + strings.Repeat("中文", 5)},
+		{"long words are broken", strings.Repeat("x", 30), strings.Repeat("x", 24) + "\n" + strings.Repeat("x", 6)},
+	}
+	for _, tt := range tests {
+		if got := Render(tt.src, Options{Width: 24}); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+
+	// A narrow width still leaves 20 columns inside a quote
+	if got := Render("> "+strings.Repeat("y ", 15), Options{Width: 5}); got != "│ "+strings.Repeat("y ", 9)+"y\n│ "+strings.TrimSpace(strings.Repeat("y ", 5)) {
+		t.Errorf("narrow quote: %q", got)
+	}
+}
+
+func TestCodeBlocks(t *testing.T) {
+	src := "intro\n\n```py\nprint(1)\n\tindented\n```\n\n- item\n  ```\n  nested\n  ```\n"
+	blocks := CodeBlocks(src)
+	want := []CodeBlock{{Lang: "py", Code: "print(1)\n\tindented"}, {Lang: "", Code: "nested"}}
+	if len(blocks) != len(want) {
+		t.Fatalf("CodeBlocks = %+v", blocks)
+	}
+	for i := range want {
+		if blocks[i] != want[i] {
+			t.Errorf("block %d = %+v, want %+v", i+1, blocks[i], want[i])
+		}
+	}
+}
+
+func TestRenderStyles(t *testing.T) {
+	saved := color.NoColor
+	color.NoColor = false
+	defer func() { color.NoColor = saved }()
+
+	got := Render("# Head\n\n**bold** and `code`", Options{})
+	for _, want := range []string{
+		"\x1b[1;36mHead\x1b[0m",
+		"\x1b[1mbold\x1b[0m and \x1b[33mcode\x1b[0m",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("render %q does not contain %q", got, want)
+		}
+	}
+	// Styling does not count towards the width
+	if got := Render("**"+strings.Repeat("b", 20)+"** end", Options{Width: 24}); strings.Contains(got, "\n") {
+		t.Errorf("styled line wrapped early: %q", got)
+	}
+}
@@ -0,0 +1,301 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/goclaw2/internal/tools"
+)
+
+// ServerConfig describes how to launch a stdio MCP server
+type ServerConfig struct {
+	Name    string
+	Command []string
+	Env     map[string]string
+	Timeout time.Duration // Per request, default 60s
+}
+
+// Client talks to one MCP server over its stdin/stdout
+type Client struct {
+	cfg    ServerConfig
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *tailBuffer
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan *message
+	done    chan struct{}
+	readErr error
+
+	// ServerInfo and Instructions are filled in by the handshake
+	ServerInfo   Implementation
+	Instructions string
+}
+
+// Start launches the server and performs the initialize handshake
+func Start(cfg ServerConfig) (*Client, error) {
+	if len(cfg.Command) == 0 {
+		return nil, fmt.Errorf("mcp server %s: command is required", cfg.Name)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 60 * time.Second
+	}
+
+	cmd := exec.Command(cfg.Command[0], cfg.Command[1:]...)
+	// Servers are third-party code: they get the scrubbed base environment
+	// plus what the configuration gives them, not the host's credentials
+	cmd.Env = tools.BaseEnv()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+os.ExpandEnv(v))
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %s: %w", cfg.Name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %s: %w", cfg.Name, err)
+	}
+	stderr := &tailBuffer{max: 4096}
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start mcp server %s: %w", cfg.Name, err)
+	}
+
+	c := &Client{
+		cfg:     cfg,
+		cmd:     cmd,
+		stdin:   stdin,
+		stderr:  stderr,
+		pending: make(map[int64]chan *message),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(stdout)
+
+	if err := c.initialize(); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("mcp server %s: %w", cfg.Name, err)
+	}
+	return c, nil
+}
+
+// Name returns the configured server name
+func (c *Client) Name() string {
+	return c.cfg.Name
+}
+
+func (c *Client) initialize() error {
+	var result initializeResult
+	err := c.call(context.Background(), "initialize", initializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]interface{}{},
+		ClientInfo:      Implementation{Name: "goclaw", Version: "0.1.0"},
+	}, &result)
+	if err != nil {
+		return fmt.Errorf("initialize failed: %w", err)
+	}
+	c.ServerInfo = result.ServerInfo
+	c.Instructions = result.Instructions
+
+	return c.send(&message{JSONRPC: "2.0", Method: "notifications/initialized"})
+}
+
+// ListTools returns every tool the server offers, following pagination
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var all []Tool
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var result listToolsResult
+		if err := c.call(ctx, "tools/list", params, &result); err != nil {
+			return nil, fmt.Errorf("tools/list failed: %w", err)
+		}
+		all = append(all, result.Tools...)
+		if result.NextCursor == "" || result.NextCursor == cursor {
+			return all, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// CallTool invokes a tool on the server
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallToolResult, error) {
+	var result CallToolResult
+	if err := c.call(ctx, "tools/call", callToolParams{Name: name, Arguments: args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close stops the server: stdin is closed first so it can exit cleanly,
+// then it is killed if it does not
+func (c *Client) Close() error {
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(2 * time.Second):
+		c.cmd.Process.Kill()
+		<-c.done
+	}
+	return c.cmd.Wait()
+}
+
+// call sends a request and waits for its response
+func (c *Client) call(parent context.Context, method string, params, result interface{}) error {
+	ctx, cancel := context.WithTimeout(parent, c.cfg.Timeout)
+	defer cancel()
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode params: %w", err)
+	}
+
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	rawID := json.RawMessage(fmt.Sprintf("%d", id))
+	if err := c.send(&message{JSONRPC: "2.0", ID: &rawID, Method: method, Params: paramsJSON}); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result != nil && len(resp.Result) > 0 {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return fmt.Errorf("invalid %s result: %w", method, err)
+			}
+		}
+		return nil
+	case <-c.done:
+		return c.exitError()
+	case <-ctx.Done():
+		if err := parent.Err(); err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+		return fmt.Errorf("%s timed out after %s", method, c.cfg.Timeout)
+	}
+}
+
+func (c *Client) send(msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to server: %w", err)
+	}
+	return nil
+}
+
+// readLoop dispatches responses to waiting calls and answers requests the
+// server sends to us
+func (c *Client) readLoop(stdout io.Reader) {
+	defer close(c.done)
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 32*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			// Servers sometimes log to stdout; ignore anything that is not JSON-RPC
+			continue
+		}
+
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			c.answerServerRequest(&msg)
+		case msg.Method != "":
+			// Notifications (progress, log messages, list changes) are not used
+		case msg.ID != nil:
+			var id int64
+			if json.Unmarshal(*msg.ID, &id) != nil {
+				continue
+			}
+			c.mu.Lock()
+			ch, ok := c.pending[id]
+			c.mu.Unlock()
+			if ok {
+				ch <- &msg
+			}
+		}
+	}
+	c.readErr = scanner.Err()
+}
+
+// answerServerRequest replies to server-to-client requests. Only ping is
+// supported; GoClaw offers no sampling or roots.
+func (c *Client) answerServerRequest(req *message) {
+	resp := &message{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &RPCError{Code: codeMethodNotFound, Message: "method not supported: " + req.Method}
+	}
+	c.send(resp)
+}
+
+func (c *Client) exitError() error {
+	msg := "server exited"
+	if c.readErr != nil {
+		msg = fmt.Sprintf("lost connection to server: %v", c.readErr)
+	}
+	if tail := strings.TrimSpace(c.stderr.String()); tail != "" {
+		msg += "\nStderr: " + tail
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,216 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/goclaw2/internal/tools"
+)
+
+// helperEnv makes the test binary act as an MCP server that completes the
+// handshake and then never answers ("hang"), or answers every tools/call
+// with its environment ("env")
+const helperEnv = "GOCLAW_MCP_TEST_SERVER"
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(helperEnv); mode != "" {
+		serveHelper(mode)
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func serveHelper(mode string) {
+	out := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req struct {
+			ID     *json.RawMessage `json:"id"`
+			Method string           `json:"method"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &req) != nil || req.ID == nil {
+			continue
+		}
+		var result interface{}
+		switch {
+		case req.Method == "initialize":
+			result = map[string]interface{}{
+				"protocolVersion": "2024-11-05",
+				"capabilities":    map[string]interface{}{},
+				"serverInfo":      map[string]string{"name": "helper", "version": "0"},
+			}
+		case req.Method == "tools/call" && mode == "env":
+			result = map[string]interface{}{
+				"content": []map[string]string{{"type": "text", "text": strings.Join(os.Environ(), "\n")}},
+			}
+		default:
+			continue
+		}
+		out.Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}
+}
+
+// startHelper starts the test binary as an MCP server in mode
+func startHelper(t *testing.T, mode string, env map[string]string) *Client {
+	t.Helper()
+	serverEnv := map[string]string{helperEnv: mode}
+	for k, v := range env {
+		serverEnv[k] = v
+	}
+	client, err := Start(ServerConfig{Name: mode, Command: []string{os.Args[0]}, Env: serverEnv, Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// startStub builds cmd/mcp-stub and starts a client talking to it
+func startStub(t *testing.T) *Client {
+	t.Helper()
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	bin := filepath.Join(t.TempDir(), "mcp-stub")
+	if runtime.GOOS == "windows" {
+		bin += ".exe"
+	}
+	build := exec.Command(goTool, "build", "-o", bin, "github.com/user/goclaw2/cmd/mcp-stub")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build mcp-stub: %v\n%s", err, out)
+	}
+
+	client, err := Start(ServerConfig{Name: "stub", Command: []string{bin}, Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestClientRoundTrip(t *testing.T) {
+	client := startStub(t)
+	if client.ServerInfo.Name != "mcp-stub" {
+		t.Errorf("ServerInfo.Name = %q, want mcp-stub", client.ServerInfo.Name)
+	}
+
+	ctx := context.Background()
+	list, err := client.ListTools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range list {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "echo,add" {
+		t.Errorf("ListTools = %v, want [echo add]", names)
+	}
+
+	result, err := client.CallTool(ctx, "echo", map[string]interface{}{"text": "héllo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsError || ContentText(result.Content) != "héllo" {
+		t.Errorf("echo = %+v", result)
+	}
+
+	result, err = client.CallTool(ctx, "add", map[string]interface{}{"a": 2, "b": 1.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ContentText(result.Content) != "3.5" {
+		t.Errorf("add = %q, want 3.5", ContentText(result.Content))
+	}
+
+	result, err = client.CallTool(ctx, "missing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError {
+		t.Errorf("calling an unknown tool did not report an error: %+v", result)
+	}
+}
+
+func TestRegisterTools(t *testing.T) {
+	client := startStub(t)
+	reg := tools.New()
+	names, err := RegisterTools(reg, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "stub__echo,stub__add" {
+		t.Errorf("RegisterTools = %v", names)
+	}
+
+	out, err := reg.ExecuteToolCall("stub__echo", `{"text": "via registry"}`)
+	if err != nil || out != "via registry" {
+		t.Errorf("stub__echo = %q, %v", out, err)
+	}
+	if _, err := reg.ExecuteToolCall("stub__echo", `{}`); err == nil {
+		t.Error("stub__echo without the required text succeeded")
+	}
+}
+
+func TestClientClosed(t *testing.T) {
+	client := startStub(t)
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.ListTools(ctx); err == nil {
+		t.Error("ListTools succeeded after Close")
+	}
+}
+
+func TestRemoteToolCallerDeadline(t *testing.T) {
+	client := startHelper(t, "hang", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	tool := &RemoteTool{Client: client, Tool: Tool{Name: "wait"}}
+	start := time.Now()
+	_, err := tool.ExecuteAs(tools.Caller{Session: "s", Ctx: ctx}, map[string]interface{}{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ExecuteAs error = %v, want the caller's deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("ExecuteAs returned after %s, not at the caller's deadline", elapsed)
+	}
+}
+
+func TestServerEnvironment(t *testing.T) {
+	t.Setenv("ZHIPU_API_KEY", "secret-1")
+	t.Setenv("GOCLAW_ZHIPU_API_KEY", "secret-2")
+	t.Setenv("GOCLAW_WEBHOOK_SECRET", "secret-3")
+	t.Setenv("GOCLAW_WORKSPACE_NOTE", "visible")
+	t.Setenv("OTHER_VAR", "other")
+	client := startHelper(t, "env", map[string]string{"EXTRA": "$OTHER_VAR-configured"})
+
+	result, err := client.CallTool(context.Background(), "env", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := "\n" + ContentText(result.Content) + "\n"
+	for _, want := range []string{"\nPATH=", "\nGOCLAW_WORKSPACE_NOTE=visible\n", "\nEXTRA=other-configured\n"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("server environment lacks %q:%s", strings.TrimSpace(want), env)
+		}
+	}
+	for _, leaked := range []string{"secret-1", "secret-2", "secret-3", "\nOTHER_VAR="} {
+		if strings.Contains(env, leaked) {
+			t.Errorf("server environment contains %q:%s", strings.TrimSpace(leaked), env)
+		}
+	}
+}
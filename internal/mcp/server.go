@@ -0,0 +1,226 @@
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/user/goclaw2/internal/memory"
+	"github.com/user/goclaw2/internal/tools"
+)
+
+const (
+	historyURI      = "goclaw://session/history"
+	memoryURIPrefix = "goclaw://memory/"
+)
+
+// Resource describes a readable resource
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// Server exposes tools of a registry, the session store and the workspace
+// memory files over MCP
+type Server struct {
+	Tools        *tools.Registry
+	AllowedTools []string // Tools clients may list and call; no others are offered
+	Sessions     *memory.Store
+	WorkspaceDir string
+	Info         Implementation
+
+	writeMu sync.Mutex
+	out     io.Writer
+}
+
+// Serve reads requests from in and writes responses to out until in is
+// closed. Requests are handled one at a time.
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	s.out = out
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 32*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			s.reply(nil, nil, &RPCError{Code: codeParseError, Message: "invalid JSON: " + err.Error()})
+			continue
+		}
+		if msg.ID == nil {
+			// Notifications (initialized, cancelled) need no reply
+			continue
+		}
+		if msg.Method == "" {
+			// A response to a request we never send
+			continue
+		}
+		result, err := s.handle(msg.Method, msg.Params)
+		s.reply(msg.ID, result, err)
+	}
+	return scanner.Err()
+}
+
+func (s *Server) handle(method string, params json.RawMessage) (interface{}, *RPCError) {
+	switch method {
+	case "initialize":
+		return initializeResult{
+			ProtocolVersion: ProtocolVersion,
+			Capabilities: map[string]interface{}{
+				"tools":     map[string]interface{}{},
+				"resources": map[string]interface{}{},
+			},
+			ServerInfo:   s.Info,
+			Instructions: "GoClaw workspace tools and memory. Read goclaw://memory/MEMORY.md for long-term memory.",
+		}, nil
+
+	case "ping":
+		return struct{}{}, nil
+
+	case "tools/list":
+		list := s.Tools.List()
+		sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+		result := listToolsResult{Tools: make([]Tool, 0, len(list))}
+		for _, tool := range list {
+			if !s.allowsTool(tool.Name()) {
+				continue
+			}
+			result.Tools = append(result.Tools, Tool{
+				Name:        tool.Name(),
+				Description: tool.Description(),
+				InputSchema: tool.Parameters(),
+			})
+		}
+		return result, nil
+
+	case "tools/call":
+		var call struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &call); err != nil || call.Name == "" {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "tools/call requires a tool name"}
+		}
+		if _, ok := s.Tools.Get(call.Name); !ok || !s.allowsTool(call.Name) {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "unknown tool: " + call.Name}
+		}
+		// Tool failures are results, so the calling model can see and fix them
+		output, err := s.Tools.ExecuteToolCall(call.Name, string(call.Arguments))
+		if err != nil {
+			return CallToolResult{Content: []Content{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+		}
+		return CallToolResult{Content: []Content{{Type: "text", Text: output}}}, nil
+
+	case "resources/list":
+		return map[string]interface{}{"resources": s.resources()}, nil
+
+	case "resources/read":
+		var req struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(params, &req); err != nil || req.URI == "" {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "resources/read requires a uri"}
+		}
+		contents, err := s.readResource(req.URI)
+		if err != nil {
+			return nil, &RPCError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return map[string]interface{}{"contents": []ResourceContents{*contents}}, nil
+	}
+
+	return nil, &RPCError{Code: codeMethodNotFound, Message: "method not found: " + method}
+}
+
+func (s *Server) allowsTool(name string) bool {
+	for _, allowed := range s.AllowedTools {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resources lists the session history and the markdown files of the
+// workspace memory directory
+func (s *Server) resources() []Resource {
+	result := []Resource{}
+	if s.Sessions != nil {
+		result = append(result, Resource{
+			URI:         historyURI,
+			Name:        "Conversation history",
+			Description: "Messages of the current GoClaw session",
+			MimeType:    "application/json",
+		})
+	}
+
+	files, _ := filepath.Glob(filepath.Join(s.WorkspaceDir, "memory", "*.md"))
+	sort.Strings(files)
+	for _, file := range files {
+		name := filepath.Base(file)
+		result = append(result, Resource{
+			URI:      memoryURIPrefix + name,
+			Name:     name,
+			MimeType: "text/markdown",
+		})
+	}
+	return result
+}
+
+func (s *Server) readResource(uri string) (*ResourceContents, error) {
+	switch {
+	case uri == historyURI && s.Sessions != nil:
+		data, err := s.Sessions.ExportJSON()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		return &ResourceContents{URI: uri, MimeType: "application/json", Text: string(data)}, nil
+
+	case strings.HasPrefix(uri, memoryURIPrefix):
+		name := strings.TrimPrefix(uri, memoryURIPrefix)
+		// Only plain file names inside the memory directory
+		if name == "" || name != filepath.Base(name) || !strings.HasSuffix(name, ".md") {
+			return nil, fmt.Errorf("unknown resource: %s", uri)
+		}
+		data, err := os.ReadFile(filepath.Join(s.WorkspaceDir, "memory", name))
+		if err != nil {
+			return nil, fmt.Errorf("unknown resource: %s", uri)
+		}
+		return &ResourceContents{URI: uri, MimeType: "text/markdown", Text: string(data)}, nil
+	}
+	return nil, fmt.Errorf("unknown resource: %s", uri)
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, rpcErr *RPCError) {
+	resp := &message{JSONRPC: "2.0", ID: id}
+	if rpcErr != nil {
+		resp.Error = rpcErr
+	} else {
+		data, err := json.Marshal(result)
+		if err != nil {
+			resp.Error = &RPCError{Code: codeInternalError, Message: "failed to encode result: " + err.Error()}
+		} else {
+			resp.Result = data
+		}
+	}
+	if id == nil {
+		// JSON-RPC requires "id": null when the request id is unknown
+		null := json.RawMessage("null")
+		resp.ID = &null
+	}
+
+	data, _ := json.Marshal(resp)
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.out.Write(append(data, '\n'))
+}
@@ -0,0 +1,99 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/user/goclaw2/internal/tools"
+)
+
+// invalidNameChars are replaced in namespaced tool names; function names
+// sent to the model may only use letters, digits, _ and -
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// RemoteTool proxies one tool of an MCP server as a GoClaw tool. Its name
+// is namespaced as <server>__<tool> so servers cannot shadow each other or
+// the built-in tools.
+type RemoteTool struct {
+	Client *Client
+	Tool   Tool
+}
+
+func (t *RemoteTool) Name() string {
+	return ToolName(t.Client.Name(), t.Tool.Name)
+}
+
+func (t *RemoteTool) Description() string {
+	desc := t.Tool.Description
+	if desc == "" {
+		desc = t.Tool.Name
+	}
+	return fmt.Sprintf("[MCP %s] %s", t.Client.Name(), desc)
+}
+
+func (t *RemoteTool) Parameters() map[string]interface{} {
+	if t.Tool.InputSchema == nil {
+		return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	return t.Tool.InputSchema
+}
+
+func (t *RemoteTool) Execute(args map[string]interface{}) (string, error) {
+	return t.ExecuteAs(tools.Caller{}, args)
+}
+
+// ExecuteAs calls the tool, giving up when the caller's turn runs out of
+// time
+func (t *RemoteTool) ExecuteAs(caller tools.Caller, args map[string]interface{}) (string, error) {
+	result, err := t.Client.CallTool(caller.Context(), t.Tool.Name, args)
+	if err != nil {
+		return "", fmt.Errorf("mcp %s: %w", t.Name(), err)
+	}
+
+	text := ContentText(result.Content)
+	if result.IsError {
+		return "", fmt.Errorf("%s", text)
+	}
+	return text, nil
+}
+
+// ToolName returns the namespaced registry name of a server's tool
+func ToolName(server, tool string) string {
+	return invalidNameChars.ReplaceAllString(server, "_") + "__" + invalidNameChars.ReplaceAllString(tool, "_")
+}
+
+// ContentText flattens result content to text for the model. Non-text
+// items are summarized since they cannot be passed through.
+func ContentText(content []Content) string {
+	parts := make([]string, 0, len(content))
+	for _, item := range content {
+		switch {
+		case item.Type == "text":
+			parts = append(parts, item.Text)
+		case item.Type == "resource" && item.Resource != nil:
+			parts = append(parts, fmt.Sprintf("[resource %s]\n%s", item.Resource.URI, item.Resource.Text))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content (%s) omitted]", item.Type, item.MimeType))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// RegisterTools lists the server's tools and registers a RemoteTool for
+// each. It returns the registered names.
+func RegisterTools(reg *tools.Registry, client *Client) ([]string, error) {
+	list, err := client.ListTools(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %s: %w", client.Name(), err)
+	}
+
+	names := make([]string, 0, len(list))
+	for _, tool := range list {
+		remote := &RemoteTool{Client: client, Tool: tool}
+		reg.Register(remote)
+		names = append(names, remote.Name())
+	}
+	return names, nil
+}
@@ -31,6 +31,10 @@ func New(dbPath, sessionID string) (*Store, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	// SQLite allows one writer at a time; a single connection serializes
+	// concurrent sessions instead of failing with "database is locked"
+	db.SetMaxOpenConns(1)
+
 	// Create table if not exists
 	if err := createSchema(db); err != nil {
 		return nil, fmt.Errorf("failed to create schema: %w", err)
@@ -121,6 +125,56 @@ func (s *Store) Count() (int, error) {
 	return count, err
 }
 
+// Session returns a store for another session that shares this store's
+// database. Only the original store should be closed.
+func (s *Store) Session(sessionID string) *Store {
+	return &Store{db: s.db, sessionID: sessionID}
+}
+
+// DB returns the underlying database so other features can keep their
+// tables in the same file
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// SessionID returns the session this store reads and writes
+func (s *Store) SessionID() string {
+	return s.sessionID
+}
+
+// SessionInfo summarizes one session
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	Messages   int       `json:"messages"`
+	LastActive time.Time `json:"last_active"`
+}
+
+// ListSessions returns every session with messages, most recently active first
+func (s *Store) ListSessions() ([]SessionInfo, error) {
+	query := `
+		SELECT m.session_id, c.n, m.timestamp
+		FROM messages m
+		JOIN (SELECT session_id, COUNT(*) AS n, MAX(id) AS last FROM messages GROUP BY session_id) c
+			ON m.id = c.last
+		ORDER BY m.id DESC
+	`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []SessionInfo
+	for rows.Next() {
+		var info SessionInfo
+		if err := rows.Scan(&info.ID, &info.Messages, &info.LastActive); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, info)
+	}
+	return sessions, rows.Err()
+}
+
 // Close closes the database connection
 func (s *Store) Close() error {
 	return s.db.Close()
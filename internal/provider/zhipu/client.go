@@ -2,11 +2,13 @@ package zhipu
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/user/goclaw2/internal/config"
 )
@@ -74,6 +76,10 @@ type Usage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
+// requestTimeout bounds a single request, including reading a streamed
+// response, so a stalled connection cannot hang a turn forever
+const requestTimeout = 10 * time.Minute
+
 // Client represents the Zhipu AI client
 type Client struct {
 	cfg       *config.Config
@@ -86,7 +92,7 @@ type Client struct {
 func New(cfg *config.Config) *Client {
 	return &Client{
 		cfg:     cfg,
-		client:  &http.Client{},
+		client:  &http.Client{Timeout: requestTimeout},
 		baseURL: cfg.Zhipu.BaseURL,
 		apiKey:  cfg.Zhipu.APIKey,
 	}
@@ -94,27 +100,16 @@ func New(cfg *config.Config) *Client {
 
 // Chat sends a chat completion request
 func (c *Client) Chat(req *ChatRequest) (*ChatResponse, error) {
-	req.Model = c.cfg.Zhipu.Model
-	if req.Temperature == 0 {
-		req.Temperature = c.cfg.Zhipu.Temperature
-	}
-	if req.MaxTokens == 0 {
-		req.MaxTokens = c.cfg.Zhipu.MaxTokens
-	}
-
-	body, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
+	return c.ChatContext(context.Background(), req)
+}
 
-	httpReq, err := http.NewRequest("POST", c.baseURL+"/chat/completions", bytes.NewReader(body))
+// ChatContext is Chat that gives up when ctx is done
+func (c *Client) ChatContext(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	httpReq, err := c.newRequest(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
-
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -138,6 +133,31 @@ func (c *Client) Chat(req *ChatRequest) (*ChatResponse, error) {
 	return &chatResp, nil
 }
 
+// newRequest fills in configured defaults and builds the HTTP request
+func (c *Client) newRequest(ctx context.Context, req *ChatRequest) (*http.Request, error) {
+	req.Model = c.cfg.Zhipu.Model
+	if req.Temperature == 0 {
+		req.Temperature = c.cfg.Zhipu.Temperature
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = c.cfg.Zhipu.MaxTokens
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	return httpReq, nil
+}
+
 // ChatSimple sends a simple text chat request
 func (c *Client) ChatSimple(messages []Message) (*ChatResponse, error) {
 	return c.Chat(&ChatRequest{
@@ -0,0 +1,134 @@
+package zhipu
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// streamChunk is one server-sent event of a streaming response
+type streamChunk struct {
+	ID      string `json:"id"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Role      string          `json:"role"`
+			Content   string          `json:"content"`
+			ToolCalls []toolCallDelta `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+type toolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// ChatStream sends a streaming chat request. onDelta is called with each
+// piece of content as it arrives; the assembled response, including any
+// tool calls, is returned at the end.
+func (c *Client) ChatStream(req *ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	return c.ChatStreamContext(context.Background(), req, onDelta)
+}
+
+// ChatStreamContext is ChatStream that gives up when ctx is done
+func (c *Client) ChatStreamContext(ctx context.Context, req *ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	req.Stream = true
+	httpReq, err := c.newRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	result := &ChatResponse{Object: "chat.completion", Choices: []Choice{{Message: Message{Role: "assistant"}}}}
+	choice := &result.Choices[0]
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+		result.ID, result.Created, result.Model = chunk.ID, chunk.Created, chunk.Model
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta
+		if delta.Content != "" {
+			content.WriteString(delta.Content)
+			if onDelta != nil {
+				onDelta(delta.Content)
+			}
+		}
+		for _, tc := range delta.ToolCalls {
+			mergeToolCall(&choice.Message, tc)
+		}
+		if chunk.Choices[0].FinishReason != "" {
+			choice.FinishReason = chunk.Choices[0].FinishReason
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	choice.Message.Content = content.String()
+	return result, nil
+}
+
+// mergeToolCall adds a tool call fragment to msg. Fragments with the same
+// index belong to the same call; arguments arrive in pieces.
+func mergeToolCall(msg *Message, delta toolCallDelta) {
+	for len(msg.ToolCalls) <= delta.Index {
+		msg.ToolCalls = append(msg.ToolCalls, ToolCall{Type: "function"})
+	}
+	call := &msg.ToolCalls[delta.Index]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Type != "" {
+		call.Type = delta.Type
+	}
+	if delta.Function.Name != "" {
+		call.Function.Name = delta.Function.Name
+	}
+	call.Function.Arguments += delta.Function.Arguments
+}
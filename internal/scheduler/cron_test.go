@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRejects(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"x * * * *",
+		"* * * foo *",
+		"@every 30s",
+		"@every soon",
+		"@fortnightly",
+	} {
+		if _, err := Parse(spec, time.UTC); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2024-05-10 is a Friday; 2024 is a leap year
+	from := time.Date(2024, 5, 10, 12, 30, 45, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 5, 10, 12, 31, 0, 0, time.UTC)},
+		{"30 12 * * *", time.Date(2024, 5, 11, 12, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 5, 10, 12, 45, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2024, 5, 10, 12, 45, 0, 0, time.UTC)},
+		{"0 9-17 * * *", time.Date(2024, 5, 10, 13, 0, 0, 0, time.UTC)},
+		{"0 7,19 * * *", time.Date(2024, 5, 10, 19, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * mon", time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 5, 12, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * jan-mar *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matches
+		{"0 0 13 * 6", time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 5, 10, 13, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 1h30m", time.Date(2024, 5, 10, 14, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		schedule, err := Parse(tt.spec, time.UTC)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: Next(%s) = %s, want %s", tt.spec, from, got, tt.want)
+		}
+	}
+}
+
+func TestNextInLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	schedule, err := Parse("0 9 * * *", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2024, 5, 10, 2, 0, 0, 0, time.UTC) // 10:00 in loc
+	want := time.Date(2024, 5, 11, 1, 0, 0, 0, time.UTC)
+	if got := schedule.Next(from); !got.Equal(want) {
+		t.Errorf("Next(%s) = %s, want %s", from, got, want)
+	}
+}
+
+func TestNextNever(t *testing.T) {
+	for _, spec := range []string{"0 9 30 2 *", "0 0 31 4,6,9,11 *"} {
+		schedule, err := Parse(spec, time.UTC)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", spec, err)
+		}
+		if next := schedule.Next(time.Now()); !next.IsZero() {
+			t.Errorf("%q: Next = %s, want zero", spec, next)
+		}
+	}
+}
+
+func TestTaskValidate(t *testing.T) {
+	tests := []struct {
+		task    Task
+		wantErr string
+	}{
+		{Task{Name: "ok", Schedule: "0 9 * * 1-5", Session: "default", Prompt: "hi"}, ""},
+		{Task{Name: "bad name", Schedule: "@daily", Session: "default", Prompt: "hi"}, "invalid task name"},
+		{Task{Name: "t", Schedule: "@daily", Session: "a/b", Prompt: "hi"}, "invalid session"},
+		{Task{Name: "t", Schedule: "@daily", Session: "default", Prompt: " "}, "prompt is required"},
+		{Task{Name: "t", Schedule: "0 25 * * *", Session: "default", Prompt: "hi"}, "invalid schedule"},
+		{Task{Name: "t", Schedule: "0 9 30 2 *", Session: "default", Prompt: "hi"}, "never fires"},
+	}
+	for _, tt := range tests {
+		err := tt.task.Validate(time.UTC)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%+v: unexpected error %v", tt.task, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%+v: error %v, want one containing %q", tt.task, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// reminderPollInterval is how often due reminders are looked for
+const reminderPollInterval = 15 * time.Second
+
+// Reminder is a message delivered to the user at a given time. It belongs
+// to the session and gateway API key it was set in, and only they see it.
+type Reminder struct {
+	ID          int64
+	Session     string
+	KeyID       int64 // 0 outside the gateway
+	Message     string
+	DueAt       time.Time
+	Timezone    string // Zone the reminder was set in, for display
+	CreatedAt   time.Time
+	DeliveredAt *time.Time
+	CancelledAt *time.Time
+}
+
+// Status is pending, delivered or cancelled
+func (r *Reminder) Status() string {
+	switch {
+	case r.CancelledAt != nil:
+		return "cancelled"
+	case r.DeliveredAt != nil:
+		return "delivered"
+	default:
+		return "pending"
+	}
+}
+
+// Local returns the due time in the zone the reminder was set in
+func (r *Reminder) Local() time.Time {
+	if loc, err := time.LoadLocation(r.Timezone); err == nil {
+		return r.DueAt.In(loc)
+	}
+	return r.DueAt
+}
+
+// ReminderStore keeps reminders in SQLite. Times are stored as Unix
+// seconds so due reminders can be found with a plain comparison.
+type ReminderStore struct {
+	db *sql.DB
+}
+
+// NewReminderStore creates the reminders table in db if needed
+func NewReminderStore(db *sql.DB) (*ReminderStore, error) {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS reminders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session TEXT NOT NULL DEFAULT 'default',
+		key_id INTEGER NOT NULL DEFAULT 0,
+		message TEXT NOT NULL,
+		due_at INTEGER NOT NULL,
+		timezone TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		delivered_at INTEGER,
+		cancelled_at INTEGER
+	);
+	CREATE INDEX IF NOT EXISTS idx_reminders_due ON reminders(due_at);
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reminders table: %w", err)
+	}
+	if err := addReminderOwner(db); err != nil {
+		return nil, err
+	}
+	return &ReminderStore{db: db}, nil
+}
+
+// addReminderOwner adds the owner columns to tables created before
+// reminders had one. Their reminders go to the default session, where the
+// CLI chat sets them.
+func addReminderOwner(db *sql.DB) error {
+	rows, err := db.Query(`PRAGMA table_info(reminders)`)
+	if err != nil {
+		return fmt.Errorf("failed to read reminders table: %w", err)
+	}
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read reminders table: %w", err)
+		}
+		columns[name] = true
+	}
+	rows.Close()
+
+	for _, column := range []string{"session TEXT NOT NULL DEFAULT 'default'", "key_id INTEGER NOT NULL DEFAULT 0"} {
+		name := column[:strings.IndexByte(column, ' ')]
+		if columns[name] {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE reminders ADD COLUMN ` + column); err != nil {
+			return fmt.Errorf("failed to add reminders.%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Add stores a reminder for session and keyID due at dueAt, displayed in loc
+func (s *ReminderStore) Add(session string, keyID int64, message string, dueAt time.Time, loc *time.Location) (*Reminder, error) {
+	r := &Reminder{Session: session, KeyID: keyID, Message: message, DueAt: dueAt, Timezone: loc.String(), CreatedAt: time.Now()}
+	result, err := s.db.Exec(`INSERT INTO reminders (session, key_id, message, due_at, timezone, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		r.Session, r.KeyID, r.Message, r.DueAt.Unix(), r.Timezone, r.CreatedAt.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to store reminder: %w", err)
+	}
+	r.ID, _ = result.LastInsertId()
+	return r, nil
+}
+
+// List returns the pending reminders of session and keyID by due time,
+// or all of theirs when all is set
+func (s *ReminderStore) List(session string, keyID int64, all bool) ([]*Reminder, error) {
+	where := `WHERE session = ? AND key_id = ?`
+	if !all {
+		where += ` AND delivered_at IS NULL AND cancelled_at IS NULL`
+	}
+	return s.query(where+` ORDER BY due_at, id`, session, keyID)
+}
+
+// Cancel cancels a pending reminder of session and keyID
+func (s *ReminderStore) Cancel(session string, keyID int64, id int64) (*Reminder, error) {
+	result, err := s.db.Exec(`UPDATE reminders SET cancelled_at = ?
+		WHERE id = ? AND session = ? AND key_id = ? AND delivered_at IS NULL AND cancelled_at IS NULL`,
+		time.Now().Unix(), id, session, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel reminder: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("no pending reminder with id %d", id)
+	}
+	reminders, err := s.query(`WHERE id = ?`, id)
+	if err != nil || len(reminders) == 0 {
+		return nil, err
+	}
+	return reminders[0], nil
+}
+
+// Deliver calls notify for every due reminder until ctx is cancelled.
+// notify reports whether the reminder's owner received it; undelivered
+// ones are offered again on the next poll. Reminders that fell due while
+// no process was running are delivered late.
+func (s *ReminderStore) Deliver(ctx context.Context, notify func(r *Reminder) bool) {
+	ticker := time.NewTicker(reminderPollInterval)
+	defer ticker.Stop()
+	for {
+		due, err := s.query(`WHERE due_at <= ? AND delivered_at IS NULL AND cancelled_at IS NULL ORDER BY due_at`,
+			time.Now().Unix())
+		if err != nil {
+			log.Printf("reminders: %v", err)
+		}
+		for _, r := range due {
+			if !s.claim(r.ID) {
+				continue // Delivered by another process
+			}
+			if !notify(r) {
+				s.db.Exec(`UPDATE reminders SET delivered_at = NULL WHERE id = ?`, r.ID)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim marks a reminder delivered, reporting false when another process
+// got there first
+func (s *ReminderStore) claim(id int64) bool {
+	result, err := s.db.Exec(`UPDATE reminders SET delivered_at = ? WHERE id = ? AND delivered_at IS NULL AND cancelled_at IS NULL`,
+		time.Now().Unix(), id)
+	if err != nil {
+		log.Printf("reminders: failed to mark reminder %d delivered: %v", id, err)
+		return false
+	}
+	n, _ := result.RowsAffected()
+	return n == 1
+}
+
+func (s *ReminderStore) query(where string, args ...interface{}) ([]*Reminder, error) {
+	rows, err := s.db.Query(`
+		SELECT id, session, key_id, message, due_at, timezone, created_at, delivered_at, cancelled_at
+		FROM reminders `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reminders: %w", err)
+	}
+	defer rows.Close()
+
+	var reminders []*Reminder
+	for rows.Next() {
+		var r Reminder
+		var due, created int64
+		var delivered, cancelled sql.NullInt64
+		if err := rows.Scan(&r.ID, &r.Session, &r.KeyID, &r.Message, &due, &r.Timezone, &created, &delivered, &cancelled); err != nil {
+			return nil, fmt.Errorf("failed to read reminder: %w", err)
+		}
+		r.DueAt = time.Unix(due, 0)
+		r.CreatedAt = time.Unix(created, 0)
+		if delivered.Valid {
+			t := time.Unix(delivered.Int64, 0)
+			r.DeliveredAt = &t
+		}
+		if cancelled.Valid {
+			t := time.Unix(cancelled.Int64, 0)
+			r.CancelledAt = &t
+		}
+		reminders = append(reminders, &r)
+	}
+	return reminders, rows.Err()
+}
@@ -0,0 +1,131 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReminderStore(t *testing.T) {
+	store, err := NewReminderStore(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := time.Date(2030, 5, 1, 9, 0, 0, 0, time.UTC)
+	a, err := store.Add("s1", 0, "a", due.Add(time.Hour), shanghai)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := store.Add("s1", 0, "b", due, time.UTC)
+	store.Add("s1", 7, "other key", due, time.UTC)
+	store.Add("s2", 0, "other session", due, time.UTC)
+
+	// Owners only see their own reminders, by due time
+	list, err := store.List("s1", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != b.ID || list[1].ID != a.ID {
+		t.Fatalf("List = %+v", list)
+	}
+	if got := list[1].Local().Format("15:04 MST"); got != "18:00 CST" {
+		t.Errorf("Local() = %s, want the time in the zone it was set in", got)
+	}
+
+	if _, err := store.Cancel("s1", 7, a.ID); err == nil {
+		t.Error("another key cancelled the reminder")
+	}
+	cancelled, err := store.Cancel("s1", 0, a.ID)
+	if err != nil || cancelled.Status() != "cancelled" {
+		t.Fatalf("Cancel = %+v, %v", cancelled, err)
+	}
+	if _, err := store.Cancel("s1", 0, a.ID); err == nil || !strings.Contains(err.Error(), "no pending reminder") {
+		t.Errorf("cancelling twice: %v", err)
+	}
+	if list, _ := store.List("s1", 0, false); len(list) != 1 {
+		t.Errorf("pending after cancel: %+v", list)
+	}
+	if list, _ := store.List("s1", 0, true); len(list) != 2 {
+		t.Errorf("all after cancel: %+v", list)
+	}
+}
+
+func TestReminderDeliver(t *testing.T) {
+	store, err := NewReminderStore(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Minute)
+	store.Add("s", 0, "received", past, time.UTC)
+	store.Add("s", 0, "nobody listening", past, time.UTC)
+	store.Add("s", 0, "later", time.Now().Add(time.Hour), time.UTC)
+	cancelled, _ := store.Add("s", 0, "cancelled", past, time.UTC)
+	store.Cancel("s", 0, cancelled.ID)
+
+	// A cancelled context makes Deliver stop after one pass
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	deliver := func() string {
+		var got []string
+		store.Deliver(ctx, func(r *Reminder) bool {
+			got = append(got, r.Message)
+			return r.Message == "received"
+		})
+		return strings.Join(got, ",")
+	}
+	if got := deliver(); got != "received,nobody listening" {
+		t.Errorf("first pass delivered %s", got)
+	}
+	// Reminders nobody received are offered again
+	if got := deliver(); got != "nobody listening" {
+		t.Errorf("second pass delivered %s", got)
+	}
+
+	all, _ := store.List("s", 0, true)
+	status := map[string]string{}
+	for _, r := range all {
+		status[r.Message] = r.Status()
+	}
+	want := map[string]string{"received": "delivered", "nobody listening": "pending", "later": "pending", "cancelled": "cancelled"}
+	for message, s := range want {
+		if status[message] != s {
+			t.Errorf("%s: status %s, want %s", message, status[message], s)
+		}
+	}
+}
+
+func TestReminderStoreAddsOwner(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec(`
+	CREATE TABLE reminders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		message TEXT NOT NULL,
+		due_at INTEGER NOT NULL,
+		timezone TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		delivered_at INTEGER,
+		cancelled_at INTEGER
+	);
+	INSERT INTO reminders (message, due_at, timezone, created_at) VALUES ('old', 0, 'UTC', 0);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reminders from before owners belong to the CLI's default session
+	store, err := NewReminderStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := store.List("default", 0, false)
+	if err != nil || len(list) != 1 || list[0].Message != "old" {
+		t.Errorf("List = %+v, %v", list, err)
+	}
+	if _, err := NewReminderStore(db); err != nil {
+		t.Errorf("opening again: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// pollInterval is how often the task list is reloaded, so tasks added
+// with the CLI are picked up by a running process
+const pollInterval = 30 * time.Second
+
+// RunFunc runs a task's prompt against its session and returns the reply
+type RunFunc func(task *Task) (string, error)
+
+// Scheduler runs due tasks. Missed activations while no process was
+// running are not caught up; a task runs at its next activation.
+type Scheduler struct {
+	Store    *Store
+	Location *time.Location
+	Run      RunFunc
+
+	// OnFinish, if set, is called after every run that was not skipped
+	OnFinish func(task *Task, output string, err error)
+
+	wg sync.WaitGroup
+}
+
+// Start runs due tasks until ctx is cancelled, then waits for running
+// tasks to finish
+func (s *Scheduler) Start(ctx context.Context) {
+	next := make(map[string]time.Time) // Task name -> next activation
+	specs := make(map[string]string)   // Task name -> schedule next was computed for
+
+	for {
+		tasks, err := s.Store.List()
+		if err != nil {
+			log.Printf("scheduler: %v", err)
+		}
+
+		now := time.Now()
+		wake := now.Add(pollInterval)
+		seen := make(map[string]bool, len(tasks))
+		for _, task := range tasks {
+			seen[task.Name] = true
+			schedule, err := Parse(task.Schedule, s.Location)
+			if err != nil {
+				if specs[task.Name] != task.Schedule {
+					log.Printf("scheduler: task %s: %v", task.Name, err)
+					specs[task.Name] = task.Schedule
+				}
+				delete(next, task.Name)
+				continue
+			}
+			if specs[task.Name] != task.Schedule || next[task.Name].IsZero() {
+				changed := specs[task.Name] != task.Schedule
+				specs[task.Name] = task.Schedule
+				next[task.Name] = schedule.Next(now)
+				// Schedules such as "0 9 30 2 *" have no next time at all
+				if next[task.Name].IsZero() {
+					if changed {
+						log.Printf("scheduler: task %s: schedule %q never fires", task.Name, task.Schedule)
+					}
+					continue
+				}
+			}
+
+			if due := next[task.Name]; !due.After(now) {
+				next[task.Name] = schedule.Next(now)
+				s.wg.Add(1)
+				go func(task *Task) {
+					defer s.wg.Done()
+					if _, _, err := s.RunNow(task); err != nil {
+						log.Printf("scheduler: task %s: %v", task.Name, err)
+					}
+				}(task)
+			}
+			if due := next[task.Name]; !due.IsZero() && due.Before(wake) {
+				wake = due
+			}
+		}
+		for name := range next {
+			if !seen[name] {
+				delete(next, name)
+				delete(specs, name)
+			}
+		}
+
+		timer := time.NewTimer(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.wg.Wait()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// RunNow runs task immediately unless it is already running. It returns
+// the reply and whether the task ran; err reports both bookkeeping
+// failures and the task's own error.
+func (s *Scheduler) RunNow(task *Task) (string, bool, error) {
+	runID, ok, err := s.Store.StartRun(task)
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	output, runErr := s.safeRun(task)
+	if err := s.Store.FinishRun(runID, output, runErr); err != nil {
+		log.Printf("scheduler: task %s: %v", task.Name, err)
+	}
+	if s.OnFinish != nil {
+		s.OnFinish(task, output, runErr)
+	}
+	return output, true, runErr
+}
+
+// safeRun keeps a panicking task from leaving its run marked as running
+func (s *Scheduler) safeRun(task *Task) (output string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked: %v", r)
+		}
+	}()
+	return s.Run(task)
+}
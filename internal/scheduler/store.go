@@ -0,0 +1,316 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// Task sources
+const (
+	SourceConfig = "config" // From scheduler.tasks, replaced on every start
+	SourceCLI    = "cli"    // Created with `goclaw tasks add`
+)
+
+// Run statuses
+const (
+	StatusRunning = "running"
+	StatusOK      = "ok"
+	StatusError   = "error"
+	StatusSkipped = "skipped" // The previous run had not finished
+)
+
+// minStaleRunAge is the least time a run stays marked running before it is
+// assumed to have died with its process
+const minStaleRunAge = time.Hour
+
+// Task is a prompt run against a session on a schedule
+type Task struct {
+	ID        int64
+	Name      string
+	Schedule  string
+	Session   string
+	Prompt    string
+	Source    string
+	CreatedAt time.Time
+}
+
+// Validate checks the task's fields, parsing its schedule in loc
+func (t *Task) Validate(loc *time.Location) error {
+	if !namePattern.MatchString(t.Name) {
+		return fmt.Errorf("invalid task name %q: use letters, digits, '.', '_' and '-'", t.Name)
+	}
+	if !namePattern.MatchString(t.Session) {
+		return fmt.Errorf("task %s: invalid session %q", t.Name, t.Session)
+	}
+	if strings.TrimSpace(t.Prompt) == "" {
+		return fmt.Errorf("task %s: prompt is required", t.Name)
+	}
+	schedule, err := Parse(t.Schedule, loc)
+	if err != nil {
+		return fmt.Errorf("task %s: %w", t.Name, err)
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return fmt.Errorf("task %s: schedule %q never fires", t.Name, t.Schedule)
+	}
+	return nil
+}
+
+// Run is one execution of a task
+type Run struct {
+	ID         int64
+	TaskID     int64
+	TaskName   string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	Status     string
+	Output     string
+	Error      string
+}
+
+// Store keeps tasks and their run history in SQLite
+type Store struct {
+	db         *sql.DB
+	staleAfter time.Duration
+}
+
+// NewStore creates the tasks and task_runs tables in db if needed
+func NewStore(db *sql.DB) (*Store, error) {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS tasks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		schedule TEXT NOT NULL,
+		session_id TEXT NOT NULL,
+		prompt TEXT NOT NULL,
+		source TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS task_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME,
+		status TEXT NOT NULL,
+		output TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_task_runs_task ON task_runs(task_id, started_at);
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task tables: %w", err)
+	}
+	return &Store{db: db, staleAfter: minStaleRunAge}, nil
+}
+
+// SetTurnTimeout sets how long a run may take from the turn timeout of the
+// agent that runs tasks. A run may wait for another turn of its session
+// before its own, and a stopped turn gets up to a minute more to answer,
+// so a run is only assumed dead after twice the timeout and some margin,
+// and never before minStaleRunAge.
+func (s *Store) SetTurnTimeout(timeout time.Duration) {
+	s.staleAfter = 2*timeout + 5*time.Minute
+	if s.staleAfter < minStaleRunAge {
+		s.staleAfter = minStaleRunAge
+	}
+}
+
+// Add stores a new task
+func (s *Store) Add(task Task) (*Task, error) {
+	task.CreatedAt = time.Now()
+	result, err := s.db.Exec(`
+		INSERT INTO tasks (name, schedule, session_id, prompt, source, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		task.Name, task.Schedule, task.Session, task.Prompt, task.Source, task.CreatedAt)
+	if err != nil {
+		if existing, _ := s.Get(task.Name); existing != nil {
+			return nil, fmt.Errorf("a task named %s already exists", task.Name)
+		}
+		return nil, fmt.Errorf("failed to store task: %w", err)
+	}
+	task.ID, _ = result.LastInsertId()
+	return &task, nil
+}
+
+// Get returns the named task, or nil when there is none
+func (s *Store) Get(name string) (*Task, error) {
+	tasks, err := s.query(`WHERE name = ?`, name)
+	if err != nil || len(tasks) == 0 {
+		return nil, err
+	}
+	return tasks[0], nil
+}
+
+// List returns every task ordered by name
+func (s *Store) List() ([]*Task, error) {
+	return s.query(`ORDER BY name`)
+}
+
+// Remove deletes a task created with the CLI together with its history
+func (s *Store) Remove(name string) error {
+	task, err := s.Get(name)
+	if err != nil {
+		return err
+	}
+	if task == nil {
+		return fmt.Errorf("no task named %s", name)
+	}
+	if task.Source == SourceConfig {
+		return fmt.Errorf("task %s comes from the config file; remove it there", name)
+	}
+	return s.delete(task)
+}
+
+func (s *Store) delete(task *Task) error {
+	if _, err := s.db.Exec(`DELETE FROM task_runs WHERE task_id = ?`, task.ID); err != nil {
+		return fmt.Errorf("failed to remove history of task %s: %w", task.Name, err)
+	}
+	if _, err := s.db.Exec(`DELETE FROM tasks WHERE id = ?`, task.ID); err != nil {
+		return fmt.Errorf("failed to remove task %s: %w", task.Name, err)
+	}
+	return nil
+}
+
+// SyncConfig makes the config-sourced tasks match tasks. Their IDs, and
+// so their history, are kept across restarts as long as the name stays.
+func (s *Store) SyncConfig(tasks []Task) error {
+	keep := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		keep[task.Name] = true
+		existing, err := s.Get(task.Name)
+		if err != nil {
+			return err
+		}
+		switch {
+		case existing == nil:
+			task.Source = SourceConfig
+			if _, err := s.Add(task); err != nil {
+				return err
+			}
+		case existing.Source != SourceConfig:
+			return fmt.Errorf("task %s is defined both in the config file and with `goclaw tasks add`", task.Name)
+		default:
+			_, err := s.db.Exec(`UPDATE tasks SET schedule = ?, session_id = ?, prompt = ? WHERE id = ?`,
+				task.Schedule, task.Session, task.Prompt, existing.ID)
+			if err != nil {
+				return fmt.Errorf("failed to update task %s: %w", task.Name, err)
+			}
+		}
+	}
+
+	all, err := s.List()
+	if err != nil {
+		return err
+	}
+	for _, task := range all {
+		if task.Source == SourceConfig && !keep[task.Name] {
+			if err := s.delete(task); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// StartRun records the start of a run. It returns false, after recording
+// a skipped run, when the task is already running in this or another
+// process. A run marked running for longer than SetTurnTimeout allows does
+// not count; its process is assumed to have died.
+func (s *Store) StartRun(task *Task) (int64, bool, error) {
+	now := time.Now()
+	result, err := s.db.Exec(`
+		INSERT INTO task_runs (task_id, started_at, status)
+		SELECT ?, ?, ?
+		WHERE NOT EXISTS (
+			SELECT 1 FROM task_runs WHERE task_id = ? AND status = ? AND started_at > ?
+		)`,
+		task.ID, now, StatusRunning, task.ID, StatusRunning, now.Add(-s.staleAfter))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to record run: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		_, err := s.db.Exec(`
+			INSERT INTO task_runs (task_id, started_at, finished_at, status, error)
+			VALUES (?, ?, ?, ?, ?)`,
+			task.ID, now, now, StatusSkipped, "previous run still in progress")
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to record skipped run: %w", err)
+		}
+		return 0, false, nil
+	}
+	id, _ := result.LastInsertId()
+	return id, true, nil
+}
+
+// FinishRun records the outcome of a run started with StartRun
+func (s *Store) FinishRun(id int64, output string, runErr error) error {
+	status, errText := StatusOK, ""
+	if runErr != nil {
+		status, errText = StatusError, runErr.Error()
+	}
+	_, err := s.db.Exec(`UPDATE task_runs SET finished_at = ?, status = ?, output = ?, error = ? WHERE id = ?`,
+		time.Now(), status, output, errText, id)
+	if err != nil {
+		return fmt.Errorf("failed to record run result: %w", err)
+	}
+	return nil
+}
+
+// Runs returns the most recent runs, newest first, of the named task or
+// of every task when name is empty
+func (s *Store) Runs(name string, limit int) ([]*Run, error) {
+	query := `
+		SELECT r.id, r.task_id, t.name, r.started_at, r.finished_at, r.status, r.output, r.error
+		FROM task_runs r JOIN tasks t ON t.id = r.task_id`
+	var args []interface{}
+	if name != "" {
+		query += ` WHERE t.name = ?`
+		args = append(args, name)
+	}
+	query += ` ORDER BY r.started_at DESC, r.id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*Run
+	for rows.Next() {
+		var r Run
+		var finished sql.NullTime
+		if err := rows.Scan(&r.ID, &r.TaskID, &r.TaskName, &r.StartedAt, &finished, &r.Status, &r.Output, &r.Error); err != nil {
+			return nil, fmt.Errorf("failed to read run: %w", err)
+		}
+		if finished.Valid {
+			r.FinishedAt = &finished.Time
+		}
+		runs = append(runs, &r)
+	}
+	return runs, rows.Err()
+}
+
+func (s *Store) query(where string, args ...interface{}) ([]*Task, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, schedule, session_id, prompt, source, created_at
+		FROM tasks `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []*Task
+	for rows.Next() {
+		var t Task
+		if err := rows.Scan(&t.ID, &t.Name, &t.Schedule, &t.Session, &t.Prompt, &t.Source, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read task: %w", err)
+		}
+		tasks = append(tasks, &t)
+	}
+	return tasks, rows.Err()
+}
@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "goclaw.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := NewStore(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestStartRunStale(t *testing.T) {
+	store := newTestStore(t)
+	task, err := store.Add(Task{Name: "t", Schedule: "@hourly", Session: "s", Prompt: "p", Source: SourceCLI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// startedAgo marks the task's running runs as started d ago
+	startedAgo := func(d time.Duration) {
+		if _, err := store.db.Exec(`UPDATE task_runs SET started_at = ? WHERE status = ?`, time.Now().Add(-d), StatusRunning); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		name        string
+		turnTimeout time.Duration // Passed to SetTurnTimeout when set
+		startedAgo  time.Duration // Age of the running run before starting
+		want        bool
+	}{
+		{"first run", 0, 0, true},
+		{"while running", 0, 0, false},
+		{"running for 50 minutes", 0, 50 * time.Minute, false},
+		{"running past an hour", 0, 61 * time.Minute, true},
+		{"short timeouts keep an hour", time.Minute, 61 * time.Minute, true},
+		{"long timeout, within twice it", time.Hour, 2 * time.Hour, false},
+		{"long timeout, past twice it", time.Hour, 2*time.Hour + 6*time.Minute, true},
+	}
+	for _, step := range steps {
+		if step.turnTimeout > 0 {
+			store.SetTurnTimeout(step.turnTimeout)
+		}
+		startedAgo(step.startedAgo)
+		_, ok, err := store.StartRun(task)
+		if err != nil || ok != step.want {
+			t.Errorf("%s: started %v, %v; want %v", step.name, ok, err, step.want)
+		}
+	}
+
+	runs, err := store.Runs("t", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := 0
+	for _, r := range runs {
+		if r.Status == StatusSkipped {
+			skipped++
+		}
+	}
+	if skipped != 3 {
+		t.Errorf("%d skipped runs recorded, want 3", skipped)
+	}
+}
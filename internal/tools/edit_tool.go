@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EditFile applies targeted edits to an existing file
+type EditFile struct {
+	Policy *PathPolicy
+}
+
+func (t *EditFile) Name() string {
+	return "edit_file"
+}
+
+func (t *EditFile) Description() string {
+	return "Edit an existing file without rewriting it. Use exactly one mode: " +
+		"'edits' (exact search/replace blocks; old_string must match exactly once unless replace_all is set), " +
+		"'start_line'/'end_line'/'content' (replace a 1-based inclusive line range; set end_line to start_line-1 to insert), " +
+		"or 'patch' (a unified diff for this file). Returns the resulting diff."
+}
+
+type editFileArgs struct {
+	Path      string      `json:"path" desc:"Absolute or relative path to the file" required:"true"`
+	Edits     []editBlock `json:"edits,omitempty" desc:"Search/replace blocks applied in order"`
+	StartLine *int        `json:"start_line,omitempty" desc:"First line to replace (1-based)"`
+	EndLine   *int        `json:"end_line,omitempty" desc:"Last line to replace (inclusive)"`
+	Content   *string     `json:"content,omitempty" desc:"Replacement text for the line range"`
+	Patch     *string     `json:"patch,omitempty" desc:"Unified diff to apply; small offsets and context mismatches are tolerated"`
+
+	// Shorthand for a single search/replace block
+	OldString  *string `json:"old_string,omitempty" desc:"Shorthand for a single edit: exact text to find"`
+	NewString  *string `json:"new_string,omitempty" desc:"Shorthand for a single edit: replacement text"`
+	ReplaceAll bool    `json:"replace_all,omitempty" desc:"Shorthand for a single edit: replace every occurrence"`
+}
+
+type editBlock struct {
+	OldString  string `json:"old_string" desc:"Exact text to find, including whitespace and indentation" required:"true"`
+	NewString  string `json:"new_string" desc:"Replacement text" required:"true"`
+	ReplaceAll bool   `json:"replace_all,omitempty" desc:"Replace every occurrence instead of requiring a unique match"`
+}
+
+func (t *EditFile) Parameters() map[string]interface{} {
+	return SchemaFor[editFileArgs]()
+}
+
+func (t *EditFile) Execute(args map[string]interface{}) (string, error) {
+	a, err := DecodeArgs[editFileArgs](t.Name(), args)
+	if err != nil {
+		return "", err
+	}
+	path := a.Path
+
+	// Resolve path and check it against the access policy
+	absPath, err := t.Policy.CheckWrite(path)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(absPath)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("file does not exist: %s (use write_file to create it)", path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	original, err := os.ReadFile(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	before := string(original)
+
+	var after string
+	var notes []string
+	switch {
+	case a.Patch != nil:
+		lines, applied, err := applyPatch(splitLines(before), *a.Patch)
+		if err != nil {
+			return "", err
+		}
+		after = joinLines(lines, strings.HasSuffix(before, "\n") || before == "")
+		notes = applied
+
+	case len(a.Edits) > 0 || a.OldString != nil:
+		edits := a.Edits
+		if len(edits) == 0 {
+			if a.NewString == nil {
+				return "", fmt.Errorf("new_string is required with old_string")
+			}
+			edits = []editBlock{{OldString: *a.OldString, NewString: *a.NewString, ReplaceAll: a.ReplaceAll}}
+		}
+		if after, err = applyEdits(before, edits); err != nil {
+			return "", err
+		}
+
+	case a.StartLine != nil:
+		if a.EndLine == nil || a.Content == nil {
+			return "", fmt.Errorf("end_line and content are required with start_line")
+		}
+		if after, err = replaceLineRange(before, *a.StartLine, *a.EndLine, *a.Content); err != nil {
+			return "", err
+		}
+
+	default:
+		return "", fmt.Errorf("one of edits, start_line or patch is required")
+	}
+
+	diff := unifiedDiff(path, before, after)
+	if diff == "" {
+		return "", fmt.Errorf("edit did not change %s", path)
+	}
+
+	if err := os.WriteFile(absPath, []byte(after), info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	result := fmt.Sprintf("Successfully edited %s\n", path)
+	for _, note := range notes {
+		result += fmt.Sprintf("Note: %s\n", note)
+	}
+	return result + "\n" + diff, nil
+}
+
+// applyEdits applies search/replace blocks in order. Nothing is written
+// unless every block applies.
+func applyEdits(content string, edits []editBlock) (string, error) {
+	for i, edit := range edits {
+		if edit.OldString == "" {
+			return "", fmt.Errorf("edit #%d: old_string must not be empty", i+1)
+		}
+		count := strings.Count(content, edit.OldString)
+		switch {
+		case count == 0:
+			return "", fmt.Errorf("edit #%d: old_string not found in file; re-read the file and copy the text exactly, including whitespace", i+1)
+		case count > 1 && !edit.ReplaceAll:
+			return "", fmt.Errorf("edit #%d: old_string matches %d locations; include more surrounding lines to make it unique, or set replace_all", i+1, count)
+		}
+		if edit.ReplaceAll {
+			content = strings.ReplaceAll(content, edit.OldString, edit.NewString)
+		} else {
+			content = strings.Replace(content, edit.OldString, edit.NewString, 1)
+		}
+	}
+	return content, nil
+}
+
+// replaceLineRange replaces lines first..last with content
+func replaceLineRange(before string, first, last int, content string) (string, error) {
+	lines := splitLines(before)
+	if first < 1 || first > len(lines)+1 {
+		return "", fmt.Errorf("start_line %d is out of range (file has %d lines)", first, len(lines))
+	}
+	if last < first-1 || last > len(lines) {
+		return "", fmt.Errorf("end_line %d is out of range (start_line %d, file has %d lines)", last, first, len(lines))
+	}
+
+	updated := make([]string, 0, len(lines))
+	updated = append(updated, lines[:first-1]...)
+	updated = append(updated, splitLines(content)...)
+	updated = append(updated, lines[last:]...)
+	return joinLines(updated, strings.HasSuffix(before, "\n") || before == ""), nil
+}
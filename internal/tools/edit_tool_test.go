@@ -0,0 +1,216 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const patchFile = "one\ntwo\nthree\nfour\nfive\nsix\nseven\n"
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		patch    string
+		want     string
+		wantNote string
+		wantErr  string
+	}{
+		{
+			name:  "exact",
+			file:  patchFile,
+			patch: "--- a/f\n+++ b/f\n@@ -2,3 +2,3 @@\n two\n-three\n+THREE\n four\n",
+			want:  "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\n",
+		},
+		{
+			name:     "offset",
+			file:     "zero\n" + patchFile,
+			patch:    "@@ -2,3 +2,3 @@\n two\n-three\n+THREE\n four\n",
+			want:     "zero\none\ntwo\nTHREE\nfour\nfive\nsix\nseven\n",
+			wantNote: "hunk #1 applied at line 3",
+		},
+		{
+			name:     "fuzz",
+			file:     patchFile,
+			patch:    "@@ -1,5 +1,5 @@\n ONE\n two\n-three\n+THREE\n four\n FIVE\n",
+			want:     "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\n",
+			wantNote: "with fuzz 1",
+		},
+		{
+			name:     "trailing whitespace",
+			file:     "one  \ntwo\nthree\n",
+			patch:    "@@ -1,2 +1,2 @@\n one\n-two\n+TWO\n",
+			want:     "one  \nTWO\nthree\n",
+			wantNote: "ignoring trailing whitespace",
+		},
+		{
+			name:  "insertion",
+			file:  patchFile,
+			patch: "@@ -3,0 +4,1 @@\n+three and a half\n",
+			want:  "one\ntwo\nthree\nthree and a half\nfour\nfive\nsix\nseven\n",
+		},
+		{
+			name:  "two hunks",
+			file:  patchFile,
+			patch: "@@ -1,2 +1,2 @@\n-one\n+ONE\n two\n@@ -6,2 +6,3 @@\n six\n seven\n+eight\n",
+			want:  "ONE\ntwo\nthree\nfour\nfive\nsix\nseven\neight\n",
+		},
+		{
+			name:  "blank context line without its space",
+			file:  "a\n\nb\n",
+			patch: "@@ -1,3 +1,3 @@\n a\n\n-b\n+B\n",
+			want:  "a\n\nB\n",
+		},
+		{
+			name:    "mismatch",
+			file:    patchFile,
+			patch:   "@@ -2,3 +2,3 @@\n deux\n-trois\n+THREE\n quatre\n",
+			wantErr: "does not match the file",
+		},
+		{
+			name:    "no hunks",
+			file:    patchFile,
+			patch:   "just some text\n",
+			wantErr: "no hunks found",
+		},
+		{
+			name:    "second file",
+			file:    patchFile,
+			patch:   "@@ -1 +1 @@\n-one\n+ONE\n--- a/g\n+++ b/g\n@@ -1 +1 @@\n-x\n+y\n",
+			wantErr: "more than one file",
+		},
+		{
+			name:    "garbage line",
+			file:    patchFile,
+			patch:   "@@ -1 +1 @@\n-one\n+ONE\n*bold*\n",
+			wantErr: "unexpected line",
+		},
+	}
+	for _, tt := range tests {
+		lines, notes, err := applyPatch(splitLines(tt.file), tt.patch)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := joinLines(lines, true); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		if note := strings.Join(notes, "; "); !strings.Contains(note, tt.wantNote) || (tt.wantNote == "" && note != "") {
+			t.Errorf("%s: notes %q, want %q", tt.name, note, tt.wantNote)
+		}
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	tests := []struct {
+		name    string
+		edits   []editBlock
+		want    string
+		wantErr string
+	}{
+		{"single", []editBlock{{OldString: "b = 2", NewString: "b = 3"}}, "a = 1\nb = 3\na = 1\n", ""},
+		{"in order", []editBlock{{OldString: "b = 2", NewString: "c = 3"}, {OldString: "c = 3", NewString: "d = 4"}}, "a = 1\nd = 4\na = 1\n", ""},
+		{"replace all", []editBlock{{OldString: "a = 1", NewString: "a = 0", ReplaceAll: true}}, "a = 0\nb = 2\na = 0\n", ""},
+		{"ambiguous", []editBlock{{OldString: "a = 1", NewString: "a = 0"}}, "", "matches 2 locations"},
+		{"missing", []editBlock{{OldString: "z", NewString: "y"}}, "", "not found"},
+		{"empty", []editBlock{{OldString: "", NewString: "y"}}, "", "must not be empty"},
+		{"later block fails", []editBlock{{OldString: "b = 2", NewString: "b = 3"}, {OldString: "z", NewString: "y"}}, "", "edit #2"},
+	}
+	for _, tt := range tests {
+		got, err := applyEdits("a = 1\nb = 2\na = 1\n", tt.edits)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case got != tt.want:
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceLineRange(t *testing.T) {
+	tests := []struct {
+		name        string
+		first, last int
+		content     string
+		want        string
+		wantErr     string
+	}{
+		{"replace one line", 2, 2, "B", "a\nB\nc\n", ""},
+		{"replace with several", 1, 2, "x\ny\nz", "x\ny\nz\nc\n", ""},
+		{"insert", 2, 1, "new", "a\nnew\nb\nc\n", ""},
+		{"append", 4, 3, "d", "a\nb\nc\nd\n", ""},
+		{"delete", 2, 3, "", "a\n", ""},
+		{"start out of range", 5, 5, "x", "", "start_line 5 is out of range"},
+		{"end out of range", 2, 4, "x", "", "end_line 4 is out of range"},
+		{"end before start", 3, 1, "x", "", "end_line 1 is out of range"},
+	}
+	for _, tt := range tests {
+		got, err := replaceLineRange("a\nb\nc\n", tt.first, tt.last, tt.content)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case got != tt.want:
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEditFileExecute(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := NewPathPolicy([]Root{{Path: dir}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := &EditFile{Policy: policy}
+
+	out, err := tool.Execute(map[string]interface{}{
+		"path":       path,
+		"old_string": "func main() {}",
+		"new_string": "func main() {\n\tprintln(1)\n}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "+\tprintln(1)") || !strings.Contains(out, "-func main() {}") {
+		t.Errorf("result lacks the diff:\n%s", out)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "package main\n\nfunc main() {\n\tprintln(1)\n}\n" {
+		t.Errorf("file = %q", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"path": path, "old_string": "package main", "new_string": "package main"},
+		{"path": path},
+		{"path": path, "start_line": 1},
+		{"path": filepath.Join(dir, "missing.go"), "old_string": "a", "new_string": "b"},
+		{"path": filepath.Join(t.TempDir(), "outside.go"), "old_string": "a", "new_string": "b"},
+	} {
+		if _, err := tool.Execute(args); err == nil {
+			t.Errorf("Execute(%v) succeeded", args)
+		}
+	}
+}
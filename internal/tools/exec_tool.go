@@ -3,13 +3,15 @@ package tools
 import (
 	"context"
 	"fmt"
-	"os/exec"
 	"strings"
 	"time"
 )
 
 // ExecCommand executes a shell command
-type ExecCommand struct{}
+type ExecCommand struct {
+	// Runner executes the command. Defaults to PlainRunner.
+	Runner Runner
+}
 
 func (t *ExecCommand) Name() string {
 	return "exec_command"
@@ -19,47 +21,43 @@ func (t *ExecCommand) Description() string {
 	return "Execute a shell command and return its output. Use with caution."
 }
 
+type execCommandArgs struct {
+	Command string `json:"command" desc:"The shell command to execute" required:"true"`
+	Timeout int    `json:"timeout" desc:"Timeout in seconds (default: 30)" default:"30" minimum:"1"`
+}
+
 func (t *ExecCommand) Parameters() map[string]interface{} {
-	return map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"command": map[string]interface{}{
-				"type":        "string",
-				"description": "The shell command to execute",
-			},
-			"timeout": map[string]interface{}{
-				"type":        "integer",
-				"description": "Timeout in seconds (default: 30)",
-			},
-		},
-		"required": []string{"command"},
-	}
+	return SchemaFor[execCommandArgs]()
 }
 
 func (t *ExecCommand) Execute(args map[string]interface{}) (string, error) {
-	command, ok := args["command"].(string)
-	if !ok {
-		return "", fmt.Errorf("command argument is required")
-	}
+	return t.ExecuteAs(Caller{}, args)
+}
 
-	timeout := 30
-	if to, ok := args["timeout"].(float64); ok {
-		timeout = int(to)
+// ExecuteAs runs the command, stopping it early when the caller's turn runs
+// out of time
+func (t *ExecCommand) ExecuteAs(caller Caller, args map[string]interface{}) (string, error) {
+	a, err := DecodeArgs[execCommandArgs](t.Name(), args)
+	if err != nil {
+		return "", err
 	}
 
 	// Split command into parts
-	parts := strings.Fields(command)
+	parts := strings.Fields(a.Command)
 	if len(parts) == 0 {
 		return "", fmt.Errorf("empty command")
 	}
 
 	// Create command with timeout using context
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	ctx, cancel := context.WithTimeout(caller.Context(), time.Duration(a.Timeout)*time.Second)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
+	runner := t.Runner
+	if runner == nil {
+		runner = PlainRunner{}
+	}
 
 	// Execute and capture output
-	output, err := cmd.CombinedOutput()
+	output, err := runner.Run(ctx, parts[0], parts[1:])
 	if err != nil {
 		return "", fmt.Errorf("command failed: %w\nOutput: %s", err, string(output))
 	}
@@ -0,0 +1,35 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecCommand(t *testing.T) {
+	tool := &ExecCommand{}
+	out, err := tool.Execute(map[string]interface{}{"command": "echo hello world"})
+	if err != nil || out != "hello world\n" {
+		t.Errorf("echo = %q, %v", out, err)
+	}
+	if _, err := tool.Execute(map[string]interface{}{"command": "   "}); err == nil || !strings.Contains(err.Error(), "empty command") {
+		t.Errorf("blank command: %v", err)
+	}
+	if _, err := tool.Execute(map[string]interface{}{"command": "false"}); err == nil || !strings.Contains(err.Error(), "command failed") {
+		t.Errorf("failing command: %v", err)
+	}
+}
+
+func TestExecCommandCallerDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := (&ExecCommand{}).ExecuteAs(Caller{Ctx: ctx}, map[string]interface{}{"command": "sleep 10"})
+	if err == nil {
+		t.Fatal("sleep outlived the caller's deadline")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("command stopped after %s, not at the caller's deadline", elapsed)
+	}
+}
@@ -2,50 +2,83 @@ package tools
 
 import (
 	"fmt"
+	"io/fs"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// defaultReadMaxBytes caps read_file output when ReadFile.MaxBytes is unset
+	defaultReadMaxBytes = 100 * 1024
+	// maxReadFileSize is the largest file read_file will load
+	maxReadFileSize = 32 * 1024 * 1024
 )
 
 // ReadFile reads the content of a file
-type ReadFile struct{}
+type ReadFile struct {
+	Policy *PathPolicy
+	// MaxBytes caps the returned text; longer output is truncated with a notice
+	MaxBytes int
+}
 
 func (t *ReadFile) Name() string {
 	return "read_file"
 }
 
 func (t *ReadFile) Description() string {
-	return "Read the content of a file at the specified path"
+	return "Read the content of a file at the specified path. Large files are truncated; " +
+		"use offset and limit to page through them. Binary files return a summary instead of content."
+}
+
+// ParallelSafe marks ReadFile as read-only
+func (t *ReadFile) ParallelSafe() bool {
+	return true
+}
+
+type readFileArgs struct {
+	Path        string `json:"path" desc:"Absolute or relative path to the file" required:"true"`
+	Offset      int    `json:"offset" desc:"Line number to start reading from (1-based, default: 1)" default:"1" minimum:"1"`
+	Limit       int    `json:"limit" desc:"Maximum number of lines to read (default: all, subject to the size cap)" minimum:"0"`
+	LineNumbers bool   `json:"line_numbers" desc:"Prefix each line with its line number"`
+	Encoding    string `json:"encoding" desc:"Text encoding (default: auto-detect)" enum:"auto,utf-8,gbk,utf-16le,utf-16be" default:"auto"`
 }
 
 func (t *ReadFile) Parameters() map[string]interface{} {
-	return map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"path": map[string]interface{}{
-				"type":        "string",
-				"description": "Absolute or relative path to the file",
-			},
-		},
-		"required": []string{"path"},
-	}
+	return SchemaFor[readFileArgs]()
 }
 
 func (t *ReadFile) Execute(args map[string]interface{}) (string, error) {
-	path, ok := args["path"].(string)
-	if !ok {
-		return "", fmt.Errorf("path argument is required")
+	a, err := DecodeArgs[readFileArgs](t.Name(), args)
+	if err != nil {
+		return "", err
 	}
+	path, offset, limit := a.Path, a.Offset, a.Limit
 
-	// Resolve to absolute path
-	absPath, err := filepath.Abs(path)
+	// Resolve path and check it against the access policy
+	absPath, err := t.Policy.CheckRead(path)
 	if err != nil {
-		return "", fmt.Errorf("failed to resolve path: %w", err)
+		return "", err
 	}
 
 	// Check if path exists
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
+	info, err := os.Stat(absPath)
+	if os.IsNotExist(err) {
 		return "", fmt.Errorf("file does not exist: %s", path)
 	}
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory, use list_dir instead", path)
+	}
+	if info.Size() > maxReadFileSize {
+		return "", fmt.Errorf("file is too large (%d bytes); use exec_command with head, tail or grep instead", info.Size())
+	}
 
 	// Read file
 	content, err := os.ReadFile(absPath)
@@ -53,11 +86,100 @@ func (t *ReadFile) Execute(args map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
 
-	return string(content), nil
+	text, encoding, isText, err := decodeText(content, a.Encoding)
+	if err != nil {
+		return "", err
+	}
+	if !isText {
+		return binarySummary(path, info, content), nil
+	}
+
+	maxBytes := t.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultReadMaxBytes
+	}
+
+	// Fast path: the whole file fits and no formatting was requested
+	if offset == 1 && limit <= 0 && !a.LineNumbers && encoding == "utf-8" && len(text) <= maxBytes {
+		return text, nil
+	}
+
+	lines := splitLines(text)
+	if offset > len(lines) && len(lines) > 0 {
+		return "", fmt.Errorf("offset %d is past the end of the file (%d lines)", offset, len(lines))
+	}
+
+	last := len(lines)
+	if limit > 0 && offset-1+limit < last {
+		last = offset - 1 + limit
+	}
+
+	var out strings.Builder
+	shown := offset - 1
+	for i := offset - 1; i < last; i++ {
+		line := lines[i]
+		if a.LineNumbers {
+			line = fmt.Sprintf("%6d\t%s", i+1, line)
+		}
+		if out.Len()+len(line)+1 > maxBytes {
+			if i == offset-1 {
+				// A single oversized line: show as much of it as fits
+				out.WriteString(truncateUTF8(line, maxBytes))
+				out.WriteString("\n")
+				shown = i + 1
+			}
+			break
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+		shown = i + 1
+	}
+
+	var notes []string
+	if encoding != "utf-8" {
+		notes = append(notes, fmt.Sprintf("decoded from %s", encoding))
+	}
+	if offset > 1 || shown < len(lines) {
+		notes = append(notes, fmt.Sprintf("showing lines %d-%d of %d", offset, shown, len(lines)))
+	}
+	if shown < last {
+		notes = append(notes, fmt.Sprintf("truncated at %d bytes", maxBytes))
+	}
+	if shown < len(lines) {
+		notes = append(notes, fmt.Sprintf("use offset=%d to continue", shown+1))
+	}
+	if len(notes) > 0 {
+		out.WriteString(fmt.Sprintf("\n[%s]\n", strings.Join(notes, "; ")))
+	}
+
+	return out.String(), nil
+}
+
+// binarySummary describes a binary file instead of returning its bytes
+func binarySummary(path string, info os.FileInfo, content []byte) string {
+	head := content
+	if len(head) > 32 {
+		head = head[:32]
+	}
+	return fmt.Sprintf("Binary file %s\n  Size: %d bytes\n  Type: %s\n  Modified: %s\n  First bytes: % x\n",
+		path, info.Size(), http.DetectContentType(content), info.ModTime().Format("2006-01-02 15:04:05"), head)
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
 }
 
 // WriteFile writes content to a file
-type WriteFile struct{}
+type WriteFile struct {
+	Policy *PathPolicy
+}
 
 func (t *WriteFile) Name() string {
 	return "write_file"
@@ -67,38 +189,26 @@ func (t *WriteFile) Description() string {
 	return "Write content to a file at the specified path. Creates the file if it doesn't exist, overwrites if it does."
 }
 
+type writeFileArgs struct {
+	Path    string `json:"path" desc:"Absolute or relative path to the file" required:"true"`
+	Content string `json:"content" desc:"Content to write to the file" required:"true"`
+}
+
 func (t *WriteFile) Parameters() map[string]interface{} {
-	return map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"path": map[string]interface{}{
-				"type":        "string",
-				"description": "Absolute or relative path to the file",
-			},
-			"content": map[string]interface{}{
-				"type":        "string",
-				"description": "Content to write to the file",
-			},
-		},
-		"required": []string{"path", "content"},
-	}
+	return SchemaFor[writeFileArgs]()
 }
 
 func (t *WriteFile) Execute(args map[string]interface{}) (string, error) {
-	path, ok := args["path"].(string)
-	if !ok {
-		return "", fmt.Errorf("path argument is required")
-	}
-
-	content, ok := args["content"].(string)
-	if !ok {
-		return "", fmt.Errorf("content argument is required")
+	a, err := DecodeArgs[writeFileArgs](t.Name(), args)
+	if err != nil {
+		return "", err
 	}
+	path, content := a.Path, a.Content
 
-	// Resolve to absolute path
-	absPath, err := filepath.Abs(path)
+	// Resolve path and check it against the access policy
+	absPath, err := t.Policy.CheckWrite(path)
 	if err != nil {
-		return "", fmt.Errorf("failed to resolve path: %w", err)
+		return "", err
 	}
 
 	// Create directory if it doesn't exist
@@ -115,55 +225,167 @@ func (t *WriteFile) Execute(args map[string]interface{}) (string, error) {
 	return fmt.Sprintf("Successfully wrote %d bytes to %s", len(content), path), nil
 }
 
+// listDirMaxPerDir keeps one huge directory from using the whole budget
+const listDirMaxPerDir = 50
+
 // ListDir lists the contents of a directory
-type ListDir struct{}
+type ListDir struct {
+	Policy *PathPolicy
+}
 
 func (t *ListDir) Name() string {
 	return "list_dir"
 }
 
 func (t *ListDir) Description() string {
-	return "List the contents of a directory at the specified path"
+	return "List the contents of a directory as a tree with file sizes. Use depth to include subdirectories; " +
+		"entries ignored by .gitignore and hidden files are omitted by default."
+}
+
+// ParallelSafe marks ListDir as read-only
+func (t *ListDir) ParallelSafe() bool {
+	return true
+}
+
+type listDirArgs struct {
+	Path       string `json:"path" desc:"Absolute or relative path to the directory. Defaults to current directory." default:"."`
+	Depth      int    `json:"depth" desc:"How many directory levels to list (default: 1)" default:"1" minimum:"1"`
+	ShowHidden bool   `json:"show_hidden" desc:"Include entries whose names start with a dot"`
+	Gitignore  bool   `json:"gitignore" desc:"Omit entries ignored by .gitignore (default: true)" default:"true"`
+	Details    bool   `json:"details" desc:"Show modification times in addition to sizes"`
+	MaxEntries int    `json:"max_entries" desc:"Maximum number of entries to list (default: 200)" default:"200" minimum:"1"`
 }
 
 func (t *ListDir) Parameters() map[string]interface{} {
-	return map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"path": map[string]interface{}{
-				"type":        "string",
-				"description": "Absolute or relative path to the directory. Defaults to current directory.",
-			},
-		},
-	}
+	return SchemaFor[listDirArgs]()
 }
 
 func (t *ListDir) Execute(args map[string]interface{}) (string, error) {
-	path := "."
-	if p, ok := args["path"].(string); ok {
-		path = p
+	a, err := DecodeArgs[listDirArgs](t.Name(), args)
+	if err != nil {
+		return "", err
 	}
+	path := a.Path
 
-	// Resolve to absolute path
-	absPath, err := filepath.Abs(path)
+	// Resolve path and check it against the access policy
+	absPath, err := t.Policy.CheckRead(path)
 	if err != nil {
-		return "", fmt.Errorf("failed to resolve path: %w", err)
+		return "", err
 	}
 
-	// Read directory
-	entries, err := os.ReadDir(absPath)
+	root := &treeNode{isDir: true}
+	nodes := map[string]*treeNode{"": root}
+	total := 0
+	truncated := false
+
+	opts := walkOptions{Policy: t.Policy, UseGitignore: a.Gitignore, ShowHidden: a.ShowHidden, MaxDepth: a.Depth}
+	err = walkTree(absPath, opts, func(full, rel string, d fs.DirEntry, level int) error {
+		parent := nodes[filepath.ToSlash(filepath.Dir(filepath.FromSlash(rel)))]
+		if parent == nil {
+			parent = root
+		}
+		if len(parent.children) >= listDirMaxPerDir || total >= a.MaxEntries {
+			parent.omitted++
+			truncated = truncated || total >= a.MaxEntries
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		node := &treeNode{name: d.Name(), isDir: d.IsDir()}
+		if info, err := d.Info(); err == nil {
+			node.size = info.Size()
+			node.modTime = info.ModTime()
+			if info.Mode()&os.ModeSymlink != 0 {
+				node.linkTarget, _ = os.Readlink(full)
+				node.symlink = true
+			}
+		}
+		parent.children = append(parent.children, node)
+		if d.IsDir() {
+			nodes[rel] = node
+		}
+		total++
+		return nil
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to read directory: %w", err)
 	}
 
-	result := fmt.Sprintf("Contents of %s:\n", absPath)
-	for _, entry := range entries {
-		if entry.IsDir() {
-			result += fmt.Sprintf("  [DIR]  %s\n", entry.Name())
-		} else {
-			result += fmt.Sprintf("  [FILE] %s\n", entry.Name())
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("Contents of %s:\n", absPath))
+	if len(root.children) == 0 && root.omitted == 0 {
+		out.WriteString("  (empty)\n")
+	}
+	renderTree(&out, root, "", a.Details)
+	if truncated {
+		out.WriteString(fmt.Sprintf("\n[stopped after %d entries; list a subdirectory or lower depth to see more]\n", a.MaxEntries))
+	}
+
+	return out.String(), nil
+}
+
+// treeNode is an entry in a list_dir tree
+type treeNode struct {
+	name       string
+	isDir      bool
+	symlink    bool
+	linkTarget string
+	size       int64
+	modTime    time.Time
+	children   []*treeNode
+	omitted    int // Entries not shown because of caps
+}
+
+// renderTree writes node's children with box-drawing guides, directories first
+func renderTree(out *strings.Builder, node *treeNode, prefix string, details bool) {
+	children := append([]*treeNode(nil), node.children...)
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].isDir && !children[j].isDir
+	})
+
+	for i, child := range children {
+		last := i == len(children)-1 && node.omitted == 0
+		branch, indent := "├── ", "│   "
+		if last {
+			branch, indent = "└── ", "    "
+		}
+
+		line := prefix + branch + child.name
+		switch {
+		case child.symlink:
+			line += " -> " + child.linkTarget
+		case child.isDir:
+			line += "/"
+		default:
+			line += "  " + formatSize(child.size)
+		}
+		if details {
+			line += "  " + child.modTime.Format("2006-01-02 15:04")
+		}
+		out.WriteString(line + "\n")
+
+		if child.isDir {
+			renderTree(out, child, prefix+indent, details)
 		}
 	}
 
-	return result, nil
+	if node.omitted > 0 {
+		out.WriteString(fmt.Sprintf("%s└── ... %d more entries\n", prefix, node.omitted))
+	}
+}
+
+// formatSize renders a byte count in human-readable units
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(size)/float64(div), "KMGTPE"[exp])
 }
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListDir(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".gitignore":         "*.log\n",
+		".hidden":            "",
+		"README.md":          strings.Repeat("x", 2048),
+		"app.log":            "",
+		"cmd/goclaw/main.go": "package main\n",
+		"internal/a.go":      "",
+		"internal/b.go":      "",
+		"internal/c.go":      "",
+	})
+	if err := os.Symlink("README.md", filepath.Join(dir, "link.md")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	tool := &ListDir{}
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want string
+	}{
+		{"top level", map[string]interface{}{},
+			"├── cmd/\n├── internal/\n├── README.md  2.0K\n└── link.md -> README.md\n"},
+		{"tree", map[string]interface{}{"depth": 3.0},
+			"├── cmd/\n│   └── goclaw/\n│       └── main.go  13B\n" +
+				"├── internal/\n│   ├── a.go  0B\n│   ├── b.go  0B\n│   └── c.go  0B\n" +
+				"├── README.md  2.0K\n└── link.md -> README.md\n"},
+		{"hidden and ignored", map[string]interface{}{"show_hidden": true, "gitignore": false},
+			"├── cmd/\n├── internal/\n├── .gitignore  6B\n├── .hidden  0B\n├── README.md  2.0K\n├── app.log  0B\n└── link.md -> README.md\n"},
+		{"max entries", map[string]interface{}{"depth": 2.0, "max_entries": 3.0},
+			"├── cmd/\n│   └── goclaw/\n├── README.md  2.0K\n└── ... 2 more entries\n\n" +
+				"[stopped after 3 entries; list a subdirectory or lower depth to see more]\n"},
+	}
+	for _, tt := range tests {
+		tt.args["path"] = dir
+		got, err := tool.Execute(tt.args)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got = strings.TrimPrefix(got, "Contents of "+dir+":\n")
+		if got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+
+	empty := t.TempDir()
+	if got, err := tool.Execute(map[string]interface{}{"path": empty}); err != nil || !strings.HasSuffix(got, "  (empty)\n") {
+		t.Errorf("empty directory: %q, %v", got, err)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for size, want := range map[int64]string{
+		0:             "0B",
+		1023:          "1023B",
+		1024:          "1.0K",
+		1536:          "1.5K",
+		5 << 20:       "5.0M",
+		3 << 30:       "3.0G",
+		1<<40 + 1<<39: "1.5T",
+	} {
+		if got := formatSize(size); got != want {
+			t.Errorf("formatSize(%d) = %s, want %s", size, got, want)
+		}
+	}
+}
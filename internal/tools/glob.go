@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// matchGlob reports whether a slash-separated path matches pattern.
+// In addition to filepath.Match syntax, "**" matches any number of
+// directories and "{a,b}" matches either alternative.
+func matchGlob(pattern, path string) bool {
+	for _, expanded := range expandBraces(pattern) {
+		if matchSegments(strings.Split(expanded, "/"), strings.Split(path, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse consecutive "**" and try every split point
+			for len(pattern) > 1 && pattern[1] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(path) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+// expandBraces expands the first {a,b,...} group recursively
+func expandBraces(pattern string) []string {
+	open := strings.IndexByte(pattern, '{')
+	if open < 0 {
+		return []string{pattern}
+	}
+
+	depth := 0
+	var alternatives []string
+	start := open + 1
+	for i := open; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				alternatives = append(alternatives, pattern[start:i])
+				var result []string
+				for _, alt := range alternatives {
+					result = append(result, expandBraces(pattern[:open]+alt+pattern[i+1:])...)
+				}
+				return result
+			}
+		case ',':
+			if depth == 1 {
+				alternatives = append(alternatives, pattern[start:i])
+				start = i + 1
+			}
+		}
+	}
+	// Unbalanced brace: treat literally
+	return []string{pattern}
+}
+
+// validGlob checks pattern syntax so errors can be reported to the model
+func validGlob(pattern string) bool {
+	for _, expanded := range expandBraces(pattern) {
+		for _, segment := range strings.Split(expanded, "/") {
+			if _, err := filepath.Match(segment, ""); err != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/goclaw/main.go", true},
+		{"cmd/**/main.go", "cmd/main.go", true},
+		{"cmd/**/main.go", "cmd/a/b/main.go", true},
+		{"cmd/**/main.go", "internal/cmd/main.go", false},
+		{"cmd/**", "cmd/a/b", true},
+		{"**/**/x", "a/x", true},
+		{"src/*.ts", "src/a/b.ts", false},
+		{"*.{go,md}", "README.md", true},
+		{"*.{go,md}", "go.mod", false},
+		{"cmd/**/main.{go,ts}", "cmd/web/main.ts", true},
+		{"{a,b{c,d}}/x", "bd/x", true},
+		{"{a,b{c,d}}/x", "b/x", false},
+		{"file[0-9].txt", "file7.txt", true},
+		{"?.txt", "ab.txt", false},
+		{"{unbalanced", "{unbalanced", true},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"plain", []string{"plain"}},
+		{"*.{go,ts}", []string{"*.go", "*.ts"}},
+		{"{a,b}/{c,d}", []string{"a/c", "a/d", "b/c", "b/d"}},
+		{"x{a,{b,c}}", []string{"xa", "xb", "xc"}},
+		{"x{,s}", []string{"x", "xs"}},
+		{"open{a,b", []string{"open{a,b"}},
+	}
+	for _, tt := range tests {
+		if got := expandBraces(tt.pattern); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandBraces(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestValidGlob(t *testing.T) {
+	for pattern, want := range map[string]bool{
+		"**/*.go":     true,
+		"{a,b}/[xy]*": true,
+		"[":           false,
+		"src/{a,[}":   false,
+	} {
+		if got := validGlob(pattern); got != want {
+			t.Errorf("validGlob(%q) = %v, want %v", pattern, got, want)
+		}
+	}
+}
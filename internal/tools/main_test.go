@@ -0,0 +1,12 @@
+package tools
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// SandboxRunner re-executes the test binary as its init helper
+	HandleSandboxInit()
+	os.Exit(m.Run())
+}
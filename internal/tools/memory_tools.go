@@ -21,16 +21,12 @@ func (t *SaveConversation) Description() string {
 	return "保存当前对话到 markdown 文件。使用 LLM 生成描述性文件名。"
 }
 
+type saveConversationArgs struct {
+	Title string `json:"title" desc:"对话的简短描述，用于生成文件名（可选）"`
+}
+
 func (t *SaveConversation) Parameters() map[string]interface{} {
-	return map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"title": map[string]interface{}{
-				"type":        "string",
-				"description": "对话的简短描述，用于生成文件名（可选）",
-			},
-		},
-	}
+	return SchemaFor[saveConversationArgs]()
 }
 
 func (t *SaveConversation) Execute(args map[string]interface{}) (string, error) {
@@ -52,24 +48,25 @@ func (t *MemorySearch) Description() string {
 	return "在记忆文件中搜索相关信息。搜索 MEMORY.md 和 memory/*.md"
 }
 
+// ParallelSafe marks MemorySearch as read-only
+func (t *MemorySearch) ParallelSafe() bool {
+	return true
+}
+
+type memorySearchArgs struct {
+	Query string `json:"query" desc:"搜索关键词或问题" required:"true"`
+}
+
 func (t *MemorySearch) Parameters() map[string]interface{} {
-	return map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"query": map[string]interface{}{
-				"type":        "string",
-				"description": "搜索关键词或问题",
-			},
-		},
-		"required": []string{"query"},
-	}
+	return SchemaFor[memorySearchArgs]()
 }
 
 func (t *MemorySearch) Execute(args map[string]interface{}) (string, error) {
-	query, ok := args["query"].(string)
-	if !ok {
-		return "", fmt.Errorf("query 参数是必需的")
+	a, err := DecodeArgs[memorySearchArgs](t.Name(), args)
+	if err != nil {
+		return "", err
 	}
+	query := a.Query
 
 	results := t.searchInMemory(query)
 	if len(results) == 0 {
@@ -135,26 +132,30 @@ func (t *MemoryGet) Description() string {
 	return "读取指定记忆文件的完整内容"
 }
 
+// ParallelSafe marks MemoryGet as read-only
+func (t *MemoryGet) ParallelSafe() bool {
+	return true
+}
+
+type memoryGetArgs struct {
+	Filename string `json:"filename" desc:"文件名，例如 MEMORY.md 或 conversations/xxx.md" required:"true"`
+}
+
 func (t *MemoryGet) Parameters() map[string]interface{} {
-	return map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"filename": map[string]interface{}{
-				"type":        "string",
-				"description": "文件名，例如 MEMORY.md 或 conversations/xxx.md",
-			},
-		},
-		"required": []string{"filename"},
-	}
+	return SchemaFor[memoryGetArgs]()
 }
 
 func (t *MemoryGet) Execute(args map[string]interface{}) (string, error) {
-	filename, ok := args["filename"].(string)
-	if !ok {
-		return "", fmt.Errorf("filename 参数是必需的")
+	a, err := DecodeArgs[memoryGetArgs](t.Name(), args)
+	if err != nil {
+		return "", err
 	}
+	filename := a.Filename
 
-	path := filepath.Join(t.WorkspaceDir, "memory", filename)
+	path, err := memoryFilePath(t.WorkspaceDir, filename)
+	if err != nil {
+		return "", err
+	}
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return "", fmt.Errorf("无法读取文件 %s: %w", filename, err)
@@ -163,6 +164,33 @@ func (t *MemoryGet) Execute(args map[string]interface{}) (string, error) {
 	return string(content), nil
 }
 
+// memoryFilePath resolves filename inside <workspace>/memory, refusing
+// names that lead out of it, also through symlinks
+func memoryFilePath(workspaceDir, filename string) (string, error) {
+	rel := filepath.Clean(filename)
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("文件 %s 不在记忆目录中", filename)
+	}
+	dir, err := filepath.Abs(filepath.Join(workspaceDir, "memory"))
+	if err != nil {
+		return "", fmt.Errorf("无法解析记忆目录: %w", err)
+	}
+	path := filepath.Join(dir, rel)
+
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("无法读取文件 %s: %w", filename, err)
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("无法读取文件 %s: %w", filename, err)
+	}
+	if r, err := filepath.Rel(realDir, realPath); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("文件 %s 不在记忆目录中", filename)
+	}
+	return realPath, nil
+}
+
 // UpdateMemory updates MEMORY.md with new information
 type UpdateMemory struct {
 	WorkspaceDir string
@@ -176,30 +204,22 @@ func (t *UpdateMemory) Description() string {
 	return "更新长期记忆文件 MEMORY.md，添加新的重要信息"
 }
 
+type updateMemoryArgs struct {
+	Content string `json:"content" desc:"要添加的内容" required:"true"`
+	Section string `json:"section" desc:"目标章节（可选），例如：用户偏好、重要事项"`
+}
+
 func (t *UpdateMemory) Parameters() map[string]interface{} {
-	return map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"content": map[string]interface{}{
-				"type":        "string",
-				"description": "要添加的内容",
-			},
-			"section": map[string]interface{}{
-				"type":        "string",
-				"description": "目标章节（可选），例如：用户偏好、重要事项",
-			},
-		},
-		"required": []string{"content"},
-	}
+	return SchemaFor[updateMemoryArgs]()
 }
 
 func (t *UpdateMemory) Execute(args map[string]interface{}) (string, error) {
-	content, ok := args["content"].(string)
-	if !ok {
-		return "", fmt.Errorf("content 参数是必需的")
+	a, err := DecodeArgs[updateMemoryArgs](t.Name(), args)
+	if err != nil {
+		return "", err
 	}
+	content, section := a.Content, a.Section
 
-	section, _ := args["section"].(string)
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 
 	memoryPath := filepath.Join(t.WorkspaceDir, "memory", "MEMORY.md")
@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// spillMaxAge is how long spilled outputs are kept before being pruned
+const spillMaxAge = 24 * time.Hour
+
+// OutputLimiter caps tool results before they are sent to the model.
+// Oversized results keep their head and tail around an elision marker,
+// and the full text is saved under SpillDir so the model can page
+// through it with read_file.
+type OutputLimiter struct {
+	MaxBytes int    // 0 for unlimited
+	SpillDir string // Where full outputs are saved; empty disables spilling
+}
+
+// Limit returns output unchanged when it fits, or a shortened version
+// that points at the saved full output
+func (l *OutputLimiter) Limit(toolName, output string) string {
+	if l == nil || l.MaxBytes <= 0 || len(output) <= l.MaxBytes {
+		return output
+	}
+
+	var marker string
+	if path, err := l.spill(toolName, output); err == nil {
+		marker = fmt.Sprintf("full output (%d bytes, %d lines) saved to %s; use read_file with offset/limit to see the omitted part",
+			len(output), len(splitLines(output)), path)
+	} else {
+		marker = fmt.Sprintf("full output is %d bytes and could not be saved: %v", len(output), err)
+	}
+
+	// Two thirds of the budget for the head, the rest for the tail
+	headLen := l.MaxBytes * 2 / 3
+	tailLen := l.MaxBytes - headLen
+	head := cutAtLine(truncateUTF8(output, headLen), true)
+	tail := cutAtLine(tailUTF8(output, tailLen), false)
+	omitted := len(output) - len(head) - len(tail)
+
+	return fmt.Sprintf("%s\n\n[... %d bytes omitted; %s ...]\n\n%s", strings.TrimRight(head, "\n"), omitted, marker, tail)
+}
+
+// spill writes output to a new file in SpillDir and returns its path
+func (l *OutputLimiter) spill(toolName, output string) (string, error) {
+	if l.SpillDir == "" {
+		return "", fmt.Errorf("no spill directory configured")
+	}
+	if err := os.MkdirAll(l.SpillDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create spill directory: %w", err)
+	}
+	l.prune()
+
+	f, err := os.CreateTemp(l.SpillDir, fmt.Sprintf("%s-%s-*.txt", time.Now().Format("20060102-150405"), toolName))
+	if err != nil {
+		return "", fmt.Errorf("failed to create spill file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(output); err != nil {
+		return "", fmt.Errorf("failed to write spill file: %w", err)
+	}
+
+	path, err := filepath.Abs(f.Name())
+	if err != nil {
+		return f.Name(), nil
+	}
+	return path, nil
+}
+
+// prune removes spilled outputs older than spillMaxAge
+func (l *OutputLimiter) prune() {
+	entries, err := os.ReadDir(l.SpillDir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-spillMaxAge)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".txt") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && info.ModTime().Before(cutoff) {
+			os.Remove(filepath.Join(l.SpillDir, entry.Name()))
+		}
+	}
+}
+
+// tailUTF8 returns at most the last n bytes of s without splitting a rune
+func tailUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	start := len(s) - n
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	return s[start:]
+}
+
+// cutAtLine drops the partial line at the cut end of s, unless that would
+// leave less than half of it
+func cutAtLine(s string, isHead bool) string {
+	if isHead {
+		if i := strings.LastIndexByte(s, '\n'); i >= len(s)/2 {
+			return s[:i+1]
+		}
+		return s
+	}
+	if i := strings.IndexByte(s, '\n'); i >= 0 && i < len(s)/2 {
+		return s[i+1:]
+	}
+	return s
+}
@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestOutputLimiter(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 100; i++ {
+		lines = append(lines, fmt.Sprintf("line %03d", i))
+	}
+	output := strings.Join(lines, "\n") + "\n" // 900 bytes
+
+	dir := filepath.Join(t.TempDir(), "scratch")
+	l := &OutputLimiter{MaxBytes: 90, SpillDir: dir}
+
+	if got := l.Limit("exec_command", "short"); got != "short" {
+		t.Errorf("short output changed: %q", got)
+	}
+	if got := (&OutputLimiter{SpillDir: dir}).Limit("exec_command", output); got != output {
+		t.Error("an unlimited limiter changed the output")
+	}
+
+	got := l.Limit("exec_command", output)
+	m := regexp.MustCompile(`(?s)^(.*)\n\n\[\.\.\. (\d+) bytes omitted; full output \(900 bytes, 100 lines\) saved to (\S+); use read_file .*\.\.\.\]\n\n(.*)$`).FindStringSubmatch(got)
+	if m == nil {
+		t.Fatalf("unexpected result:\n%s", got)
+	}
+	head, omitted, path, tail := m[1], m[2], m[3], m[4]
+	if head != "line 001\nline 002\nline 003\nline 004\nline 005\nline 006" {
+		t.Errorf("head = %q", head)
+	}
+	if tail != "line 098\nline 099\nline 100\n" {
+		t.Errorf("tail = %q", tail)
+	}
+	if omitted != fmt.Sprint(len(output)-len(head)-1-len(tail)) {
+		t.Errorf("omitted = %s", omitted)
+	}
+	if !strings.HasPrefix(path, dir) || !strings.Contains(filepath.Base(path), "exec_command") {
+		t.Errorf("spill path = %s", path)
+	}
+	if saved, err := os.ReadFile(path); err != nil || string(saved) != output {
+		t.Errorf("spilled output differs: %v", err)
+	}
+
+	// Without a spill directory the result is still capped
+	got = (&OutputLimiter{MaxBytes: 90}).Limit("exec_command", output)
+	if !strings.Contains(got, "full output is 900 bytes and could not be saved") || len(got) > 300 {
+		t.Errorf("unsaved result:\n%s", got)
+	}
+}
+
+func TestOutputLimiterUTF8(t *testing.T) {
+	output := strings.Repeat("中文", 100) // No newlines to cut at
+	got := (&OutputLimiter{MaxBytes: 50}).Limit("read_file", output)
+	if !utf8.ValidString(got) {
+		t.Errorf("result splits a character: %q", got)
+	}
+}
+
+func TestOutputLimiterPrune(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.txt")
+	keep := filepath.Join(dir, "notes.md")
+	for _, path := range []string{old, keep} {
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		past := time.Now().Add(-2 * spillMaxAge)
+		if err := os.Chtimes(path, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	(&OutputLimiter{MaxBytes: 1, SpillDir: dir}).Limit("exec_command", "too long")
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("an expired spill file was kept")
+	}
+	if _, err := os.Stat(keep); err != nil {
+		t.Error("a file that is not a spill file was removed")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("%d entries, want the new spill file and notes.md", len(entries))
+	}
+}
@@ -0,0 +1,226 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxPatchFuzz is the number of leading/trailing context lines that may be
+// ignored when a hunk does not match exactly, as in GNU patch
+const maxPatchFuzz = 2
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+type patchHunk struct {
+	oldStart int // 1-based line number in the original file
+	lines    []diffOp
+}
+
+// parsePatch parses the hunks of a single-file unified diff. File headers
+// are optional; everything before the first hunk header is ignored.
+func parsePatch(patch string) ([]patchHunk, error) {
+	var hunks []patchHunk
+	var current *patchHunk
+
+	lines := strings.Split(strings.TrimSuffix(patch, "\n"), "\n")
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if m := hunkHeaderRe.FindStringSubmatch(line); m != nil {
+			start, _ := strconv.Atoi(m[1])
+			hunks = append(hunks, patchHunk{oldStart: start})
+			current = &hunks[len(hunks)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff ") ||
+			(strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")):
+			// Header of a following file; only single-file patches are supported
+			return nil, fmt.Errorf("patch line %d: patch touches more than one file", i+1)
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file"
+		case line == "":
+			// Blank context lines often lose their leading space
+			current.lines = append(current.lines, diffOp{' ', ""})
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			current.lines = append(current.lines, diffOp{line[0], line[1:]})
+		default:
+			return nil, fmt.Errorf("patch line %d: unexpected line %q", i+1, line)
+		}
+	}
+
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("no hunks found in patch (expected lines starting with @@)")
+	}
+	return hunks, nil
+}
+
+// applyPatch applies a unified diff to lines. Each hunk is located near its
+// recorded position, allowing an offset, then up to maxPatchFuzz ignored
+// context lines, then trailing-whitespace differences.
+func applyPatch(lines []string, patch string) ([]string, []string, error) {
+	hunks, err := parsePatch(patch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := append([]string(nil), lines...)
+	var notes []string
+	offset := 0 // Lines added minus lines removed by previous hunks
+	minPos := 0 // Hunks must apply in order
+
+	for n, hunk := range hunks {
+		expected := hunk.oldStart - 1 + offset
+		if old, _ := hunkSides(hunk, 0); len(old) == 0 {
+			// "@@ -N,0" inserts after line N
+			expected++
+		}
+
+		pos, fuzz, loose, ok := locateHunk(result, hunk, expected, minPos)
+		if !ok {
+			return nil, nil, fmt.Errorf("hunk #%d (@@ -%d) does not match the file; re-read the file and regenerate the patch", n+1, hunk.oldStart)
+		}
+
+		old, replacement := hunkSides(hunk, fuzz)
+		if loose {
+			// Context lines keep the file's whitespace, not the patch's
+			replacement = replacement[:0]
+			i := pos
+			for _, op := range trimContext(hunk, fuzz) {
+				switch op.kind {
+				case ' ':
+					replacement = append(replacement, result[i])
+					i++
+				case '-':
+					i++
+				case '+':
+					replacement = append(replacement, op.text)
+				}
+			}
+		}
+		if pos != expected+leadTrimmed(hunk, fuzz) || fuzz > 0 || loose {
+			note := fmt.Sprintf("hunk #%d applied at line %d", n+1, pos+1)
+			if fuzz > 0 {
+				note += fmt.Sprintf(" with fuzz %d", fuzz)
+			}
+			if loose {
+				note += " ignoring trailing whitespace"
+			}
+			notes = append(notes, note)
+		}
+
+		updated := make([]string, 0, len(result)-len(old)+len(replacement))
+		updated = append(updated, result[:pos]...)
+		updated = append(updated, replacement...)
+		updated = append(updated, result[pos+len(old):]...)
+		result = updated
+
+		offset += len(replacement) - len(old)
+		minPos = pos + len(replacement)
+	}
+
+	return result, notes, nil
+}
+
+// locateHunk finds where the old side of hunk occurs in lines, preferring
+// positions closest to expected
+func locateHunk(lines []string, hunk patchHunk, expected, minPos int) (pos, fuzz int, loose, ok bool) {
+	prevLen := -1
+	for fuzz = 0; fuzz <= maxPatchFuzz; fuzz++ {
+		old, _ := hunkSides(hunk, fuzz)
+		if len(old) == prevLen {
+			// No more context lines to drop
+			break
+		}
+		prevLen = len(old)
+		for _, loose = range []bool{false, true} {
+			if pos, ok = searchLines(lines, old, expected+leadTrimmed(hunk, fuzz), minPos, loose); ok {
+				return pos, fuzz, loose, true
+			}
+		}
+	}
+	return 0, 0, false, false
+}
+
+// hunkSides returns the old and new lines of a hunk after dropping up to
+// fuzz context lines from each end
+func hunkSides(hunk patchHunk, fuzz int) ([]string, []string) {
+	var old, replacement []string
+	for _, op := range trimContext(hunk, fuzz) {
+		if op.kind != '+' {
+			old = append(old, op.text)
+		}
+		if op.kind != '-' {
+			replacement = append(replacement, op.text)
+		}
+	}
+	return old, replacement
+}
+
+// trimContext drops up to fuzz context lines from each end of hunk
+func trimContext(hunk patchHunk, fuzz int) []diffOp {
+	ops := hunk.lines
+	for i := 0; i < fuzz && len(ops) > 0 && ops[0].kind == ' '; i++ {
+		ops = ops[1:]
+	}
+	for i := 0; i < fuzz && len(ops) > 0 && ops[len(ops)-1].kind == ' '; i++ {
+		ops = ops[:len(ops)-1]
+	}
+	return ops
+}
+
+// leadTrimmed returns how many leading context lines fuzz removes
+func leadTrimmed(hunk patchHunk, fuzz int) int {
+	n := 0
+	for n < fuzz && n < len(hunk.lines) && hunk.lines[n].kind == ' ' {
+		n++
+	}
+	return n
+}
+
+// searchLines looks for needle in lines at positions >= minPos, starting at
+// expected and moving outwards
+func searchLines(lines, needle []string, expected, minPos int, loose bool) (int, bool) {
+	if len(needle) == 0 {
+		// Pure insertion with no context
+		if expected < minPos {
+			expected = minPos
+		}
+		if expected > len(lines) {
+			expected = len(lines)
+		}
+		return expected, true
+	}
+
+	for delta := 0; delta <= len(lines); delta++ {
+		for _, pos := range []int{expected - delta, expected + delta} {
+			if pos < minPos || pos+len(needle) > len(lines) {
+				continue
+			}
+			if linesEqual(lines[pos:pos+len(needle)], needle, loose) {
+				return pos, true
+			}
+			if delta == 0 {
+				break
+			}
+		}
+	}
+	return 0, false
+}
+
+func linesEqual(a, b []string, loose bool) bool {
+	for i := range a {
+		if a[i] == b[i] {
+			continue
+		}
+		if !loose || strings.TrimRight(a[i], " \t\r") != strings.TrimRight(b[i], " \t\r") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,186 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Root is a directory the file tools are allowed to access
+type Root struct {
+	Path     string
+	ReadOnly bool
+}
+
+// PathPolicy confines file tools to a set of root directories.
+// A nil *PathPolicy allows access to any path.
+type PathPolicy struct {
+	roots []Root
+	deny  []string
+}
+
+// NewPathPolicy creates a policy from root directories and denied globs.
+// Root paths are made absolute and have their symlinks resolved so that
+// containment checks compare real locations on disk.
+func NewPathPolicy(roots []Root, deny []string) (*PathPolicy, error) {
+	resolved := make([]Root, 0, len(roots))
+	for _, root := range roots {
+		absPath, err := filepath.Abs(expandHome(root.Path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve root %s: %w", root.Path, err)
+		}
+		realPath, err := filepath.EvalSymlinks(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve root %s: %w", root.Path, err)
+		}
+		resolved = append(resolved, Root{Path: realPath, ReadOnly: root.ReadOnly})
+	}
+
+	for _, pattern := range deny {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid deny pattern %q: %w", pattern, err)
+		}
+	}
+
+	return &PathPolicy{roots: resolved, deny: deny}, nil
+}
+
+// Roots returns the resolved root directories
+func (p *PathPolicy) Roots() []Root {
+	if p == nil {
+		return nil
+	}
+	return p.roots
+}
+
+// CheckRead resolves path and verifies it may be read
+func (p *PathPolicy) CheckRead(path string) (string, error) {
+	return p.check(path, false)
+}
+
+// CheckWrite resolves path and verifies it may be written
+func (p *PathPolicy) CheckWrite(path string) (string, error) {
+	return p.check(path, true)
+}
+
+// Allowed reports whether path may be read, without returning the reason.
+// Used to filter directory listings.
+func (p *PathPolicy) Allowed(path string) bool {
+	_, err := p.check(path, false)
+	return err == nil
+}
+
+func (p *PathPolicy) check(path string, write bool) (string, error) {
+	absPath, err := filepath.Abs(expandHome(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+	if p == nil {
+		return absPath, nil
+	}
+
+	realPath, err := resolveSymlinks(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	root, rel, ok := p.findRoot(realPath)
+	if !ok {
+		return "", fmt.Errorf("access denied: %s is outside the allowed directories (%s)", path, p.rootList())
+	}
+
+	if pattern, denied := p.matchDeny(rel); denied {
+		return "", fmt.Errorf("access denied: %s matches the denied pattern %q", path, pattern)
+	}
+
+	if write && root.ReadOnly {
+		return "", fmt.Errorf("access denied: %s is in a read-only directory (%s)", path, root.Path)
+	}
+
+	return realPath, nil
+}
+
+// findRoot returns the most specific root containing path
+func (p *PathPolicy) findRoot(path string) (Root, string, bool) {
+	var best Root
+	var bestRel string
+	found := false
+	for _, root := range p.roots {
+		rel, err := filepath.Rel(root.Path, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if !found || len(root.Path) > len(best.Path) {
+			best, bestRel, found = root, rel, true
+		}
+	}
+	return best, bestRel, found
+}
+
+// matchDeny checks every component of rel against the denied globs,
+// as well as the full relative path for patterns containing a separator
+func (p *PathPolicy) matchDeny(rel string) (string, bool) {
+	if rel == "." {
+		return "", false
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	for _, pattern := range p.deny {
+		if strings.ContainsRune(pattern, filepath.Separator) {
+			if ok, _ := filepath.Match(pattern, rel); ok {
+				return pattern, true
+			}
+			continue
+		}
+		for _, part := range parts {
+			if ok, _ := filepath.Match(pattern, part); ok {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (p *PathPolicy) rootList() string {
+	paths := make([]string, len(p.roots))
+	for i, root := range p.roots {
+		paths[i] = root.Path
+	}
+	return strings.Join(paths, ", ")
+}
+
+// resolveSymlinks evaluates symlinks in path. When path does not exist yet
+// (e.g. a file about to be written), the nearest existing ancestor is
+// resolved and the remaining components are appended unchanged.
+func resolveSymlinks(path string) (string, error) {
+	realPath, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return realPath, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	// A dangling symlink would be followed on write, so refuse it
+	if _, lerr := os.Lstat(path); lerr == nil {
+		return "", fmt.Errorf("%s is a dangling symlink", path)
+	}
+
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path, nil
+	}
+	realParent, err := resolveSymlinks(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(realParent, filepath.Base(path)), nil
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
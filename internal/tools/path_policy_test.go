@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathPolicy(t *testing.T) {
+	base := t.TempDir()
+	work := filepath.Join(base, "work")
+	docs := filepath.Join(base, "docs")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{work, filepath.Join(work, ".git"), filepath.Join(work, "sub"), docs, outside} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join(work, "a.txt"), filepath.Join(docs, "d.txt"), filepath.Join(outside, "secret.txt")} {
+		if err := os.WriteFile(file, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		filepath.Join(work, "escape"):     outside,
+		filepath.Join(work, "escape.txt"): filepath.Join(outside, "secret.txt"),
+		filepath.Join(work, "dangling"):   filepath.Join(outside, "missing.txt"),
+		filepath.Join(work, "to-docs"):    docs,
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks unavailable: %v", err)
+		}
+	}
+
+	policy, err := NewPathPolicy([]Root{{Path: work}, {Path: docs, ReadOnly: true}}, []string{".git", "*.pem", "sub/private*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		write   bool
+		wantErr string
+	}{
+		{"file in root", filepath.Join(work, "a.txt"), false, ""},
+		{"write in root", filepath.Join(work, "a.txt"), true, ""},
+		{"new file in new directory", filepath.Join(work, "new", "b.txt"), true, ""},
+		{"root itself", work, false, ""},
+		{"dot-dot escape", filepath.Join(work, "..", "outside", "secret.txt"), false, "outside the allowed directories"},
+		{"outside", filepath.Join(outside, "secret.txt"), false, "outside the allowed directories"},
+		{"symlinked directory escape", filepath.Join(work, "escape", "secret.txt"), false, "outside the allowed directories"},
+		{"symlinked file escape", filepath.Join(work, "escape.txt"), false, "outside the allowed directories"},
+		{"write through symlinked directory", filepath.Join(work, "escape", "new.txt"), true, "outside the allowed directories"},
+		{"dangling symlink", filepath.Join(work, "dangling"), true, "dangling symlink"},
+		{"symlink to another root", filepath.Join(work, "to-docs", "d.txt"), false, ""},
+		{"read-only root", filepath.Join(docs, "d.txt"), false, ""},
+		{"write to read-only root", filepath.Join(docs, "d.txt"), true, "read-only"},
+		{"write via symlink to read-only root", filepath.Join(work, "to-docs", "d.txt"), true, "read-only"},
+		{"denied directory", filepath.Join(work, ".git", "config"), false, `".git"`},
+		{"denied directory itself", filepath.Join(work, ".git"), false, `".git"`},
+		{"denied extension", filepath.Join(work, "sub", "key.pem"), false, `"*.pem"`},
+		{"denied relative pattern", filepath.Join(work, "sub", "private.txt"), false, `"sub/private*"`},
+		{"relative pattern is anchored", filepath.Join(work, "other", "sub", "private.txt"), false, ""},
+	}
+	for _, tt := range tests {
+		check := policy.CheckRead
+		if tt.write {
+			check = policy.CheckWrite
+		}
+		_, err := check(tt.path)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPathPolicyResolvesPaths(t *testing.T) {
+	dir := t.TempDir()
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := NewPathPolicy([]Root{{Path: dir}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := policy.CheckWrite(filepath.Join(dir, "x", "..", "y.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(real, "y.txt"); got != want {
+		t.Errorf("CheckWrite = %s, want %s", got, want)
+	}
+
+	// A nil policy allows everything
+	var open *PathPolicy
+	if _, err := open.CheckWrite("/etc/passwd"); err != nil {
+		t.Errorf("nil policy: %v", err)
+	}
+
+	if _, err := NewPathPolicy([]Root{{Path: filepath.Join(dir, "missing")}}, nil); err == nil {
+		t.Error("a missing root was accepted")
+	}
+	if _, err := NewPathPolicy(nil, []string{"[bad"}); err == nil {
+		t.Error("an invalid deny pattern was accepted")
+	}
+}
@@ -0,0 +1,249 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultPluginTimeout applies when a manifest sets no timeout
+const defaultPluginTimeout = 30 * time.Second
+
+var pluginNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// PluginManifest describes a tool implemented by an external executable.
+// Manifests are JSON files in the plugin directory:
+//
+//	{
+//	  "name": "jira_issue",
+//	  "description": "Look up a Jira issue by key",
+//	  "parameters": {"type": "object", "properties": {"key": {"type": "string"}}, "required": ["key"]},
+//	  "command": ["./jira-issue.sh"],
+//	  "timeout": 20
+//	}
+//
+// The command receives the validated arguments as a JSON object on stdin
+// and its stdout is returned to the model. A non-zero exit status is
+// reported as an error together with stderr.
+type PluginManifest struct {
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description"`
+	Parameters   map[string]interface{} `json:"parameters"`
+	Command      []string               `json:"command"`       // Relative executables resolve against the manifest directory
+	Timeout      int                    `json:"timeout"`       // Seconds, default 30
+	ParallelSafe bool                   `json:"parallel_safe"` // Plugin only reads state
+	ReadPaths    []string               `json:"read_paths"`    // Arguments holding paths the plugin reads
+	WritePaths   []string               `json:"write_paths"`   // Arguments holding paths the plugin writes
+}
+
+// PluginTool runs a PluginManifest. Path arguments listed in the manifest
+// are checked against Policy like the built-in file tools and passed to
+// the plugin as absolute paths. The plugin runs through Runner like
+// exec_command, in the first writable policy root, and only sees PATH,
+// HOME and the GOCLAW_* variables of the host environment.
+type PluginTool struct {
+	Manifest PluginManifest
+	Dir      string // Directory of the manifest
+	Policy   *PathPolicy
+	Runner   Runner // Defaults to PlainRunner
+}
+
+func (t *PluginTool) Name() string {
+	return t.Manifest.Name
+}
+
+func (t *PluginTool) Description() string {
+	return t.Manifest.Description
+}
+
+func (t *PluginTool) Parameters() map[string]interface{} {
+	return t.Manifest.Parameters
+}
+
+// ParallelSafe reports what the manifest declared
+func (t *PluginTool) ParallelSafe() bool {
+	return t.Manifest.ParallelSafe
+}
+
+func (t *PluginTool) Execute(args map[string]interface{}) (string, error) {
+	return t.ExecuteAs(Caller{}, args)
+}
+
+// ExecuteAs runs the plugin, stopping it early when the caller's turn runs
+// out of time
+func (t *PluginTool) ExecuteAs(caller Caller, args map[string]interface{}) (string, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	if err := ValidateArgs(t.Name(), t.Parameters(), args); err != nil {
+		return "", err
+	}
+	if err := t.checkPaths(args); err != nil {
+		return "", err
+	}
+
+	input, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode arguments: %w", err)
+	}
+
+	timeout := defaultPluginTimeout
+	if t.Manifest.Timeout > 0 {
+		timeout = time.Duration(t.Manifest.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(caller.Context(), timeout)
+	defer cancel()
+
+	command := t.Manifest.Command[0]
+	if strings.Contains(command, "/") && !filepath.IsAbs(command) {
+		command = filepath.Join(t.Dir, command)
+	}
+	runner := t.Runner
+	if runner == nil {
+		runner = PlainRunner{}
+	}
+	var stdout, stderr bytes.Buffer
+	err = runner.RunCommand(ctx, &Command{
+		Name:         command,
+		Args:         t.Manifest.Command[1:],
+		Dir:          t.workDir(),
+		Env:          pluginEnv(t.Name()),
+		Stdin:        bytes.NewReader(input),
+		Stdout:       &stdout,
+		Stderr:       &stderr,
+		ReadOnlyDirs: []string{t.Dir},
+	})
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("plugin %s timed out after %s", t.Name(), timeout)
+		}
+		return "", fmt.Errorf("plugin %s failed: %w\nStderr: %s\nOutput: %s", t.Name(), err, stderr.String(), stdout.String())
+	}
+	return stdout.String(), nil
+}
+
+// workDir is the first writable policy root, or the manifest directory
+// when file access is unrestricted
+func (t *PluginTool) workDir() string {
+	roots := t.Policy.Roots()
+	for _, root := range roots {
+		if !root.ReadOnly {
+			return root.Path
+		}
+	}
+	if len(roots) > 0 {
+		return roots[0].Path
+	}
+	return t.Dir
+}
+
+// pluginEnv is the environment of a plugin process: BaseEnv, never
+// credentials such as API keys, and the tool's name
+func pluginEnv(name string) []string {
+	var env []string
+	for _, kv := range BaseEnv() {
+		if !strings.HasPrefix(kv, "GOCLAW_TOOL_NAME=") {
+			env = append(env, kv)
+		}
+	}
+	return append(env, "GOCLAW_TOOL_NAME="+name)
+}
+
+// checkPaths resolves the declared path arguments through the policy and
+// replaces them with the resolved absolute paths
+func (t *PluginTool) checkPaths(args map[string]interface{}) error {
+	check := func(names []string, write bool) error {
+		for _, name := range names {
+			path, ok := args[name].(string)
+			if !ok || path == "" {
+				continue
+			}
+			var resolved string
+			var err error
+			if write {
+				resolved, err = t.Policy.CheckWrite(path)
+			} else {
+				resolved, err = t.Policy.CheckRead(path)
+			}
+			if err != nil {
+				return err
+			}
+			args[name] = resolved
+		}
+		return nil
+	}
+	if err := check(t.Manifest.ReadPaths, false); err != nil {
+		return err
+	}
+	return check(t.Manifest.WritePaths, true)
+}
+
+// LoadPlugins registers a PluginTool for every *.json manifest in dir, run
+// by runner, and returns the names of the loaded tools. A missing
+// directory loads nothing. Invalid manifests and names that clash with
+// already registered tools are skipped and reported in the returned error;
+// the valid ones are still registered.
+func (r *Registry) LoadPlugins(dir string, policy *PathPolicy, runner Runner) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plugins: %w", err)
+	}
+	sort.Strings(files)
+
+	var loaded, problems []string
+	for _, file := range files {
+		tool, err := loadPluginManifest(file, policy, runner)
+		if err == nil {
+			if _, exists := r.Get(tool.Name()); exists {
+				err = fmt.Errorf("a tool named %s is already registered", tool.Name())
+			}
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", filepath.Base(file), err))
+			continue
+		}
+		r.Register(tool)
+		loaded = append(loaded, tool.Name())
+	}
+
+	if len(problems) > 0 {
+		return loaded, fmt.Errorf("skipped plugins:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return loaded, nil
+}
+
+func loadPluginManifest(file string, policy *PathPolicy, runner Runner) (*PluginTool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var m PluginManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	if !pluginNamePattern.MatchString(m.Name) {
+		return nil, fmt.Errorf("invalid name %q: use letters, digits, _ and - only", m.Name)
+	}
+	if m.Description == "" {
+		return nil, fmt.Errorf("description is required")
+	}
+	if len(m.Command) == 0 || m.Command[0] == "" {
+		return nil, fmt.Errorf("command is required")
+	}
+	if m.Parameters == nil {
+		m.Parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	if schemaType, _ := m.Parameters["type"].(string); schemaType != "object" {
+		return nil, fmt.Errorf("parameters must be a JSON schema of type object")
+	}
+
+	return &PluginTool{Manifest: m, Dir: filepath.Dir(file), Policy: policy, Runner: runner}, nil
+}
@@ -0,0 +1,128 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePlugin writes an executable script and its manifest to dir
+func writePlugin(t *testing.T, dir, name, script, manifest string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name+".sh"), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPluginTool(t *testing.T) {
+	pluginDir := t.TempDir()
+	root := t.TempDir()
+	writePlugin(t, pluginDir, "inspect", `cat; echo; pwd; env | sort`, `{
+		"name": "inspect",
+		"description": "Show the input and environment",
+		"parameters": {"type": "object", "properties": {"file": {"type": "string"}, "n": {"type": "integer"}}, "required": ["file"]},
+		"command": ["./inspect.sh"],
+		"read_paths": ["file"],
+		"parallel_safe": true
+	}`)
+	writePlugin(t, pluginDir, "fail", `echo oops >&2; exit 3`, `{"name": "fail", "description": "Fail", "command": ["./fail.sh"]}`)
+	writePlugin(t, pluginDir, "slow", `sleep 10`, `{"name": "slow", "description": "Sleep", "command": ["./slow.sh"], "timeout": 1}`)
+
+	t.Setenv("GOCLAW_ZHIPU_API_KEY", "secret-1")
+	t.Setenv("ZHIPU_API_KEY", "secret-2")
+	t.Setenv("GOCLAW_PROJECT", "demo")
+	t.Setenv("GOCLAW_TOOL_NAME", "spoofed")
+
+	policy, err := NewPathPolicy([]Root{{Path: root}}, []string{"*.pem"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := New()
+	loaded, err := reg.LoadPlugins(pluginDir, policy, nil)
+	if err != nil || strings.Join(loaded, ",") != "fail,inspect,slow" {
+		t.Fatalf("LoadPlugins = %v, %v", loaded, err)
+	}
+	if !reg.IsParallelSafe("inspect") || reg.IsParallelSafe("fail") {
+		t.Error("parallel_safe not taken from the manifests")
+	}
+
+	realRoot, _ := filepath.EvalSymlinks(root)
+	out, err := reg.ExecuteToolCall("inspect", `{"file": "`+filepath.Join(root, "notes.txt")+`", "n": "2"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, rest, _ := strings.Cut(out, "\n")
+	if want := `{"file":"` + filepath.Join(realRoot, "notes.txt") + `","n":2}`; input != want {
+		t.Errorf("stdin = %s, want %s", input, want)
+	}
+	for _, want := range []string{"\nPWD=" + realRoot + "\n", "GOCLAW_PROJECT=demo", "GOCLAW_TOOL_NAME=inspect", "PATH="} {
+		if !strings.Contains(rest, want) {
+			t.Errorf("output lacks %q:\n%s", want, rest)
+		}
+	}
+	for _, leaked := range []string{"secret-1", "secret-2", "spoofed"} {
+		if strings.Contains(rest, leaked) {
+			t.Errorf("plugin environment contains %s:\n%s", leaked, rest)
+		}
+	}
+
+	tests := []struct {
+		name, tool, args, wantErr string
+	}{
+		{"missing argument", "inspect", `{}`, "file: required property is missing"},
+		{"path outside the roots", "inspect", `{"file": "/etc/passwd"}`, "outside the allowed directories"},
+		{"denied path", "inspect", `{"file": "` + filepath.Join(root, "key.pem") + `"}`, "denied pattern"},
+		{"failure", "fail", `{}`, "exit status 3\nStderr: oops"},
+		{"timeout", "slow", `{}`, "plugin slow timed out after 1s"},
+	}
+	for _, tt := range tests {
+		if _, err := reg.ExecuteToolCall(tt.tool, tt.args); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadPluginsSkipsInvalidManifests(t *testing.T) {
+	dir := t.TempDir()
+	manifests := map[string]string{
+		"good.json":      `{"name": "good", "description": "Fine", "command": ["true"]}`,
+		"broken.json":    `{"name": `,
+		"badname.json":   `{"name": "bad name", "description": "x", "command": ["true"]}`,
+		"nodesc.json":    `{"name": "nodesc", "command": ["true"]}`,
+		"nocommand.json": `{"name": "nocommand", "description": "x"}`,
+		"array.json":     `{"name": "array", "description": "x", "command": ["true"], "parameters": {"type": "array"}}`,
+		"clash.json":     `{"name": "read_file", "description": "x", "command": ["true"]}`,
+		"ignored.txt":    `not a manifest`,
+	}
+	for name, content := range manifests {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reg := New()
+	reg.Register(&ReadFile{})
+	loaded, err := reg.LoadPlugins(dir, nil, nil)
+	if strings.Join(loaded, ",") != "good" {
+		t.Errorf("loaded %v, want only good", loaded)
+	}
+	for _, want := range []string{
+		"array.json: parameters must be a JSON schema of type object",
+		"badname.json: invalid name",
+		"broken.json: invalid manifest",
+		"clash.json: a tool named read_file is already registered",
+		"nocommand.json: command is required",
+		"nodesc.json: description is required",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error %v lacks %q", err, want)
+		}
+	}
+
+	if loaded, err := reg.LoadPlugins(filepath.Join(dir, "missing"), nil, nil); err != nil || len(loaded) != 0 {
+		t.Errorf("missing directory: %v, %v", loaded, err)
+	}
+}
@@ -1,8 +1,10 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 // Tool represents a tool that can be executed
@@ -13,6 +15,29 @@ type Tool interface {
 	Execute(args map[string]interface{}) (string, error)
 }
 
+// Caller identifies whom a tool call runs for
+type Caller struct {
+	Session string          // Conversation session
+	KeyID   int64           // Gateway API key, 0 for local use and the host itself
+	Ctx     context.Context // Done when the turn runs out of time; nil for no limit
+}
+
+// Context returns c.Ctx, or a background context when it is nil
+func (c Caller) Context() context.Context {
+	if c.Ctx == nil {
+		return context.Background()
+	}
+	return c.Ctx
+}
+
+// CallerAware is implemented by tools that keep data per caller, such as
+// reminders, or that honour the caller's deadline, such as exec_command,
+// plugins and MCP tools. The registry calls ExecuteAs instead of Execute
+// for them.
+type CallerAware interface {
+	ExecuteAs(caller Caller, args map[string]interface{}) (string, error)
+}
+
 // Registry manages available tools
 type Registry struct {
 	tools map[string]Tool
@@ -61,22 +86,61 @@ func (r *Registry) ToProviderFormat() []map[string]interface{} {
 	return result
 }
 
-// ExecuteToolCall executes a tool call with the given arguments
+// ExecuteToolCall executes a tool call with the given arguments on behalf
+// of no particular caller
 func (r *Registry) ExecuteToolCall(name string, argsJSON string) (string, error) {
+	return r.ExecuteToolCallAs(Caller{}, name, argsJSON)
+}
+
+// ExecuteToolCallAs executes a tool call for caller
+func (r *Registry) ExecuteToolCallAs(caller Caller, name string, argsJSON string) (string, error) {
 	tool, ok := r.Get(name)
 	if !ok {
 		return "", fmt.Errorf("tool not found: %s", name)
 	}
 
-	var args map[string]interface{}
-	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
-		return "", fmt.Errorf("failed to parse arguments: %w", err)
+	args := map[string]interface{}{}
+	if strings.TrimSpace(argsJSON) != "" {
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return "", fmt.Errorf("failed to parse arguments as a JSON object: %w", err)
+		}
+		if args == nil {
+			args = map[string]interface{}{}
+		}
 	}
 
-	result, err := tool.Execute(args)
+	// Validate before executing so the model gets every problem at once
+	if err := ValidateArgs(name, tool.Parameters(), args); err != nil {
+		return "", err
+	}
+
+	var result string
+	var err error
+	if ca, ok := tool.(CallerAware); ok {
+		result, err = ca.ExecuteAs(caller, args)
+	} else {
+		result, err = tool.Execute(args)
+	}
 	if err != nil {
 		return "", fmt.Errorf("tool execution failed: %w", err)
 	}
 
 	return result, nil
 }
+
+// ParallelSafe is implemented by tools that can run concurrently with other
+// parallel-safe tools, typically because they only read state
+type ParallelSafe interface {
+	ParallelSafe() bool
+}
+
+// IsParallelSafe reports whether the named tool declared itself safe to run
+// concurrently. Unknown tools and tools without a declaration are not.
+func (r *Registry) IsParallelSafe(name string) bool {
+	tool, ok := r.Get(name)
+	if !ok {
+		return false
+	}
+	ps, ok := tool.(ParallelSafe)
+	return ok && ps.ParallelSafe()
+}
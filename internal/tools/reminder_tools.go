@@ -0,0 +1,266 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/goclaw2/internal/scheduler"
+)
+
+// identityTimezonePattern finds the time zone line written by `goclaw init`
+// ("- **时区**: Asia/Shanghai") or a plain "timezone: ..." line
+var identityTimezonePattern = regexp.MustCompile(`(?im)^\s*[-*]?\s*(?:\*\*)?(?:时区|time ?zone)(?:\*\*)?[ \t]*[:：][ \t]*(?:\*\*)?[ \t]*(\S+)`)
+
+// offsetZonePattern matches zones written as UTC+8 or GMT-05:30
+var offsetZonePattern = regexp.MustCompile(`^(?i:UTC|GMT)([+-])(\d{1,2})(?::?(\d{2}))?$`)
+
+// userLocation returns the user's time zone from IDENTITY.md in the
+// workspace, or fallback when the file names none or an unknown one
+func userLocation(workspaceDir string, fallback *time.Location) *time.Location {
+	if fallback == nil {
+		fallback = time.Local
+	}
+	data, err := os.ReadFile(filepath.Join(workspaceDir, "IDENTITY.md"))
+	if err != nil {
+		return fallback
+	}
+	m := identityTimezonePattern.FindSubmatch(data)
+	if m == nil {
+		return fallback
+	}
+	name := strings.TrimSpace(string(m[1]))
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc
+	}
+	if m := offsetZonePattern.FindStringSubmatch(name); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		minutes, _ := strconv.Atoi(m[3])
+		offset := hours*3600 + minutes*60
+		if m[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(name, offset)
+	}
+	return fallback
+}
+
+// reminderLayouts are the absolute formats set_reminder accepts
+var reminderLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"2006/01/02 15:04",
+}
+
+// parseReminderTime resolves "at" in loc. A bare clock time means its next
+// occurrence.
+func parseReminderTime(at string, now time.Time, loc *time.Location) (time.Time, error) {
+	at = strings.TrimSpace(at)
+	if t, err := time.Parse(time.RFC3339, at); err == nil {
+		return t, nil
+	}
+	for _, layout := range reminderLayouts {
+		if t, err := time.ParseInLocation(layout, at, loc); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if clock, err := time.Parse(layout, at); err == nil {
+			local := now.In(loc)
+			t := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, loc)
+			if !t.After(now) {
+				t = t.AddDate(0, 0, 1)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse time %q: use \"YYYY-MM-DD HH:MM\", \"HH:MM\" or RFC 3339", at)
+}
+
+// checkReminderCaller refuses calls that belong to no conversation, such
+// as those from MCP clients, since nobody could receive the reminder
+func checkReminderCaller(tool string, caller Caller) error {
+	if caller.Session == "" {
+		return fmt.Errorf("%s is only available in a conversation", tool)
+	}
+	return nil
+}
+
+// SetReminder schedules a message to be delivered to the user later, in
+// the session and for the API key that set it
+type SetReminder struct {
+	Store        *scheduler.ReminderStore
+	WorkspaceDir string         // IDENTITY.md here records the user's time zone
+	Location     *time.Location // Used when IDENTITY.md names no time zone
+}
+
+func (t *SetReminder) Name() string {
+	return "set_reminder"
+}
+
+func (t *SetReminder) Description() string {
+	return "设置提醒，到时间后通知用户。用 at 指定用户时区的时间（\"2024-05-01 17:00\" 或 \"17:00\"），" +
+		"或用 in 指定相对时间（\"30m\"、\"2h\"）。"
+}
+
+type setReminderArgs struct {
+	Message string `json:"message" desc:"提醒内容，到时原样发给用户" required:"true"`
+	At      string `json:"at,omitempty" desc:"用户时区的时间：YYYY-MM-DD HH:MM、HH:MM（下一次出现的该时刻）或 RFC 3339"`
+	In      string `json:"in,omitempty" desc:"从现在起的时长，如 30m、2h、1h30m"`
+}
+
+func (t *SetReminder) Parameters() map[string]interface{} {
+	return SchemaFor[setReminderArgs]()
+}
+
+func (t *SetReminder) Execute(args map[string]interface{}) (string, error) {
+	return t.ExecuteAs(Caller{}, args)
+}
+
+func (t *SetReminder) ExecuteAs(caller Caller, args map[string]interface{}) (string, error) {
+	if err := checkReminderCaller(t.Name(), caller); err != nil {
+		return "", err
+	}
+	a, err := DecodeArgs[setReminderArgs](t.Name(), args)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(a.Message) == "" {
+		return "", fmt.Errorf("message must not be empty")
+	}
+	if (a.At == "") == (a.In == "") {
+		return "", fmt.Errorf("give exactly one of at or in")
+	}
+
+	loc := userLocation(t.WorkspaceDir, t.Location)
+	now := time.Now()
+	var due time.Time
+	if a.In != "" {
+		d, err := time.ParseDuration(strings.TrimSpace(a.In))
+		if err != nil || d <= 0 {
+			return "", fmt.Errorf("invalid duration %q: use a positive duration such as 30m or 2h", a.In)
+		}
+		due = now.Add(d)
+	} else {
+		if due, err = parseReminderTime(a.At, now, loc); err != nil {
+			return "", err
+		}
+		if !due.After(now) {
+			return "", fmt.Errorf("%s is in the past (now %s)", due.In(loc).Format("2006-01-02 15:04 MST"), now.In(loc).Format("2006-01-02 15:04 MST"))
+		}
+	}
+
+	r, err := t.Store.Add(caller.Session, caller.KeyID, strings.TrimSpace(a.Message), due, loc)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("已设置提醒 #%d：%s（%s）", r.ID, due.In(loc).Format("2006-01-02 15:04 MST"), r.Message), nil
+}
+
+// ListReminders shows the caller's pending reminders
+type ListReminders struct {
+	Store        *scheduler.ReminderStore
+	WorkspaceDir string
+	Location     *time.Location
+}
+
+func (t *ListReminders) Name() string {
+	return "list_reminders"
+}
+
+func (t *ListReminders) Description() string {
+	return "列出待发送的提醒及其编号，并显示用户时区的当前时间"
+}
+
+// ParallelSafe marks ListReminders as read-only
+func (t *ListReminders) ParallelSafe() bool {
+	return true
+}
+
+type listRemindersArgs struct {
+	All bool `json:"all" desc:"同时列出已发送和已取消的提醒"`
+}
+
+func (t *ListReminders) Parameters() map[string]interface{} {
+	return SchemaFor[listRemindersArgs]()
+}
+
+func (t *ListReminders) Execute(args map[string]interface{}) (string, error) {
+	return t.ExecuteAs(Caller{}, args)
+}
+
+func (t *ListReminders) ExecuteAs(caller Caller, args map[string]interface{}) (string, error) {
+	if err := checkReminderCaller(t.Name(), caller); err != nil {
+		return "", err
+	}
+	a, err := DecodeArgs[listRemindersArgs](t.Name(), args)
+	if err != nil {
+		return "", err
+	}
+	reminders, err := t.Store.List(caller.Session, caller.KeyID, a.All)
+	if err != nil {
+		return "", err
+	}
+
+	loc := userLocation(t.WorkspaceDir, t.Location)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "当前时间：%s\n", time.Now().In(loc).Format("2006-01-02 15:04 MST"))
+	if len(reminders) == 0 {
+		sb.WriteString("没有提醒")
+		return sb.String(), nil
+	}
+	for _, r := range reminders {
+		fmt.Fprintf(&sb, "#%d  %s  %s", r.ID, r.DueAt.In(loc).Format("2006-01-02 15:04 MST"), r.Message)
+		if status := r.Status(); status != "pending" {
+			fmt.Fprintf(&sb, "  [%s]", status)
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+// CancelReminder cancels one of the caller's pending reminders
+type CancelReminder struct {
+	Store *scheduler.ReminderStore
+}
+
+func (t *CancelReminder) Name() string {
+	return "cancel_reminder"
+}
+
+func (t *CancelReminder) Description() string {
+	return "按编号取消一个待发送的提醒（编号见 list_reminders）"
+}
+
+type cancelReminderArgs struct {
+	ID int64 `json:"id" desc:"提醒编号" required:"true" minimum:"1"`
+}
+
+func (t *CancelReminder) Parameters() map[string]interface{} {
+	return SchemaFor[cancelReminderArgs]()
+}
+
+func (t *CancelReminder) Execute(args map[string]interface{}) (string, error) {
+	return t.ExecuteAs(Caller{}, args)
+}
+
+func (t *CancelReminder) ExecuteAs(caller Caller, args map[string]interface{}) (string, error) {
+	if err := checkReminderCaller(t.Name(), caller); err != nil {
+		return "", err
+	}
+	a, err := DecodeArgs[cancelReminderArgs](t.Name(), args)
+	if err != nil {
+		return "", err
+	}
+	r, err := t.Store.Cancel(caller.Session, caller.KeyID, a.ID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("已取消提醒 #%d：%s", r.ID, r.Message), nil
+}
@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/goclaw2/internal/scheduler"
+	_ "modernc.org/sqlite"
+)
+
+func TestParseReminderTime(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2030, 5, 1, 10, 0, 0, 0, shanghai)
+
+	tests := []struct {
+		at      string
+		want    string // In Shanghai time
+		wantErr bool
+	}{
+		{"2030-05-02 17:30", "2030-05-02 17:30:00", false},
+		{" 2030-05-02T17:30:15 ", "2030-05-02 17:30:15", false},
+		{"2030/05/02 17:30", "2030-05-02 17:30:00", false},
+		{"2030-05-02T09:30:00Z", "2030-05-02 17:30:00", false},
+		{"17:30", "2030-05-01 17:30:00", false},
+		{"09:30", "2030-05-02 09:30:00", false}, // Already past today
+		{"10:00", "2030-05-02 10:00:00", false}, // Now is not the future
+		{"tomorrow", "", true},
+		{"25:00", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseReminderTime(tt.at, now, shanghai)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: got %s, want an error", tt.at, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.at, err)
+			continue
+		}
+		if s := got.In(shanghai).Format("2006-01-02 15:04:05"); s != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.at, s, tt.want)
+		}
+	}
+}
+
+func TestUserLocation(t *testing.T) {
+	tests := []struct {
+		identity string // "" for no IDENTITY.md
+		want     string
+		offset   int // Seconds east of UTC in 2030
+	}{
+		{"", "UTC", 0},
+		{"- **时区**: Asia/Shanghai\n", "Asia/Shanghai", 8 * 3600},
+		{"# Me\ntimezone: America/New_York\n", "America/New_York", -5 * 3600},
+		{"Time Zone：Europe/Berlin", "Europe/Berlin", 3600},
+		{"时区: UTC+5:30", "UTC+5:30", 5*3600 + 30*60},
+		{"时区: GMT-3", "GMT-3", -3 * 3600},
+		{"时区: Mars/Olympus", "UTC", 0},
+		{"no zone here", "UTC", 0},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if tt.identity != "" {
+			if err := os.WriteFile(filepath.Join(dir, "IDENTITY.md"), []byte(tt.identity), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		loc := userLocation(dir, time.UTC)
+		_, offset := time.Date(2030, 1, 15, 12, 0, 0, 0, loc).Zone()
+		if loc.String() != tt.want || offset != tt.offset {
+			t.Errorf("%q: %s (%+d), want %s (%+d)", tt.identity, loc, offset, tt.want, tt.offset)
+		}
+	}
+}
+
+func TestReminderTools(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "goclaw.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store, err := scheduler.NewReminderStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := New()
+	reg.Register(&SetReminder{Store: store, Location: time.UTC})
+	reg.Register(&ListReminders{Store: store, Location: time.UTC})
+	reg.Register(&CancelReminder{Store: store})
+
+	alice := Caller{Session: "s", KeyID: 1}
+	bob := Caller{Session: "s", KeyID: 2}
+	steps := []struct {
+		name    string
+		caller  Caller
+		tool    string
+		args    string
+		want    string // Regular expression the result must match
+		wantErr string
+	}{
+		{"no conversation", Caller{}, "set_reminder", `{"message": "x", "in": "1h"}`, "", "only available in a conversation"},
+		{"at and in", alice, "set_reminder", `{"message": "x", "in": "1h", "at": "10:00"}`, "", "exactly one of at or in"},
+		{"neither", alice, "set_reminder", `{"message": "x"}`, "", "exactly one of at or in"},
+		{"empty message", alice, "set_reminder", `{"message": " ", "in": "1h"}`, "", "must not be empty"},
+		{"bad duration", alice, "set_reminder", `{"message": "x", "in": "-5m"}`, "", "invalid duration"},
+		{"past time", alice, "set_reminder", `{"message": "x", "at": "2000-01-01 10:00"}`, "", "is in the past"},
+		{"relative", alice, "set_reminder", `{"message": " stretch ", "in": "30m"}`, `^已设置提醒 #1：.* UTC（stretch）$`, ""},
+		{"absolute", alice, "set_reminder", `{"message": "launch", "at": "2099-01-01 08:00"}`, `^已设置提醒 #2：2099-01-01 08:00 UTC（launch）$`, ""},
+		{"list", alice, "list_reminders", `{}`, `^当前时间：.*\n#1  .*  stretch\n#2  2099-01-01 08:00 UTC  launch$`, ""},
+		{"other key sees none", bob, "list_reminders", `{}`, `没有提醒$`, ""},
+		{"other key cannot cancel", bob, "cancel_reminder", `{"id": 1}`, "", "no pending reminder with id 1"},
+		{"cancel", alice, "cancel_reminder", `{"id": 1}`, `^已取消提醒 #1：stretch$`, ""},
+		{"list pending", alice, "list_reminders", `{}`, `^当前时间：[^\n]*\n#2 [^\n]*launch$`, ""},
+		{"list all", alice, "list_reminders", `{"all": true}`, `#1 .*stretch  \[cancelled\]\n#2 `, ""},
+	}
+	for _, step := range steps {
+		got, err := reg.ExecuteToolCallAs(step.caller, step.tool, step.args)
+		if step.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), step.wantErr) {
+				t.Errorf("%s: got %q, %v; want error %q", step.name, got, err, step.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", step.name, err)
+			continue
+		}
+		if !regexp.MustCompile(step.want).MatchString(got) {
+			t.Errorf("%s: got %q, want %q", step.name, got, step.want)
+		}
+	}
+}
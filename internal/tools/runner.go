@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// BaseEnv is the environment third-party processes such as plugins and MCP
+// servers start from: PATH, HOME and the host's GOCLAW_* variables, except
+// those that look like credentials (GOCLAW_ZHIPU_API_KEY and the like)
+func BaseEnv() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name := kv[:strings.IndexByte(kv+"=", '=')]
+		switch {
+		case name == "PATH", name == "HOME":
+		case strings.HasPrefix(name, "GOCLAW_") && !isSecretName(name):
+		default:
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
+
+func isSecretName(name string) bool {
+	for _, word := range []string{"KEY", "SECRET", "TOKEN", "PASSWORD"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// Runner executes commands
+type Runner interface {
+	// Run executes a command and returns its combined output
+	Run(ctx context.Context, name string, args []string) ([]byte, error)
+	// RunCommand executes c with its own I/O and environment
+	RunCommand(ctx context.Context, c *Command) error
+}
+
+// Command is a process started by Runner.RunCommand. It does not inherit
+// the host environment: Env is all it gets, apart from the PATH and HOME a
+// sandbox sets for itself.
+type Command struct {
+	Name   string
+	Args   []string
+	Dir    string   // Working directory
+	Env    []string // KEY=value pairs
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// ReadOnlyDirs are host directories the command reads outside the
+	// workspace, such as the one holding its executable. A sandbox mounts
+	// them read-only.
+	ReadOnlyDirs []string
+}
+
+// PlainRunner runs commands directly on the host
+type PlainRunner struct{}
+
+func (r PlainRunner) Run(ctx context.Context, name string, args []string) ([]byte, error) {
+	var output bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := runUntilDone(ctx, cmd)
+	return output.Bytes(), err
+}
+
+func (r PlainRunner) RunCommand(ctx context.Context, c *Command) error {
+	cmd := exec.Command(c.Name, c.Args...)
+	cmd.Dir = c.Dir
+	cmd.Env = c.Env
+	if cmd.Env == nil {
+		// A nil Env would inherit everything
+		cmd.Env = []string{}
+	}
+	cmd.Stdin = c.Stdin
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	return runUntilDone(ctx, cmd)
+}
@@ -0,0 +1,28 @@
+//go:build !unix
+
+package tools
+
+import (
+	"context"
+	"os/exec"
+)
+
+// runUntilDone runs cmd and kills it when ctx is done
+func runUntilDone(ctx context.Context, cmd *exec.Cmd) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cmd.Process.Kill()
+		case <-done:
+		}
+	}()
+	return cmd.Wait()
+}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package tools
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// runUntilDone runs cmd in its own process group and kills the whole group
+// when ctx is done. Killing only cmd would leave children such as a shell
+// script's sleep holding its output pipes, and Wait blocked on them.
+func runUntilDone(ctx context.Context, cmd *exec.Cmd) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-done:
+		}
+	}()
+	return cmd.Wait()
+}
@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"sync"
+)
+
+// SandboxConfig describes the isolated environment for sandboxed commands
+type SandboxConfig struct {
+	Workspace    string // Directory bind-mounted read-write and used as working directory
+	CPUSeconds   int    // RLIMIT_CPU, 0 for unlimited
+	MemoryMB     int    // RLIMIT_AS, 0 for unlimited
+	MaxProcesses int    // RLIMIT_NPROC, 0 for unlimited
+}
+
+// SandboxRunner runs commands in an isolated environment when the platform
+// supports it, and falls back to PlainRunner otherwise
+type SandboxRunner struct {
+	cfg SandboxConfig
+
+	probeOnce sync.Once
+	probeErr  error
+}
+
+// NewSandboxRunner creates a sandbox runner
+func NewSandboxRunner(cfg SandboxConfig) *SandboxRunner {
+	return &SandboxRunner{cfg: cfg}
+}
+
+// Available reports why sandboxing is unavailable, or nil if it works.
+// The check runs once and is cached.
+func (r *SandboxRunner) Available() error {
+	r.probeOnce.Do(func() {
+		r.probeErr = probeSandbox(r.cfg)
+	})
+	return r.probeErr
+}
+
+func (r *SandboxRunner) Run(ctx context.Context, name string, args []string) ([]byte, error) {
+	if r.Available() != nil {
+		return PlainRunner{}.Run(ctx, name, args)
+	}
+	var output bytes.Buffer
+	err := runSandboxed(ctx, r.cfg, &Command{Name: name, Args: args, Stdout: &output, Stderr: &output})
+	return output.Bytes(), err
+}
+
+func (r *SandboxRunner) RunCommand(ctx context.Context, c *Command) error {
+	if r.Available() != nil {
+		return PlainRunner{}.RunCommand(ctx, c)
+	}
+	return runSandboxed(ctx, r.cfg, c)
+}
@@ -0,0 +1,372 @@
+//go:build linux
+
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// sandboxInitArg marks a re-executed goclaw process as the sandbox init helper
+	sandboxInitArg = "__goclaw_sandbox_init"
+	sandboxSpecEnv = "GOCLAW_SANDBOX_SPEC"
+	sandboxHome    = "/home/sandbox"
+	sandboxPath    = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+)
+
+// Host directories made visible read-only inside the sandbox
+var sandboxSystemDirs = []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc"}
+
+// Device nodes bind-mounted into the sandbox's /dev
+var sandboxDevices = []string{"null", "zero", "full", "random", "urandom", "tty"}
+
+// sandboxSpec is passed from the parent to the init helper
+type sandboxSpec struct {
+	Root         string   `json:"root"`
+	Workspace    string   `json:"workspace"`
+	CPUSeconds   int      `json:"cpu_seconds"`
+	MemoryMB     int      `json:"memory_mb"`
+	MaxProcesses int      `json:"max_processes"`
+	Args         []string `json:"args"`           // Empty when probing
+	Dir          string   `json:"dir"`            // Working directory, default the workspace
+	Env          []string `json:"env"`            // Added to PATH, HOME and LANG
+	ReadOnlyDirs []string `json:"read_only_dirs"` // Host directories mounted read-only
+}
+
+// HandleSandboxInit must be called at the very start of main. When the process
+// was started as the sandbox init helper it sets up the sandbox and execs the
+// requested command, never returning. Otherwise it returns immediately.
+func HandleSandboxInit() {
+	if len(os.Args) < 2 || os.Args[1] != sandboxInitArg {
+		return
+	}
+
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(os.Getenv(sandboxSpecEnv)), &spec); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: invalid spec: %v\n", err)
+		os.Exit(126)
+	}
+	if err := enterSandbox(&spec); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(126)
+	}
+	if len(spec.Args) == 0 {
+		os.Exit(0)
+	}
+
+	env := []string{"PATH=" + sandboxPath, "HOME=" + sandboxHome, "LANG=C.UTF-8"}
+	for _, kv := range spec.Env {
+		// Host paths mean nothing inside the sandbox
+		if !strings.HasPrefix(kv, "PATH=") && !strings.HasPrefix(kv, "HOME=") {
+			env = append(env, kv)
+		}
+	}
+	os.Setenv("PATH", sandboxPath)
+	path, err := exec.LookPath(spec.Args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(127)
+	}
+	err = syscall.Exec(path, spec.Args, env)
+	fmt.Fprintf(os.Stderr, "sandbox: exec %s: %v\n", path, err)
+	os.Exit(126)
+}
+
+func probeSandbox(cfg SandboxConfig) error {
+	var output bytes.Buffer
+	if err := runSandboxed(context.Background(), cfg, &Command{Stdout: &output, Stderr: &output}); err != nil {
+		return fmt.Errorf("unprivileged namespaces are unavailable: %w", err)
+	}
+	return nil
+}
+
+// runSandboxed runs c in new namespaces. A command without a name only
+// sets the sandbox up, which probes whether it works.
+func runSandboxed(ctx context.Context, cfg SandboxConfig, c *Command) error {
+	workspace, err := resolveDir(cfg.Workspace)
+	if err != nil {
+		return fmt.Errorf("failed to resolve sandbox workspace: %w", err)
+	}
+
+	// The new root is a tmpfs mounted over this directory inside the child's
+	// mount namespace, so it stays empty on the host
+	root, err := os.MkdirTemp("", "goclaw-sandbox-")
+	if err != nil {
+		return fmt.Errorf("failed to create sandbox root: %w", err)
+	}
+	defer os.RemoveAll(root)
+
+	spec := sandboxSpec{
+		Root:         root,
+		Workspace:    workspace,
+		CPUSeconds:   cfg.CPUSeconds,
+		MemoryMB:     cfg.MemoryMB,
+		MaxProcesses: cfg.MaxProcesses,
+	}
+	if c.Name != "" {
+		spec.Args = append([]string{c.Name}, c.Args...)
+	}
+	if c.Dir != "" {
+		if spec.Dir, err = resolveDir(c.Dir); err != nil {
+			return fmt.Errorf("failed to resolve working directory: %w", err)
+		}
+	}
+	spec.Env = c.Env
+	for _, dir := range append([]string{spec.Dir}, c.ReadOnlyDirs...) {
+		if dir == "" {
+			continue
+		}
+		resolved, err := resolveDir(dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", dir, err)
+		}
+		if !isWithin(workspace, resolved) && !isSystemDir(resolved) {
+			spec.ReadOnlyDirs = append(spec.ReadOnlyDirs, resolved)
+		}
+	}
+	specJSON, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("failed to encode sandbox spec: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe", sandboxInitArg)
+	cmd.Env = []string{sandboxSpecEnv + "=" + string(specJSON)}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET |
+			syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		GidMappingsEnableSetgroups: false,
+		Pdeathsig:                  syscall.SIGKILL,
+	}
+
+	cmd.Stdin = c.Stdin
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+
+	err = cmd.Run()
+	if output, ok := c.Stdout.(*bytes.Buffer); ok && err != nil && c.Name == "" {
+		return fmt.Errorf("%w: %s", err, output)
+	}
+	return err
+}
+
+// resolveDir makes dir absolute with its symlinks resolved, the form the
+// sandbox mounts it under
+func resolveDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// isSystemDir reports whether path is visible in every sandbox already
+func isSystemDir(path string) bool {
+	for _, dir := range sandboxSystemDirs {
+		if isWithin(dir, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// enterSandbox runs inside the new namespaces. It builds a minimal root
+// filesystem, pivots into it and applies resource limits.
+func enterSandbox(spec *sandboxSpec) error {
+	// Keep our mounts from propagating back to the host
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make / private: %w", err)
+	}
+	if err := syscall.Mount("tmpfs", spec.Root, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("mount root tmpfs: %w", err)
+	}
+
+	for _, dir := range sandboxSystemDirs {
+		if err := mirrorReadOnly(spec.Root, dir); err != nil {
+			return err
+		}
+	}
+
+	if err := setupDev(spec.Root); err != nil {
+		return err
+	}
+
+	proc := filepath.Join(spec.Root, "proc")
+	if err := os.MkdirAll(proc, 0555); err != nil {
+		return err
+	}
+	if err := syscall.Mount("proc", proc, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount /proc: %w", err)
+	}
+
+	for _, dir := range []string{"/tmp", sandboxHome} {
+		target := filepath.Join(spec.Root, dir)
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+		if err := syscall.Mount("tmpfs", target, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "size=64m,mode=1777"); err != nil {
+			return fmt.Errorf("mount %s: %w", dir, err)
+		}
+	}
+
+	// Mounted before the workspace, which may lie inside one of them
+	for _, dir := range spec.ReadOnlyDirs {
+		if err := mirrorReadOnly(spec.Root, dir); err != nil {
+			return err
+		}
+	}
+
+	// The workspace keeps its host path so absolute paths still work
+	workspace := filepath.Join(spec.Root, spec.Workspace)
+	if err := os.MkdirAll(workspace, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount(spec.Workspace, workspace, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind workspace: %w", err)
+	}
+
+	if err := pivotRoot(spec.Root); err != nil {
+		return err
+	}
+	dir := spec.Workspace
+	if spec.Dir != "" {
+		dir = spec.Dir
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+
+	return applyRlimits(spec)
+}
+
+// mirrorReadOnly makes a host directory visible read-only under root.
+// Symlinks (e.g. /bin -> usr/bin on merged-usr systems) are recreated as is.
+func mirrorReadOnly(root, dir string) error {
+	info, err := os.Lstat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(root, dir)
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(dir)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, target)
+	}
+
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount(dir, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", dir, err)
+	}
+	return remountReadOnly(dir, target)
+}
+
+// remountReadOnly remounts a bind mount read-only. Flags locked by the
+// parent user namespace (nosuid, nodev, ...) must be preserved or the
+// kernel rejects the remount.
+func remountReadOnly(source, target string) error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(source, &st); err != nil {
+		return err
+	}
+	flags := uintptr(syscall.MS_REMOUNT | syscall.MS_BIND | syscall.MS_RDONLY)
+	for _, f := range []uintptr{syscall.MS_NOSUID, syscall.MS_NODEV, syscall.MS_NOEXEC, syscall.MS_NOATIME, syscall.MS_NODIRATIME} {
+		if uintptr(st.Flags)&f != 0 {
+			flags |= f
+		}
+	}
+	// ST_RELATIME (4096) is reported under a different bit than MS_RELATIME
+	if st.Flags&4096 != 0 {
+		flags |= syscall.MS_RELATIME
+	}
+	if err := syscall.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("remount %s read-only: %w", source, err)
+	}
+	return nil
+}
+
+func setupDev(root string) error {
+	dev := filepath.Join(root, "dev")
+	if err := os.MkdirAll(dev, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", dev, "tmpfs", syscall.MS_NOSUID, "mode=0755"); err != nil {
+		return fmt.Errorf("mount /dev: %w", err)
+	}
+	for _, name := range sandboxDevices {
+		source := filepath.Join("/dev", name)
+		if _, err := os.Stat(source); err != nil {
+			continue
+		}
+		target := filepath.Join(dev, name)
+		f, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		f.Close()
+		if err := syscall.Mount(source, target, "", syscall.MS_BIND, ""); err != nil {
+			return fmt.Errorf("bind %s: %w", source, err)
+		}
+	}
+	return nil
+}
+
+func pivotRoot(root string) error {
+	oldRoot := filepath.Join(root, ".oldroot")
+	if err := os.MkdirAll(oldRoot, 0700); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(root, oldRoot); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+	if err := syscall.Unmount("/.oldroot", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("unmount old root: %w", err)
+	}
+	return os.Remove("/.oldroot")
+}
+
+func applyRlimits(spec *sandboxSpec) error {
+	limits := []struct {
+		resource int
+		value    uint64
+	}{
+		{unix.RLIMIT_CPU, uint64(spec.CPUSeconds)},
+		{unix.RLIMIT_AS, uint64(spec.MemoryMB) * 1024 * 1024},
+		{unix.RLIMIT_NPROC, uint64(spec.MaxProcesses)},
+	}
+	for _, l := range limits {
+		if l.value == 0 {
+			continue
+		}
+		if err := unix.Setrlimit(l.resource, &unix.Rlimit{Cur: l.value, Max: l.value}); err != nil {
+			return fmt.Errorf("setrlimit(%d): %w", l.resource, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSandboxRunner(t *testing.T) {
+	workspace := t.TempDir()
+	hidden := t.TempDir()
+	if err := os.WriteFile(filepath.Join(hidden, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runner := NewSandboxRunner(SandboxConfig{Workspace: workspace, MaxProcesses: 50})
+	if err := runner.Available(); err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"workspace is writable", "echo hi > out.txt && cat out.txt", "hi"},
+		{"starts in the workspace", "pwd", workspace},
+		{"host files are hidden", "cat " + filepath.Join(hidden, "secret.txt") + " || echo hidden", "hidden"},
+		{"system directories are read-only", "touch /etc/goclaw-test || echo read-only", "read-only"},
+		{"home is private", "echo $HOME", sandboxHome},
+		{"environment is passed", "echo $GREETING", "hello"},
+		{"runs as root of its namespace", "id -u", "0"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		err := runner.RunCommand(context.Background(), &Command{
+			Name:   "sh",
+			Args:   []string{"-c", tt.script},
+			Env:    []string{"GREETING=hello", "HOME=/host/home"},
+			Stdout: &out,
+			Stderr: &out,
+		})
+		if err != nil {
+			t.Errorf("%s: %v: %s", tt.name, err, out.String())
+			continue
+		}
+		if got := strings.TrimSpace(out.String()); !strings.HasSuffix(got, tt.want) {
+			t.Errorf("%s: output %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(workspace, "out.txt")); err != nil || string(data) != "hi\n" {
+		t.Errorf("workspace file on the host = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// HandleSandboxInit is a no-op on platforms without sandbox support
+func HandleSandboxInit() {}
+
+func probeSandbox(cfg SandboxConfig) error {
+	return fmt.Errorf("sandboxing is not supported on %s", runtime.GOOS)
+}
+
+func runSandboxed(ctx context.Context, cfg SandboxConfig, c *Command) error {
+	return probeSandbox(cfg)
+}
@@ -0,0 +1,286 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldError describes one argument that failed validation
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when tool arguments do not match the
+// tool's parameter schema. Its message lists every problem so the model
+// can correct all of them in one retry.
+type ValidationError struct {
+	Tool   string       `json:"tool"`
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("invalid arguments for %s:", e.Tool))
+	for _, fe := range e.Errors {
+		b.WriteString(fmt.Sprintf("\n- %s: %s", fe.Path, fe.Message))
+	}
+	b.WriteString("\nFix the arguments to match the tool's parameter schema and call it again.")
+	return b.String()
+}
+
+// ValidateArgs checks args against a JSON schema in place: missing
+// properties with a default are filled in and common mistakes such as
+// numbers or booleans passed as strings are coerced. It returns a
+// *ValidationError when the arguments are still invalid.
+func ValidateArgs(toolName string, schema map[string]interface{}, args map[string]interface{}) error {
+	v := &validator{}
+	v.validateObject("", schema, args)
+	if len(v.errors) > 0 {
+		return &ValidationError{Tool: toolName, Errors: v.errors}
+	}
+	return nil
+}
+
+type validator struct {
+	errors []FieldError
+}
+
+func (v *validator) fail(path, format string, a ...interface{}) {
+	if path == "" {
+		path = "(arguments)"
+	}
+	v.errors = append(v.errors, FieldError{Path: path, Message: fmt.Sprintf(format, a...)})
+}
+
+// validate checks value against schema and returns the possibly coerced value
+func (v *validator) validate(path string, schema map[string]interface{}, value interface{}) interface{} {
+	if value == nil {
+		v.fail(path, "must not be null")
+		return value
+	}
+
+	switch schemaType, _ := schema["type"].(string); schemaType {
+	case "string":
+		switch val := value.(type) {
+		case string:
+		case float64, bool:
+			// Models occasionally send unquoted scalars for string fields
+			value = jsonScalar(val)
+		default:
+			v.fail(path, "expected string, got %s", describe(value))
+			return value
+		}
+
+	case "integer", "number":
+		if s, ok := value.(string); ok {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				value = f
+			}
+		}
+		f, ok := value.(float64)
+		if !ok {
+			v.fail(path, "expected %s, got %s", schemaType, describe(value))
+			return value
+		}
+		if schemaType == "integer" && f != math.Trunc(f) {
+			v.fail(path, "expected integer, got %v", f)
+			return value
+		}
+		if min, ok := numberValue(schema["minimum"]); ok && f < min {
+			v.fail(path, "must be >= %v", min)
+		}
+		if max, ok := numberValue(schema["maximum"]); ok && f > max {
+			v.fail(path, "must be <= %v", max)
+		}
+
+	case "boolean":
+		if s, ok := value.(string); ok {
+			if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+				value = b
+			}
+		}
+		if _, ok := value.(bool); !ok {
+			v.fail(path, "expected boolean, got %s", describe(value))
+			return value
+		}
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			// A JSON-encoded array passed as a string
+			if s, isString := value.(string); isString && json.Unmarshal([]byte(s), &items) == nil {
+				ok = true
+			}
+		}
+		if !ok {
+			v.fail(path, "expected array, got %s", describe(value))
+			return value
+		}
+		if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range items {
+				items[i] = v.validate(fmt.Sprintf("%s[%d]", path, i), itemSchema, item)
+			}
+		}
+		value = items
+
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			if s, isString := value.(string); isString && json.Unmarshal([]byte(s), &obj) == nil {
+				ok = true
+			}
+		}
+		if !ok {
+			v.fail(path, "expected object, got %s", describe(value))
+			return value
+		}
+		v.validateObject(path, schema, obj)
+		value = obj
+	}
+
+	if enum := stringSlice(schema["enum"]); len(enum) > 0 {
+		s := jsonScalar(value)
+		found := false
+		for _, allowed := range enum {
+			if s == allowed {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "must be one of [%s], got %s", strings.Join(enum, ", "), describe(value))
+		}
+	}
+
+	return value
+}
+
+func (v *validator) validateObject(path string, schema map[string]interface{}, obj map[string]interface{}) {
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	// Sort for deterministic error order
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		propSchema, ok := properties[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, present := obj[name]
+		if !present || value == nil {
+			if def, ok := propSchema["default"]; ok {
+				obj[name] = normalizeJSON(def)
+			} else if present {
+				// Treat an explicit null for an optional property as absent
+				delete(obj, name)
+			}
+			continue
+		}
+		obj[name] = v.validate(joinPath(path, name), propSchema, value)
+	}
+
+	for _, name := range stringSlice(schema["required"]) {
+		if value, ok := obj[name]; !ok || value == nil {
+			v.fail(joinPath(path, name), "required property is missing")
+		}
+	}
+
+	if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+		for name := range obj {
+			if _, known := properties[name]; !known {
+				v.fail(joinPath(path, name), "unknown property")
+			}
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// stringSlice accepts both []string (Go literals) and []interface{} (decoded JSON)
+func stringSlice(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			result = append(result, jsonScalar(item))
+		}
+		return result
+	}
+	return nil
+}
+
+// numberValue accepts the numeric types used in Go schema literals
+func numberValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// normalizeJSON converts a Go value to what encoding/json would decode,
+// so defaults from schema literals look like model-provided arguments
+func normalizeJSON(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return value
+	}
+	return result
+}
+
+func jsonScalar(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func describe(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		if len(v) > 40 {
+			v = truncateUTF8(v, 40) + "..."
+		}
+		return fmt.Sprintf("string %q", v)
+	case float64:
+		return fmt.Sprintf("number %v", v)
+	case bool:
+		return fmt.Sprintf("boolean %v", v)
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"path":  map[string]interface{}{"type": "string"},
+		"limit": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 100},
+		"ratio": map[string]interface{}{"type": "number"},
+		"force": map[string]interface{}{"type": "boolean", "default": false},
+		"mode":  map[string]interface{}{"type": "string", "enum": []string{"fast", "slow"}, "default": "fast"},
+		"tags":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"options": map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"depth": map[string]interface{}{"type": "integer"}},
+			"required":   []string{"depth"},
+		},
+	},
+	"required": []string{"path"},
+}
+
+func TestValidateArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       string
+		want       string   // Arguments after validation, as JSON
+		wantErrors []string // "path: message" for each problem, in order
+	}{
+		{"defaults", `{"path": "a"}`, `{"force":false,"mode":"fast","path":"a"}`, nil},
+		{"valid", `{"path": "a", "limit": 5, "ratio": 0.5, "force": true, "mode": "slow", "tags": ["x"], "options": {"depth": 2}}`,
+			`{"force":true,"limit":5,"mode":"slow","options":{"depth":2},"path":"a","ratio":0.5,"tags":["x"]}`, nil},
+		{"coerced scalars", `{"path": 42, "limit": " 7 ", "force": "true"}`, `{"force":true,"limit":7,"mode":"fast","path":"42"}`, nil},
+		{"JSON-encoded containers", `{"path": "a", "tags": "[\"x\", \"y\"]", "options": "{\"depth\": 1}"}`,
+			`{"force":false,"mode":"fast","options":{"depth":1},"path":"a","tags":["x","y"]}`, nil},
+		{"null optional is dropped", `{"path": "a", "limit": null, "mode": null}`, `{"force":false,"mode":"fast","path":"a"}`, nil},
+		{"missing required", `{}`, "", []string{"path: required property is missing"}},
+		{"null required", `{"path": null}`, "", []string{"path: required property is missing"}},
+		{"every problem", `{"path": ["a"], "limit": 1.5, "ratio": "x", "mode": "medium", "force": "maybe"}`, "", []string{
+			"force: expected boolean, got string \"maybe\"",
+			"limit: expected integer, got 1.5",
+			"mode: must be one of [fast, slow], got string \"medium\"",
+			"path: expected string, got array",
+			"ratio: expected number, got string \"x\"",
+		}},
+		{"bounds", `{"path": "a", "limit": 0}`, "", []string{"limit: must be >= 1"}},
+		{"upper bound", `{"path": "a", "limit": 101}`, "", []string{"limit: must be <= 100"}},
+		{"nested", `{"path": "a", "tags": ["x", 1, {}], "options": {}}`, "", []string{
+			"options.depth: required property is missing",
+			"tags[2]: expected string, got object",
+		}},
+		{"bad array string", `{"path": "a", "tags": "x, y"}`, "", []string{"tags: expected array, got string \"x, y\""}},
+	}
+	for _, tt := range tests {
+		var args map[string]interface{}
+		if err := json.Unmarshal([]byte(tt.args), &args); err != nil {
+			t.Fatal(err)
+		}
+		err := ValidateArgs("demo", testSchema, args)
+		if len(tt.wantErrors) == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			if got, _ := json.Marshal(args); string(got) != tt.want {
+				t.Errorf("%s: args = %s, want %s", tt.name, got, tt.want)
+			}
+			continue
+		}
+
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s: error %v, want a ValidationError", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, fe := range verr.Errors {
+			got = append(got, fe.Path+": "+fe.Message)
+		}
+		if !reflect.DeepEqual(got, tt.wantErrors) {
+			t.Errorf("%s: errors\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.wantErrors, "\n"))
+		}
+		if !strings.HasPrefix(err.Error(), "invalid arguments for demo:\n- ") {
+			t.Errorf("%s: message %q", tt.name, err.Error())
+		}
+	}
+}
+
+func TestValidateArgsAdditionalProperties(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           map[string]interface{}{"a": map[string]interface{}{"type": "string"}},
+		"additionalProperties": false,
+	}
+	err := ValidateArgs("demo", schema, map[string]interface{}{"a": "x", "b": "y"})
+	if err == nil || !strings.Contains(err.Error(), "- b: unknown property") {
+		t.Errorf("error %v, want b rejected", err)
+	}
+}
+
+func TestRegistryValidatesArguments(t *testing.T) {
+	reg := New()
+	reg.Register(&GrepFiles{})
+	_, err := reg.ExecuteToolCall("grep_files", `{"max_results": 0, "context": "lots"}`)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Errors) != 3 {
+		t.Fatalf("error %v, want three validation errors", err)
+	}
+	if _, err := reg.ExecuteToolCall("grep_files", `[1, 2]`); err == nil || !strings.Contains(err.Error(), "JSON object") {
+		t.Errorf("non-object arguments: %v", err)
+	}
+}
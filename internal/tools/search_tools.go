@@ -0,0 +1,355 @@
+package tools
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// grepMaxFileSize skips files too large to be source code
+	grepMaxFileSize = 4 * 1024 * 1024
+	// grepMaxLineLen shortens minified or generated lines in the output
+	grepMaxLineLen = 300
+)
+
+// GrepFiles searches file contents with a regular expression
+type GrepFiles struct {
+	Policy *PathPolicy
+}
+
+func (t *GrepFiles) Name() string {
+	return "grep_files"
+}
+
+func (t *GrepFiles) Description() string {
+	return "Search file contents recursively with a regular expression (Go RE2 syntax). " +
+		"Respects .gitignore and skips binary files. Output lines are path:line:text, context lines use path-line-text."
+}
+
+// ParallelSafe marks GrepFiles as read-only
+func (t *GrepFiles) ParallelSafe() bool {
+	return true
+}
+
+type grepFilesArgs struct {
+	Pattern         string   `json:"pattern" desc:"Regular expression to search for" required:"true"`
+	Path            string   `json:"path" desc:"File or directory to search. Defaults to current directory." default:"."`
+	Include         []string `json:"include" desc:"Only search files matching these globs, e.g. [\"*.go\", \"src/**/*.ts\"]"`
+	Exclude         []string `json:"exclude" desc:"Skip files and directories matching these globs"`
+	Context         int      `json:"context" desc:"Lines of context to show around each match (default: 0)" minimum:"0"`
+	MaxResults      int      `json:"max_results" desc:"Maximum number of matching lines (default: 100)" default:"100" minimum:"1"`
+	CaseInsensitive bool     `json:"case_insensitive" desc:"Ignore case when matching"`
+	Gitignore       bool     `json:"gitignore" desc:"Skip files ignored by .gitignore (default: true)" default:"true"`
+}
+
+func (t *GrepFiles) Parameters() map[string]interface{} {
+	return SchemaFor[grepFilesArgs]()
+}
+
+func (t *GrepFiles) Execute(args map[string]interface{}) (string, error) {
+	a, err := DecodeArgs[grepFilesArgs](t.Name(), args)
+	if err != nil {
+		return "", err
+	}
+	// required only rejects a missing pattern; an empty one matches every line
+	if a.Pattern == "" {
+		return "", fmt.Errorf("pattern must not be empty")
+	}
+	pattern := a.Pattern
+	if a.CaseInsensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid regular expression: %w", err)
+	}
+	filter, err := newGlobFilter(a.Include, a.Exclude)
+	if err != nil {
+		return "", err
+	}
+	path, contextLines, maxResults := a.Path, a.Context, a.MaxResults
+
+	// Resolve path and check it against the access policy
+	absPath, err := t.Policy.CheckRead(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to access path: %w", err)
+	}
+
+	var out strings.Builder
+	matches, files := 0, 0
+	truncated := false
+
+	searchFile := func(file, display string) error {
+		n := grepFile(&out, re, file, display, contextLines, maxResults-matches)
+		if n > 0 {
+			files++
+		}
+		matches += n
+		if matches >= maxResults {
+			truncated = true
+			return errStopWalk
+		}
+		return nil
+	}
+
+	if !info.IsDir() {
+		// errStopWalk only matters to a walk; a single file is done either way
+		_ = searchFile(absPath, path)
+	} else {
+		opts := walkOptions{Policy: t.Policy, UseGitignore: a.Gitignore, ShowHidden: true}
+		err = walkTree(absPath, opts, func(full, rel string, d fs.DirEntry, depth int) error {
+			if filter.excluded(rel) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || !d.Type().IsRegular() || !filter.included(rel) {
+				return nil
+			}
+			return searchFile(full, filepath.Join(path, rel))
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to search: %w", err)
+		}
+	}
+
+	if matches == 0 {
+		return fmt.Sprintf("No matches for %q in %s", re.String(), path), nil
+	}
+	summary := fmt.Sprintf("\n[%d matches in %d files", matches, files)
+	if truncated {
+		summary += fmt.Sprintf("; stopped at max_results=%d, narrow the search to see more", maxResults)
+	}
+	return out.String() + summary + "]\n", nil
+}
+
+// grepFile writes matches in one file to out and returns how many lines matched
+func grepFile(out *strings.Builder, re *regexp.Regexp, file, display string, contextLines, limit int) int {
+	info, err := os.Stat(file)
+	if err != nil || info.Size() > grepMaxFileSize {
+		return 0
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0
+	}
+	text, _, isText, err := decodeText(data, "auto")
+	if err != nil || !isText {
+		return 0
+	}
+
+	lines := splitLines(text)
+	matches := 0
+	lastPrinted := -1
+	for i, line := range lines {
+		if matches >= limit {
+			break
+		}
+		if !re.MatchString(line) {
+			continue
+		}
+		matches++
+
+		from := i - contextLines
+		if from <= lastPrinted {
+			from = lastPrinted + 1
+		}
+		if from < 0 {
+			from = 0
+		}
+		if contextLines > 0 && lastPrinted >= 0 && from > lastPrinted+1 {
+			out.WriteString("--\n")
+		}
+		for j := from; j < i; j++ {
+			out.WriteString(fmt.Sprintf("%s-%d-%s\n", display, j+1, clipLine(lines[j])))
+		}
+		out.WriteString(fmt.Sprintf("%s:%d:%s\n", display, i+1, clipLine(line)))
+		lastPrinted = i
+
+		// Trailing context stops at the next match, which prints itself
+		for j := i + 1; j <= i+contextLines && j < len(lines) && !re.MatchString(lines[j]); j++ {
+			out.WriteString(fmt.Sprintf("%s-%d-%s\n", display, j+1, clipLine(lines[j])))
+			lastPrinted = j
+		}
+	}
+	return matches
+}
+
+func clipLine(line string) string {
+	if len(line) <= grepMaxLineLen {
+		return line
+	}
+	return truncateUTF8(line, grepMaxLineLen) + " [...]"
+}
+
+// FindFiles finds files by glob pattern
+type FindFiles struct {
+	Policy *PathPolicy
+}
+
+func (t *FindFiles) Name() string {
+	return "find_files"
+}
+
+func (t *FindFiles) Description() string {
+	return "Find files whose path matches a glob pattern. Supports ** for any number of directories " +
+		"and {a,b} alternatives, e.g. \"**/*.go\" or \"cmd/**/main.{go,ts}\". Respects .gitignore."
+}
+
+// ParallelSafe marks FindFiles as read-only
+func (t *FindFiles) ParallelSafe() bool {
+	return true
+}
+
+type findFilesArgs struct {
+	Pattern    string `json:"pattern" desc:"Glob matched against paths relative to the search directory. Patterns without \"/\" match file names at any depth." required:"true"`
+	Path       string `json:"path" desc:"Directory to search. Defaults to current directory." default:"."`
+	Sort       string `json:"sort" desc:"Sort order: name (default) or mtime (most recently modified first)" enum:"name,mtime" default:"name"`
+	Type       string `json:"type" desc:"Entry type to return: file (default), dir or any" enum:"file,dir,any" default:"file"`
+	MaxResults int    `json:"max_results" desc:"Maximum number of paths to return (default: 200)" default:"200" minimum:"1"`
+	Gitignore  bool   `json:"gitignore" desc:"Skip files ignored by .gitignore (default: true)" default:"true"`
+}
+
+func (t *FindFiles) Parameters() map[string]interface{} {
+	return SchemaFor[findFilesArgs]()
+}
+
+func (t *FindFiles) Execute(args map[string]interface{}) (string, error) {
+	a, err := DecodeArgs[findFilesArgs](t.Name(), args)
+	if err != nil {
+		return "", err
+	}
+	pattern := a.Pattern
+	if pattern == "" || !validGlob(pattern) {
+		return "", fmt.Errorf("invalid glob pattern: %q", pattern)
+	}
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	path, sortBy, entryType, maxResults := a.Path, a.Sort, a.Type, a.MaxResults
+
+	// Resolve path and check it against the access policy
+	absPath, err := t.Policy.CheckRead(path)
+	if err != nil {
+		return "", err
+	}
+
+	type found struct {
+		rel     string
+		modTime time.Time
+	}
+	var results []found
+
+	opts := walkOptions{Policy: t.Policy, UseGitignore: a.Gitignore, ShowHidden: true}
+	err = walkTree(absPath, opts, func(full, rel string, d fs.DirEntry, depth int) error {
+		switch entryType {
+		case "dir":
+			if !d.IsDir() {
+				return nil
+			}
+		case "any":
+		default:
+			if d.IsDir() {
+				return nil
+			}
+		}
+		if !matchGlob(pattern, rel) {
+			return nil
+		}
+		var modTime time.Time
+		if info, err := d.Info(); err == nil {
+			modTime = info.ModTime()
+		}
+		results = append(results, found{rel, modTime})
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to search: %w", err)
+	}
+
+	if len(results) == 0 {
+		return fmt.Sprintf("No files matching %s in %s", pattern, path), nil
+	}
+
+	if sortBy == "mtime" {
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].modTime.After(results[j].modTime)
+		})
+	}
+
+	var out strings.Builder
+	for i, r := range results {
+		if i == maxResults {
+			break
+		}
+		display := filepath.Join(path, r.rel)
+		if sortBy == "mtime" {
+			out.WriteString(fmt.Sprintf("%s  %s\n", r.modTime.Format("2006-01-02 15:04"), display))
+		} else {
+			out.WriteString(display + "\n")
+		}
+	}
+	if len(results) > maxResults {
+		out.WriteString(fmt.Sprintf("\n[showing %d of %d matches; narrow the pattern to see more]\n", maxResults, len(results)))
+	}
+	return out.String(), nil
+}
+
+// globFilter applies include/exclude glob lists to relative paths
+type globFilter struct {
+	include []string
+	exclude []string
+}
+
+func newGlobFilter(include, exclude []string) (*globFilter, error) {
+	f := &globFilter{}
+	for _, list := range []struct {
+		name     string
+		patterns []string
+		target   *[]string
+	}{{"include", include, &f.include}, {"exclude", exclude, &f.exclude}} {
+		for _, pattern := range list.patterns {
+			if !validGlob(pattern) {
+				return nil, fmt.Errorf("invalid %s glob: %s", list.name, pattern)
+			}
+			// Patterns without a slash match names at any depth
+			if !strings.Contains(pattern, "/") {
+				pattern = "**/" + pattern
+			}
+			*list.target = append(*list.target, pattern)
+		}
+	}
+	return f, nil
+}
+
+func (f *globFilter) included(rel string) bool {
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, pattern := range f.include {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *globFilter) excluded(rel string) bool {
+	for _, pattern := range f.exclude {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGrepFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".gitignore":      "vendor/\n",
+		"main.go":         "package main\n\nfunc main() {\n\tgreet()\n}\n",
+		"greet.go":        "package main\n\n// greet says hello\nfunc greet() {\n\tprintln(\"Hello\")\n}\n",
+		"notes.md":        "hello from the notes\n",
+		"vendor/lib.go":   "func greet() {}\n",
+		"image.bin":       "func greet\x00\x01",
+		"sub/deep/one.go": "func greet() {}\n",
+	})
+	tool := &GrepFiles{}
+
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		want    []string
+		wantErr string
+	}{
+		{"default", map[string]interface{}{"pattern": `func greet`},
+			[]string{"greet.go:4:func greet() {", "sub/deep/one.go:1:func greet() {}", "[2 matches in 2 files]"}, ""},
+		{"case insensitive", map[string]interface{}{"pattern": "^hello", "case_insensitive": true},
+			[]string{"notes.md:1:hello from the notes", "[1 matches in 1 files]"}, ""},
+		{"context", map[string]interface{}{"pattern": "println", "context": 1.0},
+			[]string{"greet.go-4-func greet() {", "greet.go:5:\tprintln(\"Hello\")", "greet.go-6-}"}, ""},
+		{"include", map[string]interface{}{"pattern": "greet", "include": []interface{}{"*.md", "main.go"}},
+			[]string{"main.go:4:\tgreet()", "[1 matches in 1 files]"}, ""},
+		{"exclude directory", map[string]interface{}{"pattern": "func greet", "exclude": []interface{}{"sub"}},
+			[]string{"[1 matches in 1 files]"}, ""},
+		{"without gitignore", map[string]interface{}{"pattern": "func greet", "gitignore": false},
+			[]string{"vendor/lib.go:1:func greet() {}", "[3 matches in 3 files]"}, ""},
+		{"max results", map[string]interface{}{"pattern": "greet", "max_results": 2.0},
+			[]string{"[2 matches in", "stopped at max_results=2"}, ""},
+		{"single file", map[string]interface{}{"pattern": "package", "path": "main.go"},
+			[]string{"main.go:1:package main", "[1 matches in 1 files]"}, ""},
+		{"no match", map[string]interface{}{"pattern": "nothing here"}, []string{`No matches for "nothing here"`}, ""},
+		{"empty pattern", map[string]interface{}{"pattern": ""}, nil, "must not be empty"},
+		{"bad regexp", map[string]interface{}{"pattern": "("}, nil, "invalid regular expression"},
+		{"bad glob", map[string]interface{}{"pattern": "x", "include": []interface{}{"["}}, nil, "invalid include glob"},
+	}
+	for _, tt := range tests {
+		path, _ := tt.args["path"].(string)
+		tt.args["path"] = filepath.Join(dir, path)
+		got, err := tool.Execute(tt.args)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		// Results are shown under the path that was asked for
+		got = strings.ReplaceAll(got, dir+string(filepath.Separator), "")
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: output lacks %q:\n%s", tt.name, want, got)
+			}
+		}
+		if strings.Contains(got, "image.bin") {
+			t.Errorf("%s: binary file searched:\n%s", tt.name, got)
+		}
+	}
+}
+
+func TestFindFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".gitignore":          "*.tmp\n",
+		"main.go":             "",
+		"cmd/goclaw/main.go":  "",
+		"cmd/web/main.ts":     "",
+		"cmd/web/scratch.tmp": "",
+		"docs/":               "",
+	})
+	tool := &FindFiles{}
+
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		want    string
+		wantErr string
+	}{
+		{"name at any depth", map[string]interface{}{"pattern": "main.go"}, "cmd/goclaw/main.go\nmain.go\n", ""},
+		{"anchored", map[string]interface{}{"pattern": "cmd/**/main.{go,ts}"}, "cmd/goclaw/main.go\ncmd/web/main.ts\n", ""},
+		{"directories", map[string]interface{}{"pattern": "*", "type": "dir"}, "cmd\ncmd/goclaw\ncmd/web\ndocs\n", ""},
+		{"gitignore", map[string]interface{}{"pattern": "*.tmp"}, "No files matching **/*.tmp", ""},
+		{"without gitignore", map[string]interface{}{"pattern": "*.tmp", "gitignore": false}, "cmd/web/scratch.tmp\n", ""},
+		{"max results", map[string]interface{}{"pattern": "main.*", "max_results": 1.0},
+			"cmd/goclaw/main.go\n\n[showing 1 of 3 matches; narrow the pattern to see more]\n", ""},
+		{"invalid", map[string]interface{}{"pattern": "[x"}, "", "invalid glob pattern"},
+	}
+	for _, tt := range tests {
+		tt.args["path"] = dir
+		got, err := tool.Execute(tt.args)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got = strings.ReplaceAll(got, dir+string(filepath.Separator), "")
+		if !strings.HasPrefix(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// sniffLen is how much of a file is inspected to classify it
+const sniffLen = 8192
+
+// Encodings accepted by read_file's encoding parameter
+var textEncodings = map[string]encoding.Encoding{
+	"gbk":      simplifiedchinese.GB18030, // GB18030 is a superset of GBK and GB2312
+	"gb18030":  simplifiedchinese.GB18030,
+	"utf-16le": unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"utf-16be": unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+}
+
+// decodeText converts file content to UTF-8. With encodingName "auto" the
+// encoding is detected from BOMs and content; ok is false when the data
+// looks binary.
+func decodeText(data []byte, encodingName string) (text, detected string, ok bool, err error) {
+	name := strings.ToLower(encodingName)
+	if name == "" {
+		name = "auto"
+	}
+	if name == "auto" {
+		name = detectEncoding(data)
+		if name == "binary" {
+			return "", name, false, nil
+		}
+	}
+
+	switch name {
+	case "utf-8", "utf8":
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+		return string(data), "utf-8", true, nil
+	case "utf-16":
+		if bytes.HasPrefix(data, []byte{0xfe, 0xff}) {
+			name = "utf-16be"
+		} else {
+			name = "utf-16le"
+		}
+	}
+
+	enc, found := textEncodings[name]
+	if !found {
+		return "", "", false, fmt.Errorf("unsupported encoding %q (use auto, utf-8, gbk, utf-16le or utf-16be)", encodingName)
+	}
+	if strings.HasPrefix(name, "utf-16") {
+		data = bytes.TrimPrefix(bytes.TrimPrefix(data, []byte{0xff, 0xfe}), []byte{0xfe, 0xff})
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to decode as %s: %w", name, err)
+	}
+	return string(decoded), name, true, nil
+}
+
+// detectEncoding guesses the encoding of data, returning "binary" for
+// content that should not be shown as text
+func detectEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xef\xbb\xbf")):
+		return "utf-8"
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		return "utf-16le"
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		return "utf-16be"
+	}
+
+	sample := data
+	if len(sample) > sniffLen {
+		sample = sample[:sniffLen]
+	}
+	if enc := detectUTF16(sample); enc != "" {
+		return enc
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return "binary"
+	}
+	if utf8.Valid(data) {
+		return "utf-8"
+	}
+
+	// Chinese Windows files are commonly GBK; accept the decode only if
+	// it produces no invalid sequences
+	decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data)
+	if err == nil && !bytes.ContainsRune(decoded, utf8.RuneError) {
+		return "gbk"
+	}
+	return "binary"
+}
+
+// detectUTF16 recognizes BOM-less UTF-16 text, where mostly ASCII content
+// leaves every other byte zero
+func detectUTF16(sample []byte) string {
+	if len(sample) < 4 {
+		return ""
+	}
+	var evenZeros, oddZeros int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenZeros++
+		} else {
+			oddZeros++
+		}
+	}
+	half := len(sample) / 2
+	switch {
+	case oddZeros > half*4/10 && evenZeros <= half/20:
+		return "utf-16le"
+	case evenZeros > half*4/10 && oddZeros <= half/20:
+		return "utf-16be"
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// utf16 encodes ASCII s as UTF-16 in the given byte order
+func utf16(s string, bigEndian bool) []byte {
+	var out []byte
+	for _, c := range []byte(s) {
+		if bigEndian {
+			out = append(out, 0, c)
+		} else {
+			out = append(out, c, 0)
+		}
+	}
+	return out
+}
+
+func TestDecodeText(t *testing.T) {
+	gbk := []byte{0xc4, 0xe3, 0xba, 0xc3, 0xa3, 0xac, 0xca, 0xc0, 0xbd, 0xe7} // 你好，世界
+	tests := []struct {
+		name         string
+		data         []byte
+		encoding     string
+		wantText     string
+		wantEncoding string
+		wantBinary   bool
+		wantErr      string
+	}{
+		{"ascii", []byte("hello\n"), "auto", "hello\n", "utf-8", false, ""},
+		{"utf-8", []byte("你好\n"), "", "你好\n", "utf-8", false, ""},
+		{"utf-8 BOM", []byte("\xef\xbb\xbfhi"), "auto", "hi", "utf-8", false, ""},
+		{"gbk", gbk, "auto", "你好，世界", "gbk", false, ""},
+		{"explicit gbk", gbk, "GBK", "你好，世界", "gbk", false, ""},
+		{"utf-16le BOM", append([]byte{0xff, 0xfe}, utf16("hi there", false)...), "auto", "hi there", "utf-16le", false, ""},
+		{"utf-16be BOM", append([]byte{0xfe, 0xff}, utf16("hi there", true)...), "auto", "hi there", "utf-16be", false, ""},
+		{"utf-16le without BOM", utf16("plain text file", false), "auto", "plain text file", "utf-16le", false, ""},
+		{"utf-16be without BOM", utf16("plain text file", true), "auto", "plain text file", "utf-16be", false, ""},
+		{"explicit utf-16", append([]byte{0xfe, 0xff}, utf16("ok", true)...), "utf-16", "ok", "utf-16be", false, ""},
+		{"NUL bytes", []byte("\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00>\x00"), "auto", "", "binary", true, ""},
+		{"PNG header", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "auto", "", "binary", true, ""},
+		{"forced utf-8 on binary", []byte("a\x00b"), "utf-8", "a\x00b", "utf-8", false, ""},
+		{"unknown encoding", []byte("x"), "latin-9", "", "", false, "unsupported encoding"},
+	}
+	for _, tt := range tests {
+		text, encoding, ok, err := decodeText(tt.data, tt.encoding)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if ok == tt.wantBinary || text != tt.wantText || encoding != tt.wantEncoding {
+			t.Errorf("%s: got %q, %s, text %v; want %q, %s, text %v", tt.name, text, encoding, ok, tt.wantText, tt.wantEncoding, !tt.wantBinary)
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	var numbered strings.Builder
+	for i := 1; i <= 10; i++ {
+		numbered.WriteString(strings.Repeat("x", 9) + "\n")
+	}
+	files := map[string][]byte{
+		"small.txt": []byte("one\ntwo\nthree\n"),
+		"lines.txt": []byte(numbered.String()),
+		"gbk.txt":   {0xc4, 0xe3, 0xba, 0xc3, '\n'},
+		"blob.bin":  append([]byte("\x00\x01\x02"), bytes.Repeat([]byte{0}, 64)...),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tool := &ReadFile{MaxBytes: 35}
+
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		want    string
+		wantErr string
+	}{
+		{"whole file", map[string]interface{}{"path": "small.txt"}, "one\ntwo\nthree\n", ""},
+		{"range", map[string]interface{}{"path": "small.txt", "offset": 2.0, "limit": 1.0},
+			"two\n\n[showing lines 2-2 of 3; use offset=3 to continue]\n", ""},
+		{"line numbers", map[string]interface{}{"path": "small.txt", "line_numbers": true},
+			"     1\tone\n     2\ttwo\n     3\tthree\n", ""},
+		{"size cap", map[string]interface{}{"path": "lines.txt"},
+			"xxxxxxxxx\nxxxxxxxxx\nxxxxxxxxx\n\n[showing lines 1-3 of 10; truncated at 35 bytes; use offset=4 to continue]\n", ""},
+		{"gbk", map[string]interface{}{"path": "gbk.txt"}, "你好\n\n[decoded from gbk]\n", ""},
+		{"binary", map[string]interface{}{"path": "blob.bin"}, "Binary file ", ""},
+		{"offset past the end", map[string]interface{}{"path": "small.txt", "offset": 9.0}, "", "past the end"},
+		{"directory", map[string]interface{}{"path": "."}, "", "is a directory"},
+		{"missing", map[string]interface{}{"path": "nope.txt"}, "", "does not exist"},
+	}
+	for _, tt := range tests {
+		tt.args["path"] = filepath.Join(dir, tt.args["path"].(string))
+		got, err := tool.Execute(tt.args)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case !strings.HasPrefix(got, tt.want):
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Tool arguments can be declared as a Go struct instead of a hand-written
+// schema. Fields are described with tags:
+//
+//	type args struct {
+//		Path    string `json:"path" desc:"File to read" required:"true"`
+//		Mode    string `json:"mode,omitempty" desc:"Read mode" enum:"text,binary" default:"text"`
+//		Limit   *int   `json:"limit,omitempty" desc:"Maximum lines" minimum:"1"`
+//	}
+//
+// SchemaFor derives the JSON schema and DecodeArgs fills the struct.
+// Pointer fields stay nil when the model omits them.
+
+var schemaCache sync.Map // reflect.Type -> map[string]interface{}
+
+// SchemaFor returns the JSON schema for the args struct A
+func SchemaFor[A any]() map[string]interface{} {
+	t := reflect.TypeOf((*A)(nil)).Elem()
+	if cached, ok := schemaCache.Load(t); ok {
+		return cached.(map[string]interface{})
+	}
+	schema := schemaForType(t)
+	schemaCache.Store(t, schema)
+	return schema
+}
+
+// DecodeArgs validates args against the schema of A, applying defaults and
+// coercions, and decodes them into a new A
+func DecodeArgs[A any](toolName string, args map[string]interface{}) (A, error) {
+	var result A
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	if err := ValidateArgs(toolName, SchemaFor[A](), args); err != nil {
+		return result, err
+	}
+
+	data, err := json.Marshal(args)
+	if err != nil {
+		return result, fmt.Errorf("failed to encode arguments: %w", err)
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, fmt.Errorf("failed to decode arguments: %w", err)
+	}
+	return result, nil
+}
+
+func schemaForType(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaForType(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object"}
+	case reflect.Struct:
+		return schemaForStruct(t)
+	}
+	// interface{} and anything else accept any value
+	return map[string]interface{}{}
+}
+
+func schemaForStruct(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := schemaForType(field.Type)
+		if desc := field.Tag.Get("desc"); desc != "" {
+			prop["description"] = desc
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			prop["enum"] = strings.Split(enum, ",")
+		}
+		if def, ok := field.Tag.Lookup("default"); ok {
+			prop["default"] = parseTagValue(prop["type"], def)
+		}
+		for _, key := range []string{"minimum", "maximum"} {
+			if v := field.Tag.Get(key); v != "" {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					prop[key] = f
+				}
+			}
+		}
+		if field.Tag.Get("required") == "true" {
+			required = append(required, name)
+		}
+		properties[name] = prop
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// parseTagValue converts a default tag to the JSON value for its schema type
+func parseTagValue(schemaType interface{}, value string) interface{} {
+	switch schemaType {
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type typedInner struct {
+	Name string `json:"name" required:"true"`
+}
+
+type typedArgs struct {
+	Path     string            `json:"path" desc:"File to read" required:"true"`
+	Mode     string            `json:"mode,omitempty" enum:"text,binary" default:"text"`
+	Limit    *int              `json:"limit,omitempty" minimum:"1" maximum:"10"`
+	Verbose  bool              `json:"verbose" default:"true"`
+	Ratio    float64           `json:"ratio" default:"0.5"`
+	Tags     []string          `json:"tags"`
+	Items    []typedInner      `json:"items"`
+	Labels   map[string]string `json:"labels"`
+	Any      interface{}       `json:"any"`
+	Skipped  string            `json:"-"`
+	hidden   string
+	Untagged int
+}
+
+func TestSchemaFor(t *testing.T) {
+	got, err := json.Marshal(SchemaFor[typedArgs]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"properties":{` +
+		`"Untagged":{"type":"integer"},` +
+		`"any":{},` +
+		`"items":{"items":{"properties":{"name":{"type":"string"}},"required":["name"],"type":"object"},"type":"array"},` +
+		`"labels":{"type":"object"},` +
+		`"limit":{"maximum":10,"minimum":1,"type":"integer"},` +
+		`"mode":{"default":"text","enum":["text","binary"],"type":"string"},` +
+		`"path":{"description":"File to read","type":"string"},` +
+		`"ratio":{"default":0.5,"type":"number"},` +
+		`"tags":{"items":{"type":"string"},"type":"array"},` +
+		`"verbose":{"default":true,"type":"boolean"}` +
+		`},"required":["path"],"type":"object"}`
+	if string(got) != want {
+		t.Errorf("SchemaFor:\n got %s\nwant %s", got, want)
+	}
+
+	// The schema is cached per type
+	a, b := SchemaFor[typedArgs](), SchemaFor[typedArgs]()
+	a["marker"] = true
+	if b["marker"] != true {
+		t.Error("SchemaFor did not return the cached schema")
+	}
+	delete(a, "marker")
+}
+
+func TestDecodeArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		check   func(a typedArgs) bool
+		wantErr string
+	}{
+		{"defaults", `{"path": "a"}`, func(a typedArgs) bool {
+			return a.Path == "a" && a.Mode == "text" && a.Limit == nil && a.Verbose && a.Ratio == 0.5
+		}, ""},
+		{"values", `{"path": "a", "mode": "binary", "limit": 3, "verbose": false, "tags": ["x"], "items": [{"name": "n"}], "labels": {"k": "v"}}`,
+			func(a typedArgs) bool {
+				return a.Mode == "binary" && a.Limit != nil && *a.Limit == 3 && !a.Verbose &&
+					len(a.Tags) == 1 && a.Items[0].Name == "n" && a.Labels["k"] == "v"
+			}, ""},
+		{"coercion", `{"path": 7, "limit": "4", "verbose": "false"}`, func(a typedArgs) bool {
+			return a.Path == "7" && *a.Limit == 4 && !a.Verbose
+		}, ""},
+		{"any value", `{"path": "a", "any": [1, "two"]}`, func(a typedArgs) bool {
+			list, ok := a.Any.([]interface{})
+			return ok && len(list) == 2
+		}, ""},
+		{"missing required", `{"mode": "text"}`, nil, "path: required property is missing"},
+		{"bad enum", `{"path": "a", "mode": "hex"}`, nil, "mode: must be one of [text, binary]"},
+		{"out of range", `{"path": "a", "limit": 11}`, nil, "limit: must be <= 10"},
+		{"nested required", `{"path": "a", "items": [{}]}`, nil, "items[0].name: required property is missing"},
+	}
+	for _, tt := range tests {
+		var args map[string]interface{}
+		if err := json.Unmarshal([]byte(tt.args), &args); err != nil {
+			t.Fatal(err)
+		}
+		a, err := DecodeArgs[typedArgs]("demo", args)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case !tt.check(a):
+			t.Errorf("%s: decoded %+v", tt.name, a)
+		}
+	}
+
+	if a, err := DecodeArgs[struct {
+		N int `json:"n" default:"2"`
+	}]("demo", nil); err != nil || a.N != 2 {
+		t.Errorf("nil arguments: %+v, %v", a, err)
+	}
+}
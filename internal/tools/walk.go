@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is one pattern from a .gitignore file
+type ignoreRule struct {
+	base    string // Slash-separated directory of the .gitignore, relative to the walk root
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreMatcher holds the .gitignore rules in effect for a directory.
+// Rules from deeper directories come later and take precedence.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// withDir returns a matcher extended by dir's .gitignore, if any.
+// The receiver is not modified so sibling directories are unaffected.
+func (m *ignoreMatcher) withDir(dir, relDir string) *ignoreMatcher {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return m
+	}
+	defer f.Close()
+
+	rules := append([]ignoreRule(nil), m.rules...)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: relDir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		// Patterns without an inner slash match at any depth
+		if strings.Contains(line, "/") {
+			line = strings.TrimPrefix(line, "/")
+		} else {
+			line = "**/" + line
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return &ignoreMatcher{rules: rules}
+}
+
+// ignored reports whether the slash-separated path rel is excluded
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	result := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		target := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			target = strings.TrimPrefix(rel, rule.base+"/")
+		}
+		if matchGlob(rule.pattern, target) {
+			result = !rule.negate
+		}
+	}
+	return result
+}
+
+// walkOptions controls walkTree
+type walkOptions struct {
+	Policy       *PathPolicy
+	UseGitignore bool
+	ShowHidden   bool
+	MaxDepth     int // 0 for unlimited; 1 lists only the root's entries
+}
+
+// walkFunc receives each visited entry with its slash-separated path
+// relative to the walk root. Returning filepath.SkipDir skips a directory,
+// errStopWalk ends the walk early and any other error aborts it.
+type walkFunc func(path, rel string, d fs.DirEntry, depth int) error
+
+// errStopWalk stops walkTree without reporting an error
+var errStopWalk = errors.New("stop walk")
+
+// walkTree walks root in lexical order, skipping .git, entries hidden by
+// the options, entries excluded by .gitignore and paths the policy denies.
+// Symlinked directories are not followed.
+func walkTree(root string, opts walkOptions, fn walkFunc) error {
+	matcher := &ignoreMatcher{}
+	if opts.UseGitignore {
+		matcher = matcher.withDir(root, "")
+	}
+	err := walkDir(root, "", 1, matcher, opts, fn)
+	if err == errStopWalk {
+		return nil
+	}
+	return err
+}
+
+func walkDir(dir, relDir string, depth int, matcher *ignoreMatcher, opts walkOptions, fn walkFunc) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if relDir != "" {
+			// Unreadable subdirectories are skipped
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		full := filepath.Join(dir, name)
+		rel := path.Join(relDir, name)
+
+		if name == ".git" {
+			continue
+		}
+		if !opts.ShowHidden && strings.HasPrefix(name, ".") {
+			continue
+		}
+		if opts.UseGitignore && matcher.ignored(rel, entry.IsDir()) {
+			continue
+		}
+		if !opts.Policy.Allowed(full) {
+			continue
+		}
+
+		err := fn(full, rel, entry, depth)
+		if err == filepath.SkipDir {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() && (opts.MaxDepth == 0 || depth < opts.MaxDepth) {
+			child := matcher
+			if opts.UseGitignore {
+				child = matcher.withDir(full, rel)
+			}
+			if err := walkDir(full, rel, depth+1, child, opts, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
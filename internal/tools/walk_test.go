@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTree creates files under dir; names ending in "/" are directories
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWalkTreeGitignore(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".gitignore":           "# build output\n*.log\n!keep.log\nbuild/\n/top.txt\ndocs/*.tmp\n",
+		".git/config":          "",
+		".env":                 "",
+		"app.log":              "",
+		"keep.log":             "",
+		"top.txt":              "",
+		"main.go":              "",
+		"build/out.bin":        "",
+		"src/build":            "not a directory, so build/ does not apply",
+		"src/top.txt":          "only the root top.txt is anchored",
+		"src/debug.log":        "",
+		"docs/a.tmp":           "",
+		"docs/nested/b.tmp":    "",
+		"pkg/.gitignore":       "generated.go\n!important.log\n",
+		"pkg/generated.go":     "",
+		"pkg/lib.go":           "",
+		"pkg/important.log":    "",
+		"pkg/sub/generated.go": "",
+		"other/generated.go":   "the nested .gitignore does not reach here",
+	})
+
+	walk := func(opts walkOptions) []string {
+		var visited []string
+		err := walkTree(dir, opts, func(path, rel string, d fs.DirEntry, depth int) error {
+			if !d.IsDir() {
+				visited = append(visited, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return visited
+	}
+
+	got := strings.Join(walk(walkOptions{UseGitignore: true, ShowHidden: true}), " ")
+	want := ".env .gitignore docs/nested/b.tmp keep.log main.go other/generated.go " +
+		"pkg/.gitignore pkg/important.log pkg/lib.go src/build src/top.txt"
+	if got != want {
+		t.Errorf("with .gitignore:\n got %s\nwant %s", got, want)
+	}
+
+	got = strings.Join(walk(walkOptions{}), " ")
+	if strings.Contains(got, ".env") || strings.Contains(got, ".git/") || !strings.Contains(got, "build/out.bin") {
+		t.Errorf("without .gitignore or hidden files: %s", got)
+	}
+
+	got = strings.Join(walk(walkOptions{MaxDepth: 1}), " ")
+	if got != "app.log keep.log main.go top.txt" {
+		t.Errorf("depth 1: %s", got)
+	}
+}
+
+func TestWalkTreeStop(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a": "", "b": "", "c": ""})
+	var visited []string
+	err := walkTree(dir, walkOptions{}, func(path, rel string, d fs.DirEntry, depth int) error {
+		visited = append(visited, rel)
+		if rel == "b" {
+			return errStopWalk
+		}
+		return nil
+	})
+	if err != nil || strings.Join(visited, ",") != "a,b" {
+		t.Errorf("visited %v, error %v", visited, err)
+	}
+}